@@ -12,6 +12,10 @@ func NewDropdown(options []string, key tcell.Key, do func(int)) *Dropdown {
 	return &Dropdown{Select: NewSelect(options, key, do)}
 }
 
+func (d *Dropdown) IsOpen() bool {
+	return d.open
+}
+
 func (d *Dropdown) Render(updateScreen bool) {
 	if !d.visible {
 		return
@@ -2,6 +2,7 @@ package components
 
 import (
 	"sync"
+	"time"
 
 	"github.com/claude42/infiltrator/fail"
 	"github.com/claude42/infiltrator/util"
@@ -57,6 +58,12 @@ func (i *InputImpl) SetUpdateWatchersFunc(newFunc func()) {
 	i.delay.SetInvokeFunc(newFunc)
 }
 
+// SetUpdateDelay sets how long typing has to pause before watchers get
+// updated.
+func (i *InputImpl) SetUpdateDelay(duration time.Duration) {
+	i.delay.SetDelay(duration)
+}
+
 func (i *InputImpl) Resize(x, y, width, height int) {
 	// height gets ignored
 	i.ComponentImpl.Resize(x, y, width, 1)
@@ -0,0 +1,263 @@
+package components
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/claude42/infiltrator/util"
+	"github.com/gdamore/tcell/v2"
+)
+
+// at most this many options are shown at once when the box is open
+const multiSelectMaxRows = 15
+
+// MultiSelect shows the checked options. Pressing key opens a box to check
+// and uncheck options with space. Enter closes the box and calls do, Esc
+// closes it and discards the changes.
+type MultiSelect struct {
+	ComponentImpl
+	util.ObservableImpl
+
+	// shown in the box, Labels (if set) are shown when the box is closed
+	Options []string
+	Labels  []string
+	Checked []bool
+	// shown when nothing is checked
+	Placeholder string
+	// at most one option can be checked at a time
+	Single bool
+
+	cursor int
+	offset int
+	open   bool
+	// to restore on Esc
+	checkedBefore []bool
+
+	key tcell.Key
+	do  func([]bool)
+
+	OldStyler     Styler
+	CurrentStyler Styler
+}
+
+func NewMultiSelect(placeholder string, key tcell.Key, do func([]bool)) *MultiSelect {
+	m := &MultiSelect{Placeholder: placeholder, key: key, do: do}
+	m.StyleUsing(m)
+
+	return m
+}
+
+func (m *MultiSelect) SetOptions(options []string, labels []string, checked []bool) {
+	m.Options = options
+	m.Labels = labels
+	m.Checked = checked
+	m.cursor = min(m.cursor, max(0, len(options)-1))
+	m.offset = 0
+}
+
+func (m *MultiSelect) IsOpen() bool {
+	return m.open
+}
+
+func (m *MultiSelect) Resize(x, y, width, height int) {
+	// height gets ignored
+	m.ComponentImpl.Resize(x, y, width, 1)
+}
+
+func (m *MultiSelect) Height() int {
+	return 1
+}
+
+func (m *MultiSelect) Size() (int, int) {
+	return m.width, 1
+}
+
+func (m *MultiSelect) label() string {
+	var checked []string
+	for i, c := range m.Checked {
+		if !c {
+			continue
+		}
+		if i < len(m.Labels) {
+			checked = append(checked, m.Labels[i])
+		} else {
+			checked = append(checked, m.Options[i])
+		}
+	}
+
+	if len(checked) == 0 {
+		return m.Placeholder
+	}
+	return strings.Join(checked, ", ")
+}
+
+func (m *MultiSelect) Render(updateScreen bool) {
+	if !m.visible {
+		return
+	}
+
+	label := m.label()
+	if m.width > 2 && len(label) > m.width-2 {
+		label = label[:m.width-3] + "…"
+	}
+	RenderText(m.x, m.y, fmt.Sprintf("[%-*s]", max(0, m.width-2), label), m.CurrentStyler.Style())
+
+	if m.open {
+		m.renderBox()
+	}
+
+	if updateScreen {
+		Screen.Show()
+	}
+}
+
+func (m *MultiSelect) rows() int {
+	return max(1, min(len(m.Options), multiSelectMaxRows, m.y-2))
+}
+
+// the box opens upwards as panels are at the bottom of the screen
+func (m *MultiSelect) renderBox() {
+	style := m.CurrentStyler.Style()
+	cursorStyle := style.Reverse(false)
+
+	width := m.width
+	for _, option := range m.Options {
+		width = max(width, len(option)+6)
+	}
+	rows := m.rows()
+	boxY := m.y - rows - 2
+
+	Screen.SetContent(m.x, boxY, tcell.RuneULCorner, nil, style)
+	DrawChars(m.x+1, boxY, width-2, tcell.RuneHLine, style)
+	Screen.SetContent(m.x+width-1, boxY, tcell.RuneURCorner, nil, style)
+
+	for row := range rows {
+		y := boxY + 1 + row
+		Screen.SetContent(m.x, y, tcell.RuneVLine, nil, style)
+
+		i := m.offset + row
+		text := ""
+		if i < len(m.Options) {
+			check := " "
+			if m.Checked[i] {
+				check = "x"
+			}
+			if m.Single {
+				text = fmt.Sprintf("(%s) %s", check, m.Options[i])
+			} else {
+				text = fmt.Sprintf("[%s] %s", check, m.Options[i])
+			}
+		}
+		textStyle := style
+		if i == m.cursor {
+			textStyle = cursorStyle
+		}
+		RenderText(m.x+1, y, fmt.Sprintf("%-*s", width-2, text), textStyle)
+
+		Screen.SetContent(m.x+width-1, y, tcell.RuneVLine, nil, style)
+	}
+
+	Screen.SetContent(m.x, m.y-1, tcell.RuneLLCorner, nil, style)
+	DrawChars(m.x+1, m.y-1, width-2, tcell.RuneHLine, style)
+	Screen.SetContent(m.x+width-1, m.y-1, tcell.RuneLRCorner, nil, style)
+}
+
+func (m *MultiSelect) moveCursor(offset int) {
+	if len(m.Options) == 0 {
+		return
+	}
+
+	m.cursor = max(0, min(len(m.Options)-1, m.cursor+offset))
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	} else if m.cursor >= m.offset+m.rows() {
+		m.offset = m.cursor - m.rows() + 1
+	}
+}
+
+func (m *MultiSelect) close(apply bool) {
+	m.open = false
+
+	if !apply {
+		m.Checked = m.checkedBefore
+		return
+	}
+	if m.do != nil {
+		m.do(m.Checked)
+	}
+}
+
+func (m *MultiSelect) Style() tcell.Style {
+	style := tcell.StyleDefault.Reverse(true)
+
+	if m.IsActive() {
+		return style.Bold(true)
+	} else {
+		return style
+	}
+}
+
+func (m *MultiSelect) StyleUsing(styler Styler) {
+	if m.CurrentStyler != nil {
+		m.OldStyler = m.CurrentStyler
+	}
+	m.CurrentStyler = styler
+}
+
+func (m *MultiSelect) HandleEvent(ev tcell.Event) bool {
+	if !m.IsActive() {
+		return false
+	}
+
+	tev, ok := ev.(*tcell.EventKey)
+	if !ok {
+		return false
+	}
+
+	if tev.Key() == m.key {
+		if m.open {
+			m.close(false)
+		} else {
+			m.open = true
+			m.checkedBefore = slices.Clone(m.Checked)
+		}
+		RenderAll(true)
+		return true
+	}
+
+	if !m.open {
+		return false
+	}
+
+	switch tev.Key() {
+	case tcell.KeyUp:
+		m.moveCursor(-1)
+	case tcell.KeyDown:
+		m.moveCursor(1)
+	case tcell.KeyPgUp:
+		m.moveCursor(-m.rows())
+	case tcell.KeyPgDn:
+		m.moveCursor(m.rows())
+	case tcell.KeyRune:
+		if tev.Rune() != ' ' || len(m.Options) == 0 {
+			return true
+		}
+		checked := !m.Checked[m.cursor]
+		if m.Single {
+			clear(m.Checked)
+		}
+		m.Checked[m.cursor] = checked
+	case tcell.KeyEnter:
+		m.close(true)
+	case tcell.KeyEscape:
+		m.close(false)
+	default:
+		m.close(false)
+		RenderAll(true)
+		return false
+	}
+
+	RenderAll(true)
+	return true
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/adrg/xdg"
 	"github.com/claude42/infiltrator/fail"
@@ -31,7 +33,7 @@ type ConfigManager struct {
 	kConfig *koanf.Koanf `koanf:"-"`
 
 	kFormats *koanf.Koanf      `koanf:"-"`
-	formats  map[string]string `koanf:"-"`
+	formats  map[string]Format `koanf:"-"`
 
 	kState    *koanf.Koanf        `koanf:"-"`
 	histories map[string][]string `koanf:"-"`
@@ -48,8 +50,40 @@ type UserConfig struct {
 	Preset   string `koanf:"preset"`
 	Debug    bool   `koanf:"debug"`
 
+	// all files given on the command line, FilePath is the first of them
+	FilePaths []string `koanf:"filepaths"`
+	Rotated   bool     `koanf:"rotated"`
+
+	// set if several files get merged by their timestamps
+	Merge bool `koanf:"-"`
+
+	// command given after "--" to read from instead of a file
+	Command []string `koanf:"command"`
+	Stderr  bool     `koanf:"stderr"`
+
+	// address to receive syslog messages on, e.g. udp://127.0.0.1:5514
+	Listen string `koanf:"listen"`
+
+	// longer lines get truncated, 0 means no limit at all
+	MaxLineLength int `koanf:"maxlinelength"`
+
+	// JSON and logfmt lines get shown as "ts level msg key=val ..." instead
+	// of raw. Leading fields are shown first, without their key. Each entry
+	// lists alternative names, e.g. "msg|message".
+	Compact       bool     `koanf:"compact"`
+	LeadingFields []string `koanf:"leadingfields"`
+
+	// timezone of timestamps which don't contain one, e.g. UTC or
+	// Europe/Berlin. Empty means local time.
+	Timezone string         `koanf:"timezone"`
+	Location *time.Location `koanf:"-"`
+
 	FileFormat      string         `koanf:"-"`
 	FileFormatRegex *regexp.Regexp `koanf:"-"`
+	// lines not matching this regex belong to the record of the line before
+	RecordStartRegex *regexp.Regexp `koanf:"-"`
+	// names of the fields of formats without a regex (e.g. JSON)
+	FileFormatFields []string `koanf:"-"`
 }
 
 type PanelTable struct {
@@ -60,6 +94,10 @@ type PanelTable struct {
 	From          string `koanf:"from"`
 	To            string `koanf:"to"`
 	ColorIndex    uint8  `koanf:"color"`
+	Origin        string `koanf:"origin"`
+	Field         string `koanf:"field"`
+	Before        int    `koanf:"before"`
+	After         int    `koanf:"after"`
 }
 
 func init() {
@@ -67,7 +105,7 @@ func init() {
 		kConfig:    koanf.New("."),
 		kFormats:   koanf.New("."),
 		kState:     koanf.New("."),
-		formats:    make(map[string]string),
+		formats:    make(map[string]Format),
 		histories:  make(map[string][]string),
 		UserConfig: &UserConfig{},
 		Panels:     make([]PanelTable, 0),
@@ -78,7 +116,7 @@ func User() *UserConfig {
 	return cm.UserConfig
 }
 
-func Formats() map[string]string {
+func Formats() map[string]Format {
 	return cm.formats
 }
 
@@ -114,6 +152,11 @@ func Load() error {
 
 	unmarshal()
 
+	err = resolveLocation()
+	if err != nil {
+		return err
+	}
+
 	readStateFile()
 	readFormatsFile()
 
@@ -121,7 +164,35 @@ func Load() error {
 }
 
 func doSomeAdjustments() {
+	if len(cm.kConfig.Strings("main.command")) > 0 ||
+		cm.kConfig.String("main.listen") != "" {
+
+		return
+	}
+
 	cm.kConfig.Set("main.filename", filepath.Base(cm.kConfig.String("main.filepath")))
+
+	// a preset might have overwritten the filepath, in this case it wins
+	// over a single file given on the command line
+	if len(cm.kConfig.Strings("main.filepaths")) <= 1 &&
+		cm.kConfig.String("main.filepath") != "" {
+
+		cm.kConfig.Set("main.filepaths", []string{cm.kConfig.String("main.filepath")})
+	}
+}
+
+func resolveLocation() error {
+	if cm.UserConfig.Timezone == "" {
+		cm.UserConfig.Location = time.Local
+		return nil
+	}
+
+	location, err := time.LoadLocation(cm.UserConfig.Timezone)
+	if err != nil {
+		return fmt.Errorf("unknown timezone %s: %w", cm.UserConfig.Timezone, err)
+	}
+	cm.UserConfig.Location = location
+	return nil
 }
 
 func unmarshal() {
@@ -154,6 +225,11 @@ func readCommandLine() error {
 	colorize := flagSet.BoolP("colorize", "c", true, "Colorize output if it's in a well known format")
 	debug := flagSet.BoolP("debug", "d", false, "Log debugging information to ./debug.log")
 	preset := flagSet.StringP("preset", "p", "", "Load preset by name")
+	rotated := flagSet.BoolP("rotated", "r", false, "Also read rotated versions of file (file.1, file.2.gz, ...)")
+	stderr := flagSet.BoolP("stderr", "e", false, "Also read stderr of command given after --")
+	maxLineLength := flagSet.Int("max-line-length", 0, "Truncate lines longer than this many bytes, 0 means no limit")
+	timezone := flagSet.String("timezone", "", "Timezone of timestamps without one, e.g. UTC, defaults to local time")
+	listen := flagSet.String("listen", "", "Receive syslog messages, e.g. udp://127.0.0.1:5514 or tcp://:5514")
 
 	err := flagSet.Parse(os.Args[1:])
 	fail.OnError(err, "Parsing of command line failed")
@@ -183,18 +259,73 @@ func readCommandLine() error {
 		fail.OnError(err, "Error setting command line option")
 	}
 
+	if flagSet.Lookup("rotated").Changed {
+		err := cm.kConfig.Set("main.rotated", *rotated)
+		fail.OnError(err, "Error setting command line option")
+	}
+
+	if flagSet.Lookup("stderr").Changed {
+		err := cm.kConfig.Set("main.stderr", *stderr)
+		fail.OnError(err, "Error setting command line option")
+	}
+
+	if flagSet.Lookup("max-line-length").Changed {
+		err := cm.kConfig.Set("main.maxlinelength", *maxLineLength)
+		fail.OnError(err, "Error setting command line option")
+	}
+
+	if flagSet.Lookup("timezone").Changed {
+		err := cm.kConfig.Set("main.timezone", *timezone)
+		fail.OnError(err, "Error setting command line option")
+	}
+
+	if *listen != "" {
+		if len(flagSet.Args()) > 0 || *rotated {
+			flagSet.Usage()
+			return fmt.Errorf("either give filenames or --listen")
+		}
+		cm.kConfig.Set("main.filename", *listen)
+		cm.kConfig.Set("main.filepath", "")
+		cm.kConfig.Set("main.filepaths", []string{})
+		cm.kConfig.Set("main.listen", *listen)
+		cm.kConfig.Set("main.stdin", false)
+		// there's nothing but new messages
+		cm.kConfig.Set("main.follow", true)
+		return nil
+	}
+
+	// everything after "--" is a command to run
+	if dash := flagSet.ArgsLenAtDash(); dash >= 0 {
+		command := flagSet.Args()[dash:]
+		if dash > 0 || len(command) == 0 || *rotated {
+			flagSet.Usage()
+			return fmt.Errorf("either give filenames or a command after --")
+		}
+		cm.kConfig.Set("main.filename", strings.Join(command, " "))
+		cm.kConfig.Set("main.filepath", "")
+		cm.kConfig.Set("main.filepaths", []string{})
+		cm.kConfig.Set("main.command", command)
+		cm.kConfig.Set("main.stdin", false)
+		return nil
+	}
+
+	// more than one file either belong to the same rotated log family or get
+	// merged, this gets decided later when the files are opened
 	switch len(flagSet.Args()) {
 	case 0:
+		if *rotated {
+			flagSet.Usage()
+			return fmt.Errorf("--rotated requires a filename")
+		}
 		cm.kConfig.Set("main.filename", "[stdin]")
 		cm.kConfig.Set("main.filepath", "")
+		cm.kConfig.Set("main.filepaths", []string{})
 		cm.kConfig.Set("main.stdin", true)
-	case 1:
+	default:
 		cm.kConfig.Set("main.filename", filepath.Base(flagSet.Args()[0]))
 		cm.kConfig.Set("main.filepath", flagSet.Args()[0])
+		cm.kConfig.Set("main.filepaths", flagSet.Args())
 		cm.kConfig.Set("main.stdin", false)
-	default:
-		flagSet.Usage()
-		return fmt.Errorf("try again")
 	}
 
 	return nil
@@ -213,6 +344,9 @@ func WritePreset(fullPath string) error {
 	// presetK.Load(confmap.Provider(instance.kConfig.All(), "."), nil)
 
 	presetK.Delete("main.filename")
+	presetK.Delete("main.filepaths")
+	presetK.Delete("main.command")
+	presetK.Delete("main.listen")
 	presetK.Delete("main.stdin")
 	presetK.Delete("main.preset")
 	presetK.Delete("main.debug")
@@ -15,15 +15,30 @@ const (
 	FilterTypeKeyword FilterType = iota
 	FilterTypeRegex
 	FilterTypeDate
-	PanelTypeGlob
-	PanelTypeHost
-	PanelTypeFacility
+	FilterTypeQuery
+	FilterTypeCompare
+	FilterTypeGlob
+	FilterTypeHost
+	FilterTypeFacility
+	FilterTypeSeverity
+	FilterTypeDedup
+	FilterTypeList
+	FilterTypeCommand
 
 	FilterTypeCount
 
-	FilterStringKeyword = "Keyword"
-	FilterStringRegex   = "Regex"
-	FilterStringDate    = "Date"
+	FilterStringKeyword  = "Keyword"
+	FilterStringRegex    = "Regex"
+	FilterStringDate     = "Date"
+	FilterStringQuery    = "Query"
+	FilterStringCompare  = "Compare"
+	FilterStringGlob     = "Glob"
+	FilterStringHost     = "Host"
+	FilterStringFacility = "Facility"
+	FilterStringSeverity = "Severity"
+	FilterStringDedup    = "Dedup"
+	FilterStringList     = "List"
+	FilterStringCommand  = "Command"
 
 	// These are no filters of their own, just names for the two inputs for
 	// the date filter
@@ -42,6 +57,15 @@ var Filters FilterSlice = []FilterTuple{
 	{FilterType: FilterTypeKeyword, FilterString: FilterStringKeyword},
 	{FilterType: FilterTypeRegex, FilterString: FilterStringRegex},
 	{FilterType: FilterTypeDate, FilterString: FilterStringDate},
+	{FilterType: FilterTypeQuery, FilterString: FilterStringQuery},
+	{FilterType: FilterTypeCompare, FilterString: FilterStringCompare},
+	{FilterType: FilterTypeGlob, FilterString: FilterStringGlob},
+	{FilterType: FilterTypeHost, FilterString: FilterStringHost},
+	{FilterType: FilterTypeFacility, FilterString: FilterStringFacility},
+	{FilterType: FilterTypeSeverity, FilterString: FilterStringSeverity},
+	{FilterType: FilterTypeDedup, FilterString: FilterStringDedup},
+	{FilterType: FilterTypeList, FilterString: FilterStringList},
+	{FilterType: FilterTypeCommand, FilterString: FilterStringCommand},
 }
 
 func (FilterSlice) String(key FilterType) (string, error) {
@@ -73,6 +97,9 @@ func (FilterSlice) Type(value string) (FilterType, error) {
 var Histories []string = []string{
 	FilterStringKeyword,
 	FilterStringRegex,
+	FilterStringQuery,
+	FilterStringCompare,
+	FilterStringGlob,
 	FilterStringFrom,
 	FilterStringTo,
 }
@@ -3,4 +3,8 @@ package config
 var defaults map[string]any = map[string]any{
 	"main.name":     "Default",
 	"main.colorize": true,
+
+	"main.compact": true,
+	"main.leadingfields": []string{"time|ts|timestamp|@timestamp", "level|lvl|severity",
+		"msg|message"},
 }
@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 
 	"github.com/adrg/xdg"
@@ -12,6 +13,28 @@ import (
 
 const formatsFileName = "/formats.toml"
 
+// Format is one entry of formats.toml. It's either just the regex
+//
+//	syslog = '^(\w{3}...'
+//
+// or a table with further settings
+//
+//	[javalog]
+//	regex = '^(?P<timestamp>\d{4}-\d{2}-\d{2} ...'
+//	recordstart = '^\d{4}-\d{2}-\d{2} '
+//	timestamp = 'timestamp'
+//	layout = '2006-01-02 15:04:05.000'
+type Format struct {
+	Regex string `koanf:"regex"`
+	// lines not matching this regex are continuation lines (e.g. of a stack
+	// trace) and get grouped with the line before
+	RecordStart string `koanf:"recordstart"`
+	// name or number of the group holding the timestamp and its layout in
+	// Go's notation, "unix" or "unixms". Both get guessed if not given.
+	Timestamp string `koanf:"timestamp"`
+	Layout    string `koanf:"layout"`
+}
+
 // TODO error handling
 func readFormatsFile() {
 	formatsFile, err := xdg.ConfigFile(appName + formatsFileName)
@@ -23,6 +46,28 @@ func readFormatsFile() {
 	}
 	fail.OnError(err, "Loading formats file failed")
 
-	err = cm.kFormats.Unmarshal("", &cm.formats)
-	fail.OnError(err, "Error unmarshalling formats file")
+	for name, value := range cm.kFormats.Raw() {
+		format, err := unmarshalFormat(name, value)
+		fail.OnError(err, "Error unmarshalling formats file")
+		cm.formats[name] = format
+	}
+}
+
+func unmarshalFormat(name string, value any) (Format, error) {
+	switch value := value.(type) {
+	case string:
+		return Format{Regex: value}, nil
+	case map[string]any:
+		var format Format
+		err := cm.kFormats.Cut(name).Unmarshal("", &format)
+		if err != nil {
+			return format, err
+		}
+		if format.Regex == "" {
+			return format, fmt.Errorf("format %s has no regex", name)
+		}
+		return format, nil
+	default:
+		return Format{}, fmt.Errorf("format %s is neither a regex nor a table", name)
+	}
 }
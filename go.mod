@@ -7,9 +7,11 @@ toolchain go1.24.5
 require (
 	github.com/adrg/xdg v0.5.3
 	github.com/gdamore/tcell/v2 v2.8.1
+	github.com/klauspost/compress v1.18.0
 	github.com/knadh/koanf v1.5.0
 	github.com/knadh/koanf/v2 v2.2.2
 	github.com/markusmobius/go-dateparser v1.2.4
+	github.com/ulikunitz/xz v0.5.17
 )
 
 require (
@@ -63,10 +63,14 @@ func run() error {
 	wg.Add(1)
 	go window.MetaEventLoop(ctx, &wg, quit)
 
-	if cfg.Stdin {
+	if len(cfg.Command) > 0 {
+		fm.ReadFromCommand()
+	} else if cfg.Listen != "" {
+		fm.Listen(cfg.Listen)
+	} else if cfg.Stdin {
 		fm.ReadFromStdin()
 	} else {
-		fm.ReadFromFile(cfg.FilePath)
+		fm.ReadFromFiles(cfg.FilePaths)
 	}
 
 	wg.Add(1)
@@ -3,6 +3,7 @@ package model
 import (
 	"github.com/claude42/infiltrator/config"
 	"github.com/claude42/infiltrator/model/filter"
+	"github.com/claude42/infiltrator/model/templates"
 )
 
 type Command interface {
@@ -143,9 +144,105 @@ func (d CommandFilterKeyUpdate) commandString() string {
 	return "FilterKeyUpdate"
 }
 
+type CommandFilterOriginUpdate struct {
+	Filter filter.Filter
+	Origin string
+}
+
+func (d CommandFilterOriginUpdate) commandString() string {
+	return "FilterOriginUpdate"
+}
+
+type CommandFilterFieldUpdate struct {
+	Filter filter.Filter
+	Field  string
+}
+
+func (d CommandFilterFieldUpdate) commandString() string {
+	return "FilterFieldUpdate"
+}
+
+type CommandFilterContextUpdate struct {
+	Filter filter.Filter
+	Before int
+	After  int
+}
+
+func (d CommandFilterContextUpdate) commandString() string {
+	return "FilterContextUpdate"
+}
+
+type CommandFileTypeIdentified struct {
+}
+
+func (d CommandFileTypeIdentified) commandString() string {
+	return "FileTypeIdentified"
+}
+
+type CommandRestartCommand struct {
+}
+
+func (d CommandRestartCommand) commandString() string {
+	return "RestartCommand"
+}
+
 type CommandToggleFollowMode struct {
 }
 
 func (d CommandToggleFollowMode) commandString() string {
 	return "ToggleFollowMode"
 }
+
+type CommandCountFieldValues struct {
+	Field string
+}
+
+func (d CommandCountFieldValues) commandString() string {
+	return "CountFieldValues"
+}
+
+type CommandExpandRun struct {
+	Line int
+}
+
+func (d CommandExpandRun) commandString() string {
+	return "ExpandRun"
+}
+
+type CommandCollapseRuns struct {
+}
+
+func (d CommandCollapseRuns) commandString() string {
+	return "CollapseRuns"
+}
+
+type CommandMineTemplates struct {
+}
+
+func (d CommandMineTemplates) commandString() string {
+	return "MineTemplates"
+}
+
+type CommandTemplatesMined struct {
+	Index *templates.Index
+}
+
+func (d CommandTemplatesMined) commandString() string {
+	return "TemplatesMined"
+}
+
+type CommandTemplateSelectionUpdate struct {
+	Selection filter.TemplateSelection
+}
+
+func (d CommandTemplateSelectionUpdate) commandString() string {
+	return "TemplateSelectionUpdate"
+}
+
+type CommandJumpToLine struct {
+	Line int
+}
+
+func (d CommandJumpToLine) commandString() string {
+	return "JumpToLine"
+}
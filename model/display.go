@@ -73,19 +73,21 @@ func (d *Display) SetHeight(height int) {
 	}
 
 	// so height > currentHeight
-	d.Buffer = append(d.Buffer, make([]*lines.Line, height-currentHeight)...)
-
 	var lineNo int
-	if currentHeight > 0 && d.Buffer[currentHeight-1] != nil {
-		lineNo = d.Buffer[currentHeight-1].No + 1
+	if currentHeight > 0 && d.lastLine() != nil {
+		lineNo = d.lastLine().No + 1
 		if lineNo == 0 {
+			d.Buffer = append(d.Buffer, make([]*lines.Line, height-currentHeight)...)
 			d.fillRestOfBufferWithNonExistingLines(currentHeight - 1)
 			return
 		}
 	} else {
 		lineNo = 0
 	}
+	d.Buffer = append(d.Buffer, make([]*lines.Line, height-currentHeight)...)
+
 	// copied from refreshDisplay()
+	showsContext := GetFilterManager().filters.ShowsContext()
 	y := currentHeight
 	for y < height {
 		// log.Printf("doing line=%d", y)
@@ -98,8 +100,7 @@ func (d *Display) SetHeight(height int) {
 		}
 
 		if line.Status != lines.LineHidden {
-			d.Buffer[y] = line
-			y++
+			y = d.putLine(y, line, showsContext)
 		}
 	}
 
@@ -152,6 +153,7 @@ func (d *Display) refreshDisplay(ctx context.Context, wg *sync.WaitGroup,
 		return
 	}
 
+	showsContext := GetFilterManager().filters.ShowsContext()
 	y := 0
 	for y < displayHeight {
 		line, err := GetFilterManager().filters.GetLine(lineNo)
@@ -163,8 +165,7 @@ func (d *Display) refreshDisplay(ctx context.Context, wg *sync.WaitGroup,
 		}
 
 		if line.Status != lines.LineHidden {
-			d.Buffer[y] = line
-			y++
+			y = d.putLine(y, line, showsContext)
 		}
 		if ctx != nil {
 			select {
@@ -183,11 +184,43 @@ func (d *Display) refreshDisplay(ctx context.Context, wg *sync.WaitGroup,
 	config.PostEventFunc(NewEventDisplay(*d))
 }
 
+// does not lock! Puts line into row y, after a divider if the line above
+// isn't adjacent. Returns the row following the line.
+func (d *Display) putLine(y int, line *lines.Line, showsContext bool) int {
+	if showsContext && y > 0 && needsDivider(d.Buffer[y-1], line) {
+		d.Buffer[y] = lines.DividerLine
+		y++
+		if y >= len(d.Buffer) {
+			return y
+		}
+	}
+
+	d.Buffer[y] = line
+	return y + 1
+}
+
+// Dividers never need another divider.
+func needsDivider(above *lines.Line, below *lines.Line) bool {
+	return above.No >= 0 && below.No > above.No+1
+}
+
+// Dividers at the top or bottom of the screen belong to lines which are
+// off-screen, so they're skipped.
 func (d *Display) firstLine() *lines.Line {
+	for _, line := range d.Buffer {
+		if line == nil || line.Status != lines.LineDivider {
+			return line
+		}
+	}
 	return d.Buffer[0]
 }
 
 func (d *Display) lastLine() *lines.Line {
+	for y := len(d.Buffer) - 1; y >= 0; y-- {
+		if d.Buffer[y] == nil || d.Buffer[y].Status != lines.LineDivider {
+			return d.Buffer[y]
+		}
+	}
 	return d.Buffer[len(d.Buffer)-1]
 }
 
@@ -212,21 +245,36 @@ func (d *Display) isAffectedByNewContend() bool {
 	return d.lastLine().No == -1
 }
 
-func (d *Display) addLineAtBottomRemoveLineAtTop(line *lines.Line) {
-	if d.Height() > 0 {
-		d.Buffer = append(d.Buffer[1:], line)
-	} else {
+func (d *Display) addLineAtBottomRemoveLineAtTop(line *lines.Line,
+	showsContext bool) {
+
+	if d.Height() == 0 {
 		d.Buffer = []*lines.Line{line}
+		return
 	}
+
+	bottom := d.Buffer[d.Height()-1]
+	if showsContext && d.Height() > 1 && needsDivider(bottom, line) {
+		d.Buffer = append(d.Buffer[1:], lines.DividerLine)
+	}
+	d.Buffer = append(d.Buffer[1:], line)
 }
 
-func (d *Display) addLineAtTopRemoveLineAtBottom(line *lines.Line) {
-	if d.Height() > 0 {
-		d.Buffer = append([]*lines.Line{line},
-			d.Buffer[:d.Height()-1]...)
-	} else {
+func (d *Display) addLineAtTopRemoveLineAtBottom(line *lines.Line,
+	showsContext bool) {
+
+	if d.Height() == 0 {
 		d.Buffer = []*lines.Line{line}
+		return
+	}
+
+	top := d.Buffer[0]
+	if showsContext && d.Height() > 1 && needsDivider(line, top) {
+		d.Buffer = append([]*lines.Line{lines.DividerLine},
+			d.Buffer[:d.Height()-1]...)
 	}
+	d.Buffer = append([]*lines.Line{line},
+		d.Buffer[:d.Height()-1]...)
 }
 
 func (d *Display) getLineOnScreen(lineNo int) (int, error) {
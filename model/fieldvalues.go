@@ -0,0 +1,74 @@
+package model
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/busy"
+	"github.com/claude42/infiltrator/model/formats"
+	"github.com/claude42/infiltrator/util"
+)
+
+type FieldValue struct {
+	Value string
+	Count int
+}
+
+// Runs in its own Go routine as it has to look at each and every line.
+func (fm *FilterManager) countFieldValues(field string) {
+	source := fm.filters.Source()
+	length := source.Length()
+
+	counts := make(map[string]int)
+	for lineNo := range length {
+		if lineNo%10_000 == 0 {
+			select {
+			case <-fm.ctx.Done():
+				return
+			default:
+			}
+		}
+
+		busy.SpinWithFraction(lineNo, length)
+		text, err := source.Text(lineNo)
+		if err != nil {
+			break
+		}
+
+		start, end, ok := formats.FieldSpan(text, field)
+		if !ok || start == end {
+			continue
+		}
+		counts[text[start:end]]++
+	}
+
+	values := make([]FieldValue, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, FieldValue{Value: value, Count: count})
+	}
+	// most frequent first
+	slices.SortFunc(values, func(a, b FieldValue) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Value, b.Value)
+	})
+
+	config.PostEventFunc(NewEventFieldValues(field, values))
+}
+
+// Generated by FilterManager.CountFieldValues()
+
+type EventFieldValues struct {
+	util.EventImpl
+
+	Field  string
+	Values []FieldValue
+}
+
+func NewEventFieldValues(field string, values []FieldValue) *EventFieldValues {
+	ev := &EventFieldValues{Field: field, Values: values}
+	ev.EventImpl.SetEventNow()
+	return ev
+}
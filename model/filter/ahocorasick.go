@@ -0,0 +1,133 @@
+package filter
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Finds any number of keywords in a single pass over the input (Aho and
+// Corasick, "Efficient string matching: an aid to bibliographic search").
+// Works on bytes, case only gets folded for ASCII letters.
+type ahoCorasick struct {
+	nodes    []acNode
+	foldCase bool
+}
+
+type acNode struct {
+	children map[byte]int32
+	// longest proper suffix of this node which is also in the trie
+	fail int32
+	// length of the longest keyword ending in this node, either the node's
+	// own or one reachable through the fail links. 0 if there's none.
+	out int32
+}
+
+func newAhoCorasick(keywords []string, foldCase bool) *ahoCorasick {
+	ac := &ahoCorasick{
+		nodes:    []acNode{{children: make(map[byte]int32)}},
+		foldCase: foldCase,
+	}
+
+	for _, keyword := range keywords {
+		if keyword == "" {
+			continue
+		}
+
+		current := int32(0)
+		for i := 0; i < len(keyword); i++ {
+			c := ac.fold(keyword[i])
+			next, ok := ac.nodes[current].children[c]
+			if !ok {
+				next = int32(len(ac.nodes))
+				ac.nodes = append(ac.nodes, acNode{children: make(map[byte]int32)})
+				ac.nodes[current].children[c] = next
+			}
+			current = next
+		}
+		ac.nodes[current].out = int32(len(keyword))
+	}
+
+	ac.buildFailLinks()
+	return ac
+}
+
+// breadth first, so the fail links of shallower nodes are there already
+func (ac *ahoCorasick) buildFailLinks() {
+	var queue []int32
+	for _, child := range ac.nodes[0].children {
+		queue = append(queue, child)
+	}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		for c, child := range ac.nodes[node].children {
+			fail := ac.nodes[node].fail
+			for fail != 0 {
+				if _, ok := ac.nodes[fail].children[c]; ok {
+					break
+				}
+				fail = ac.nodes[fail].fail
+			}
+			if next, ok := ac.nodes[fail].children[c]; ok && next != child {
+				ac.nodes[child].fail = next
+			}
+
+			if ac.nodes[child].out == 0 {
+				ac.nodes[child].out = ac.nodes[ac.nodes[child].fail].out
+			}
+			queue = append(queue, child)
+		}
+	}
+}
+
+func (ac *ahoCorasick) fold(c byte) byte {
+	if ac.foldCase && c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// find returns the start and end of all non-overlapping keywords in input,
+// leftmost longest ones first.
+func (ac *ahoCorasick) find(input string) [][]int {
+	// the longest keyword ending at each position, shorter ones ending there
+	// are part of it anyways
+	var candidates [][]int
+	current := int32(0)
+	for i := 0; i < len(input); i++ {
+		c := ac.fold(input[i])
+		for {
+			if next, ok := ac.nodes[current].children[c]; ok {
+				current = next
+				break
+			}
+			if current == 0 {
+				break
+			}
+			current = ac.nodes[current].fail
+		}
+
+		if out := int(ac.nodes[current].out); out > 0 {
+			candidates = append(candidates, []int{i + 1 - out, i + 1})
+		}
+	}
+
+	slices.SortFunc(candidates, func(a, b []int) int {
+		if c := cmp.Compare(a[0], b[0]); c != 0 {
+			return c
+		}
+		return cmp.Compare(b[1], a[1])
+	})
+
+	var indeces [][]int
+	end := 0
+	for _, candidate := range candidates {
+		if candidate[0] >= end {
+			indeces = append(indeces, candidate)
+			end = candidate[1]
+		}
+	}
+	return indeces
+}
@@ -0,0 +1,41 @@
+package filter
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAhoCorasick(t *testing.T) {
+	tests := []struct {
+		name     string
+		keywords []string
+		input    string
+		foldCase bool
+		want     [][]int
+	}{
+		{"single", []string{"error"}, "an error occurred", false, [][]int{{3, 8}}},
+		{"none", []string{"error"}, "all good", false, nil},
+		{"no keywords", nil, "all good", false, nil},
+		{"empty keyword ignored", []string{""}, "all good", false, nil},
+		{"several", []string{"alice", "bob"}, "bob and alice", false, [][]int{{0, 3}, {8, 13}}},
+		{"repeated", []string{"ab"}, "abab", false, [][]int{{0, 2}, {2, 4}}},
+		{"longest wins", []string{"a", "ab", "abc"}, "abcd", false, [][]int{{0, 3}}},
+		{"leftmost wins", []string{"abc", "bcde"}, "abcde", false, [][]int{{0, 3}}},
+		{"suffix of another keyword", []string{"he", "she", "hers"}, "ushers", false, [][]int{{1, 4}}},
+		{"through fail link", []string{"abcd", "bc"}, "abcx", false, [][]int{{1, 3}}},
+		{"shorter inside failed longer", []string{"abcd", "b"}, "abce", false, [][]int{{1, 2}}},
+		{"case sensitive", []string{"Error"}, "error ERROR Error", false, [][]int{{12, 17}}},
+		{"fold case", []string{"Error"}, "error ERROR", true, [][]int{{0, 5}, {6, 11}}},
+		{"ip addresses", []string{"10.0.0.1", "10.0.0.12"}, "from 10.0.0.12 to 10.0.0.1", false, [][]int{{5, 14}, {18, 26}}},
+		{"utf-8", []string{"über"}, "Grüße über alles", false, [][]int{{8, 13}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newAhoCorasick(tt.keywords, tt.foldCase).find(tt.input)
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("find() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
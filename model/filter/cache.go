@@ -6,10 +6,16 @@ import (
 	"github.com/claude42/infiltrator/model/lines"
 )
 
+// big enough for everything on screen and then some, small enough so that
+// searching through a huge file doesn't keep all of it in memory
+const cachedLines = 100_000
+
 type Cache struct {
 	FilterImpl
 	sync.Mutex
 	lines map[int]*lines.Line
+	// used instead of lines once the cache is bounded
+	lru *lineLRU
 }
 
 func NewCache() *Cache {
@@ -19,10 +25,22 @@ func NewCache() *Cache {
 	return c
 }
 
+// Bound limits the number of cached lines, e.g. for huge files which are
+// read on demand.
+func (c *Cache) Bound() {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.lru == nil {
+		c.lru = newLineLRU(cachedLines)
+		c.lines = nil
+	}
+}
+
 func (c *Cache) GetLine(lineNo int) (*lines.Line, error) {
 	c.Lock()
 	defer c.Unlock()
-	line, ok := c.lines[lineNo]
+	line, ok := c.get(lineNo)
 	if ok {
 		return line, nil
 	}
@@ -31,14 +49,49 @@ func (c *Cache) GetLine(lineNo int) (*lines.Line, error) {
 	if err != nil {
 		return sourceLine, err
 	}
-	c.lines[lineNo] = sourceLine
+	c.put(lineNo, sourceLine)
 
 	return sourceLine, nil
 }
 
+// does not lock!
+func (c *Cache) get(lineNo int) (*lines.Line, bool) {
+	if c.lru != nil {
+		return c.lru.get(lineNo)
+	}
+	line, ok := c.lines[lineNo]
+	return line, ok
+}
+
+// does not lock!
+func (c *Cache) put(lineNo int, line *lines.Line) {
+	if c.lru != nil {
+		c.lru.put(lineNo, line)
+	} else {
+		c.lines[lineNo] = line
+	}
+}
+
+// InvalidateLines forgets the lines from (including) to to (excluding).
+func (c *Cache) InvalidateLines(from int, to int) {
+	c.Lock()
+	defer c.Unlock()
+
+	for lineNo := max(0, from); lineNo < to; lineNo++ {
+		if c.lru != nil {
+			c.lru.remove(lineNo)
+		} else {
+			delete(c.lines, lineNo)
+		}
+	}
+}
+
 func (c *Cache) Invalidate() {
 	c.Lock()
-	c.lines = nil
-	c.lines = make(map[int]*lines.Line)
+	if c.lru != nil {
+		c.lru.clear()
+	} else {
+		c.lines = make(map[int]*lines.Line)
+	}
 	c.Unlock()
 }
@@ -0,0 +1,505 @@
+package filter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/lines"
+	"github.com/claude42/infiltrator/util"
+)
+
+// names of the two keys of the command filter
+const (
+	CommandFilterCommand = config.FilterStringCommand
+	CommandFilterOutput  = "Output"
+)
+
+// what the command answers for each line
+const (
+	CommandVerdict   = "keep/drop"
+	CommandTransform = "transform"
+)
+
+var CommandOutputs = []string{CommandVerdict, CommandTransform}
+
+const (
+	// how long the command gets to answer a single line
+	commandTimeout = 2 * time.Second
+	// lines sent to the command at once, one round trip per line would take
+	// way too long
+	commandBatchSize = 256
+	// how long to wait for more output after the last answer of a batch,
+	// that's when a command printing more than one line per line shows
+	commandSettleTime = 20 * time.Millisecond
+	// how long the command gets to exit after being killed
+	commandWaitDelay = time.Second
+	// answers get forgotten once there are more
+	maxCommandAnswers = 100000
+	// longest answer the command may give
+	maxCommandAnswerSize = 1024 * 1024
+)
+
+// answers meaning the line gets dropped, everything else keeps it
+var dropAnswers = []string{"", "0", "false", "no", "drop", "null"}
+
+var (
+	errCommandTimeout = fmt.Errorf("no answer within %v, buffering or not "+
+		"printing exactly one line per line? (try jq --unbuffered, "+
+		"mawk -W interactive, fflush() in awk)", commandTimeout)
+	errCommandExtraOutput = errors.New("command printed more than one line " +
+		"for a line (try jq --compact-output)")
+	errCommandExited = errors.New("command exited")
+)
+
+// CommandFilter pipes lines through an external command, e.g. jq or awk,
+// which keeps running as long as the filter exists. The command gets a batch
+// of lines on stdin and has to answer each of them with exactly one line on
+// stdout, so it must not buffer its output (jq --unbuffered, fflush() in awk)
+// nor wait for more input (mawk -W interactive). Commands known to print
+// nothing for some lines, like grep, get rejected right away. Commands
+// printing more than one line for a line fail as soon as that shows.
+//
+// With CommandVerdict the answer decides whether the line stays, with
+// CommandTransform the answer replaces the line.
+//
+// If the command fails, times out or exits, lines pass unchanged until the
+// command gets changed. Errors get reported with EventCommandFilterStatus.
+type CommandFilter struct {
+	FilterImpl
+	sync.Mutex
+
+	command   string
+	transform bool
+
+	process *coprocess
+	failed  bool
+	// answers by line, lines repeat often enough
+	answers map[string]string
+
+	// lines which went through GetLine() or got asked about in a batch
+	seen lineMemo
+	// the batch goes in the direction lines get asked for
+	lastLineNo int
+}
+
+const lineSeen int8 = 1
+
+func NewCommandFilter() *CommandFilter {
+	return &CommandFilter{answers: make(map[string]string)}
+}
+
+// SetKey sets the command (name CommandFilterCommand) or what the command
+// answers (name CommandFilterOutput, either CommandVerdict or
+// CommandTransform). The command gets restarted in both cases.
+func (c *CommandFilter) SetKey(name string, key string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	switch name {
+	case CommandFilterCommand:
+		if key == c.command {
+			return nil
+		}
+		c.command = key
+	case CommandFilterOutput:
+		var transform bool
+		switch key {
+		case "", CommandVerdict:
+			transform = false
+		case CommandTransform:
+			transform = true
+		default:
+			return fmt.Errorf("unknown command output %s", key)
+		}
+		if transform == c.transform {
+			return nil
+		}
+		c.transform = transform
+	default:
+		log.Panicf("Neither command nor output but '%s'", name)
+	}
+
+	c.stop()
+	c.failed = false
+	clear(c.answers)
+	c.seen = nil
+
+	// clears any error shown for the previous command
+	err := checkAnswersEachLine(c.command)
+	if err != nil {
+		c.failed = true
+		log.Printf("command filter %q rejected: %+v", c.command, err)
+	}
+	config.PostEventFunc(NewEventCommandFilterStatus(c.command, err))
+	return nil
+}
+
+// Close terminates the command, e.g. when the filter gets removed.
+func (c *CommandFilter) Close() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.stop()
+}
+
+func (c *CommandFilter) GetLine(lineNo int) (*lines.Line, error) {
+	sourceLine, err := c.source.GetLine(lineNo)
+	if err != nil {
+		return sourceLine, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.seen.set(lineNo, lineSeen)
+
+	if sourceLine.Status == lines.LineHidden || sourceLine.Marker ||
+		c.command == "" || c.failed {
+
+		return sourceLine, nil
+	}
+
+	answer, err := c.ask(lineNo, sourceLine.Str)
+	if err != nil {
+		c.fail(err)
+		return sourceLine, nil
+	}
+
+	if c.transform {
+		// the text belongs to the source and must not get changed
+		line := *sourceLine
+		line.Str = answer
+		line.ColorIndex = make([]uint8, len(answer))
+		return &line, nil
+	}
+
+	if isDropAnswer(answer) {
+		sourceLine.Status = lines.LineHidden
+		sourceLine.Matched = false
+	}
+	return sourceLine, nil
+}
+
+func isDropAnswer(answer string) bool {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	for _, drop := range dropAnswers {
+		if answer == drop {
+			return true
+		}
+	}
+	return false
+}
+
+// Commands printing nothing for some lines, like grep, can't be used: there
+// would be no telling which line an answer belongs to. Only catches the usual
+// suspects, everything else runs into the timeout.
+func checkAnswersEachLine(command string) error {
+	stages := strings.FieldsFunc(command, func(r rune) bool {
+		return r == '|' || r == ';' || r == '&'
+	})
+	for _, stage := range stages {
+		args := strings.Fields(stage)
+		if len(args) == 0 {
+			continue
+		}
+
+		name := filepath.Base(args[0])
+		var skipsLines bool
+		switch name {
+		case "grep", "egrep", "fgrep", "rg", "ag", "ack":
+			skipsLines = true
+		case "sed":
+			skipsLines = slices.ContainsFunc(args[1:], func(arg string) bool {
+				return arg == "-n" || arg == "--quiet" || arg == "--silent"
+			})
+		}
+
+		if skipsLines {
+			return fmt.Errorf("%s prints nothing for some lines, the command "+
+				"has to answer each line (try awk '{print /x/}')", name)
+		}
+	}
+	return nil
+}
+
+// does not lock! Lines following lineNo (or preceding it when scrolling up)
+// get asked about in the same batch.
+func (c *CommandFilter) ask(lineNo int, text string) (string, error) {
+	if answer, ok := c.answers[text]; ok {
+		return answer, nil
+	}
+
+	if c.process == nil {
+		process, err := startCoprocess(c.command)
+		if err != nil {
+			return "", err
+		}
+		c.process = process
+	}
+
+	texts := c.batch(lineNo, text)
+	answers, err := c.process.ask(texts)
+	if err != nil {
+		return "", err
+	}
+
+	if len(c.answers)+len(texts) > maxCommandAnswers {
+		clear(c.answers)
+	}
+	for i, answer := range answers {
+		c.answers[texts[i]] = answer
+	}
+	return answers[0], nil
+}
+
+// does not lock! Texts of text and the lines around lineNo which will be
+// asked for next and haven't been answered yet. Lines which went through
+// GetLine() already aren't looked at again, they might be cached.
+func (c *CommandFilter) batch(lineNo int, text string) []string {
+	direction := 1
+	if lineNo < c.lastLineNo {
+		direction = -1
+	}
+	c.lastLineNo = lineNo
+
+	texts := []string{text}
+	batched := map[string]bool{text: true}
+	length := c.source.Length()
+	for i := lineNo + direction; i >= 0 && i < length &&
+		len(texts) < commandBatchSize; i += direction {
+
+		if c.seen.get(i) == lineSeen {
+			continue
+		}
+		c.seen.set(i, lineSeen)
+
+		line, err := c.source.GetLine(i)
+		if err != nil {
+			break
+		}
+		if line.Status == lines.LineHidden || line.Marker {
+			continue
+		}
+		if _, ok := c.answers[line.Str]; ok || batched[line.Str] {
+			continue
+		}
+		texts = append(texts, line.Str)
+		batched[line.Str] = true
+	}
+	return texts
+}
+
+// The lines asked about in a batch went through GetLine() of the filters
+// before this one, so they must be forgotten together with them.
+func (c *CommandFilter) forget(from int, to int) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.seen.forget(from, to)
+}
+
+// does not lock!
+func (c *CommandFilter) fail(err error) {
+	if c.process != nil {
+		c.process.kill()
+		if errors.Is(err, errCommandExited) {
+			err = c.process.exitError()
+		}
+		c.process = nil
+	}
+	c.failed = true
+
+	log.Printf("command filter %q failed: %+v", c.command, err)
+	config.PostEventFunc(NewEventCommandFilterStatus(c.command, err))
+}
+
+// does not lock!
+func (c *CommandFilter) stop() {
+	if c.process == nil {
+		return
+	}
+	c.process.kill()
+	c.process = nil
+}
+
+type coprocess struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	answers chan string
+	// lines written but not answered yet, an answer without a line means the
+	// command prints more than one line per line
+	pending atomic.Int64
+	// why answers got closed early, set before closing
+	readErr error
+	// closed once the command gets killed, nobody reads answers anymore
+	done    chan struct{}
+	stderr  bytes.Buffer
+	killed  bool
+	waitErr error
+}
+
+func startCoprocess(command string) (*coprocess, error) {
+	p := &coprocess{
+		answers: make(chan string),
+		done:    make(chan struct{}),
+	}
+
+	p.cmd = exec.Command("sh", "-c", command)
+	setProcessGroup(p.cmd)
+	p.cmd.WaitDelay = commandWaitDelay
+	p.cmd.Stderr = &p.stderr
+
+	var err error
+	p.stdin, err = p.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	go p.readAnswers(stdout)
+	return p, nil
+}
+
+func (p *coprocess) readAnswers(stdout io.Reader) {
+	defer close(p.answers)
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(nil, maxCommandAnswerSize)
+	for scanner.Scan() {
+		if p.pending.Add(-1) < 0 {
+			p.readErr = errCommandExtraOutput
+			return
+		}
+		select {
+		case p.answers <- scanner.Text():
+		case <-p.done:
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("error reading output of command: %+v", err)
+	}
+}
+
+// Returns an answer for each of texts. The timeout covers writing as well, a
+// command not reading its input would block the write otherwise. Once the
+// command gets killed, the write returns.
+func (p *coprocess) ask(texts []string) ([]string, error) {
+	p.pending.Add(int64(len(texts)))
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := io.WriteString(p.stdin, strings.Join(texts, "\n")+"\n")
+		written <- err
+	}()
+
+	timer := time.NewTimer(commandTimeout)
+	defer timer.Stop()
+
+	// set to nil once done, receiving from nil channels blocks
+	answers := make([]string, 0, len(texts))
+	answerCh := p.answers
+	for answerCh != nil || written != nil {
+		select {
+		case err := <-written:
+			if err != nil {
+				// most likely the command has exited already
+				return nil, fmt.Errorf("%w: %w", errCommandExited, err)
+			}
+			written = nil
+		case answer, ok := <-answerCh:
+			if !ok {
+				if p.readErr != nil {
+					return nil, p.readErr
+				}
+				return nil, errCommandExited
+			}
+			answers = append(answers, answer)
+			if len(answers) == len(texts) {
+				answerCh = nil
+			}
+			timer.Reset(commandTimeout)
+		case <-timer.C:
+			return nil, errCommandTimeout
+		}
+	}
+
+	// readAnswers() closes answers on extra output
+	select {
+	case <-p.answers:
+		if p.readErr != nil {
+			return nil, p.readErr
+		}
+		return nil, errCommandExited
+	case <-time.After(commandSettleTime):
+	}
+	return answers, nil
+}
+
+func (p *coprocess) kill() {
+	if p.killed {
+		return
+	}
+	p.killed = true
+
+	close(p.done)
+	p.stdin.Close()
+	if err := killProcess(p.cmd); err != nil {
+		log.Printf("error killing command: %+v", err)
+	}
+	p.waitErr = p.cmd.Wait()
+}
+
+// Only after kill(). Uses the last line the command wrote to stderr as error
+// message if there is one.
+func (p *coprocess) exitError() error {
+	var stderr string
+	if lastLines := strings.Split(strings.TrimSpace(p.stderr.String()), "\n"); len(lastLines) > 0 {
+		stderr = lastLines[len(lastLines)-1]
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case stderr != "":
+		return errors.New(stderr)
+	case errors.As(p.waitErr, &exitErr):
+		return fmt.Errorf("command exited with status %d", exitErr.ExitCode())
+	default:
+		return errCommandExited
+	}
+}
+
+// Created by a CommandFilter whenever its command fails, Err is nil once the
+// command got changed.
+
+type EventCommandFilterStatus struct {
+	util.EventImpl
+
+	Command string
+	Err     error
+}
+
+func NewEventCommandFilterStatus(command string, err error) *EventCommandFilterStatus {
+	ev := &EventCommandFilterStatus{Command: command, Err: err}
+	ev.EventImpl.SetEventNow()
+	return ev
+}
@@ -0,0 +1,138 @@
+package filter
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/util"
+)
+
+func TestCheckAnswersEachLine(t *testing.T) {
+	tests := []struct {
+		command string
+		wantErr bool
+	}{
+		{command: "", wantErr: false},
+		{command: "jq --unbuffered .level", wantErr: false},
+		{command: `jq --unbuffered '.level == "error"'`, wantErr: false},
+		{command: "jq --unbuffered '.a // empty'", wantErr: false},
+		{command: "jq 'if .x then . else empty end'", wantErr: false},
+		{command: "grep error", wantErr: true},
+		{command: "/usr/bin/grep --line-buffered error", wantErr: true},
+		{command: "cut -d' ' -f1 | grep x", wantErr: true},
+		{command: "sed -u s/a/b/", wantErr: false},
+		{command: "sed -n s/a/b/p", wantErr: true},
+		{command: "sed --quiet s/a/b/p", wantErr: true},
+		{command: "sed -un s/a/b/", wantErr: false},
+		{command: "sed -E s/n/m/", wantErr: false},
+		{command: "awk '{print /x/; fflush()}'", wantErr: false},
+		{command: "mawk -W interactive 'BEGIN {n = 0} {print $1}'", wantErr: false},
+		{command: "tr a-z A-Z", wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			err := checkAnswersEachLine(tt.command)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkAnswersEachLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCoprocess(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+
+	// unlike most tools, the shell doesn't buffer
+	p, err := startCoprocess(`while IFS= read -r line; do echo "<$line>"; done`)
+	if err != nil {
+		t.Fatalf("startCoprocess() error = %v", err)
+	}
+	defer p.kill()
+
+	for _, texts := range [][]string{{"hello"}, {"a", "b", "c"}} {
+		answers, err := p.ask(texts)
+		if err != nil {
+			t.Fatalf("ask(%q) error = %v", texts, err)
+		}
+		for i, text := range texts {
+			if want := "<" + text + ">"; answers[i] != want {
+				t.Errorf("ask(%q)[%d] = %q, want %q", texts, i, answers[i], want)
+			}
+		}
+	}
+}
+
+func TestCoprocessExtraOutput(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+
+	p, err := startCoprocess(`while IFS= read -r line; do echo "$line"; echo "$line"; done`)
+	if err != nil {
+		t.Fatalf("startCoprocess() error = %v", err)
+	}
+	defer p.kill()
+
+	_, err = p.ask([]string{"x"})
+	if !errors.Is(err, errCommandExtraOutput) {
+		t.Errorf("ask() error = %v, want %v", err, errCommandExtraOutput)
+	}
+}
+
+func TestCommandFilterBatches(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+
+	config.PostEventFunc = func(ev util.Event) error { return nil }
+
+	texts := []string{"a", "b", "a", "c"}
+	source := newTestSource(texts)
+	c := NewCommandFilter()
+	c.SetSource(source)
+	if err := c.SetKey(CommandFilterOutput, CommandTransform); err != nil {
+		t.Fatal(err)
+	}
+	// counts the lines it got, so all lines being answered by the first
+	// round trip shows in the answers
+	if err := c.SetKey(CommandFilterCommand,
+		`n=0; while IFS= read -r line; do n=$((n+1)); echo "$n$line"; done`); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	want := []string{"1a", "2b", "1a", "3c"}
+	for i := range texts {
+		line, err := c.GetLine(i)
+		if err != nil {
+			t.Fatalf("GetLine(%d) error = %v", i, err)
+		}
+		if line.Str != want[i] {
+			t.Errorf("GetLine(%d) = %q, want %q", i, line.Str, want[i])
+		}
+	}
+}
+
+func TestCoprocessNotReading(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+
+	p, err := startCoprocess("sleep 10")
+	if err != nil {
+		t.Fatalf("startCoprocess() error = %v", err)
+	}
+	defer p.kill()
+
+	// more than fits into the pipe, so writing blocks
+	_, err = p.ask([]string{strings.Repeat("x", 1024*1024)})
+	if !errors.Is(err, errCommandTimeout) {
+		t.Errorf("ask() error = %v, want %v", err, errCommandTimeout)
+	}
+}
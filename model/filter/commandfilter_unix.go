@@ -0,0 +1,24 @@
+//go:build !windows
+
+package filter
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// The command gets a process group of its own, so the whole pipeline gets
+// killed and not only the shell.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcess(cmd *exec.Cmd) error {
+	// negative pid: the whole process group
+	err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	if errors.Is(err, syscall.ESRCH) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,230 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/claude42/infiltrator/model/formats"
+)
+
+// Comparisons look like "status >= 500", "latency_ms > 250ms" or
+// "size > 1MB". The field may be left out if the filter is restricted to a
+// field anyways. Values may have a unit, either a duration (ns, us, ms, s, m,
+// h, d or e.g. 1h30m) or a size (B, KB, MB, GB, TB or KiB, MiB, GiB, TiB).
+//
+// Numbers without a unit in the line are bytes when comparing sizes. When
+// comparing durations they're in the unit the field name ends in (e.g.
+// latency_ms) or, if it doesn't, in seconds.
+//
+// Values which aren't numbers can only be compared for (in)equality.
+
+var comparisonRegex = regexp.MustCompile(`^\s*([^<>=!\s]*)\s*(==|!=|<=|>=|=|<|>)\s*(.*?)\s*$`)
+
+var quantityRegex = regexp.MustCompile(`^([-+]?(?:\d+\.?\d*|\.\d+))\s*([a-zA-Zµ]*)$`)
+
+type unitKind int
+
+const (
+	unitNone unitKind = iota
+	unitDuration
+	unitSize
+)
+
+type unit struct {
+	kind unitKind
+	// in seconds or bytes
+	factor float64
+}
+
+var units = map[string]unit{
+	"ns":  {unitDuration, 1e-9},
+	"us":  {unitDuration, 1e-6},
+	"µs":  {unitDuration, 1e-6},
+	"ms":  {unitDuration, 1e-3},
+	"s":   {unitDuration, 1},
+	"sec": {unitDuration, 1},
+	"m":   {unitDuration, 60},
+	"min": {unitDuration, 60},
+	"h":   {unitDuration, 3600},
+	"d":   {unitDuration, 86400},
+	"b":   {unitSize, 1},
+	"kb":  {unitSize, 1e3},
+	"mb":  {unitSize, 1e6},
+	"gb":  {unitSize, 1e9},
+	"tb":  {unitSize, 1e12},
+	"kib": {unitSize, 1 << 10},
+	"mib": {unitSize, 1 << 20},
+	"gib": {unitSize, 1 << 30},
+	"tib": {unitSize, 1 << 40},
+}
+
+var ErrComparison = errors.New("invalid comparison")
+
+type comparison struct {
+	field    string
+	operator string
+
+	// for values which aren't numbers
+	text          string
+	caseSensitive bool
+
+	numeric bool
+	number  float64
+	unit    unit
+}
+
+func CompareFilterFuncFactory(key string, caseSensitive bool) (func(input string) (string, [][]int, bool), error) {
+	c, err := parseComparison(key, caseSensitive)
+	if err != nil {
+		return nil, err
+	}
+
+	// The whole value of the field gets highlighted
+	return func(input string) (string, [][]int, bool) {
+		if c == nil {
+			return input, nil, true
+		}
+
+		start, end := 0, len(input)
+		if c.field != "" {
+			var ok bool
+			start, end, ok = formats.FieldSpan(input, c.field)
+			if !ok {
+				return "", nil, false
+			}
+		}
+
+		if !c.matches(input[start:end]) {
+			return "", nil, false
+		}
+		return input, [][]int{{start, end}}, true
+	}, nil
+}
+
+// parseComparison parses key. An empty key results in nil, which matches
+// everything.
+func parseComparison(key string, caseSensitive bool) (*comparison, error) {
+	if strings.TrimSpace(key) == "" {
+		return nil, nil
+	}
+
+	parts := comparisonRegex.FindStringSubmatch(key)
+	if parts == nil {
+		return nil, fmt.Errorf("%w: missing operator", ErrComparison)
+	}
+
+	c := &comparison{
+		operator:      parts[2],
+		text:          parts[3],
+		caseSensitive: caseSensitive,
+	}
+
+	if parts[1] != "" {
+		c.field = formats.FieldName(parts[1])
+		if c.field == "" {
+			return nil, fmt.Errorf("%w: unknown field %s", ErrComparison, parts[1])
+		}
+	}
+
+	if c.text == "" {
+		return nil, fmt.Errorf("%w: missing value", ErrComparison)
+	}
+
+	c.number, c.unit, c.numeric = parseQuantity(c.text)
+	if !c.numeric && c.operator != "=" && c.operator != "==" && c.operator != "!=" {
+		return nil, fmt.Errorf("%w: %s is not a number", ErrComparison, c.text)
+	}
+
+	// plain numbers in the line are in the unit of the field name, if it has
+	// one, otherwise in seconds
+	if c.unit.kind == unitDuration {
+		c.unit = unit{unitDuration, 1}
+		if fieldUnit, ok := unitOfFieldName(c.field); ok && fieldUnit.kind == unitDuration {
+			c.unit = fieldUnit
+		}
+	}
+
+	return c, nil
+}
+
+func (c *comparison) matches(value string) bool {
+	value = strings.TrimSpace(value)
+
+	if !c.numeric {
+		equal := value == c.text ||
+			(!c.caseSensitive && strings.EqualFold(value, c.text))
+		return equal == (c.operator != "!=")
+	}
+
+	number, valueUnit, ok := parseQuantity(value)
+	if !ok {
+		return false
+	}
+
+	switch {
+	case valueUnit.kind == unitNone && c.unit.kind == unitDuration:
+		number *= c.unit.factor
+	case valueUnit.kind != unitNone && c.unit.kind != unitNone && valueUnit.kind != c.unit.kind:
+		return false
+	}
+
+	switch c.operator {
+	case "=", "==":
+		return number == c.number
+	case "!=":
+		return number != c.number
+	case "<":
+		return number < c.number
+	case "<=":
+		return number <= c.number
+	case ">":
+		return number > c.number
+	case ">=":
+		return number >= c.number
+	}
+
+	return false
+}
+
+// Returns the number in seconds or bytes, and the unit it was given in.
+// Numbers without a unit are returned as they are.
+func parseQuantity(str string) (float64, unit, bool) {
+	parts := quantityRegex.FindStringSubmatch(str)
+	if parts == nil {
+		// e.g. 1h30m
+		duration, err := time.ParseDuration(str)
+		if err != nil {
+			return 0, unit{}, false
+		}
+		return duration.Seconds(), unit{unitDuration, 1}, true
+	}
+
+	number, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return 0, unit{}, false
+	}
+
+	if parts[2] == "" {
+		return number, unit{unitNone, 1}, true
+	}
+
+	u, ok := units[strings.ToLower(parts[2])]
+	if !ok {
+		return 0, unit{}, false
+	}
+	return number * u.factor, u, true
+}
+
+// e.g. latency_ms or .req.duration_s
+func unitOfFieldName(field string) (unit, bool) {
+	i := strings.LastIndexAny(field, "_.")
+	if i < 0 {
+		return unit{}, false
+	}
+	u, ok := units[strings.ToLower(field[i+1:])]
+	return u, ok
+}
@@ -0,0 +1,108 @@
+package filter
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/formats"
+)
+
+func TestParseComparison(t *testing.T) {
+	config.User().FileFormatFields = []string{"status", "latency", "latency_ms", "size", "level"}
+	defer func() { config.User().FileFormatFields = nil }()
+
+	tests := []struct {
+		key     string
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{key: "status >= 500", value: "503", want: true},
+		{key: "status >= 500", value: "404", want: false},
+		{key: "status>=500", value: "500", want: true},
+		{key: "status != 200", value: "200", want: false},
+		{key: "status < 1.5", value: ".5", want: true},
+		{key: "level = error", value: "ERROR", want: true},
+		{key: "level == error", value: "warn", want: false},
+		{key: "level != error", value: "warn", want: true},
+
+		// durations
+		{key: "latency > 2s", value: "2500ms", want: true},
+		{key: "latency > 2s", value: "1h30m", want: true},
+		{key: "latency > 2s", value: "1.5s", want: false},
+		{key: "latency > 2s", value: "3", want: true},
+		{key: "latency > 2ms", value: "1", want: true},
+		{key: "latency > 1m", value: "59", want: false},
+		{key: "latency_ms > 250ms", value: "300", want: true},
+		{key: "latency_ms > 1s", value: "999", want: false},
+		{key: "latency > 2s", value: "3MB", want: false},
+
+		// sizes
+		{key: "size > 1MB", value: "2000000", want: true},
+		{key: "size > 1MB", value: "1MiB", want: true},
+		{key: "size <= 1KiB", value: "1kb", want: true},
+
+		// no field, e.g. when the filter is restricted to one
+		{key: "> 10", value: "11", want: true},
+		{key: "", value: "anything", want: true},
+
+		{key: "status", wantErr: true},
+		{key: "status >", wantErr: true},
+		{key: "unknown > 1", wantErr: true},
+		{key: "level > error", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+" "+tt.value, func(t *testing.T) {
+			c, err := parseComparison(tt.key, false)
+			if tt.wantErr {
+				if !errors.Is(err, ErrComparison) {
+					t.Errorf("parseComparison() error = %v, want ErrComparison", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseComparison() error = %v", err)
+			}
+
+			got := c == nil || c.matches(tt.value)
+			if got != tt.want {
+				t.Errorf("%q matches %q = %v, want %v", tt.key, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// A comparison of a preset with a field unknown so far doesn't filter
+// anything, until the file type got identified.
+func TestCompareFilterReparse(t *testing.T) {
+	defer func() {
+		config.User().FileFormat = ""
+		config.User().FileFormatFields = nil
+	}()
+
+	texts := []string{"status=200", "status=503"}
+	source := newTestSource(texts)
+	compare := NewStringFilter(CompareFilterFuncFactory, config.FilterMatch)
+	pp := &Pipeline{}
+	pp.Add(source)
+	pp.Add(compare)
+
+	if err := compare.SetKey("", "status >= 500"); !errors.Is(err, ErrComparison) {
+		t.Fatalf("SetKey() error = %v, want %v", err, ErrComparison)
+	}
+	if got := shownLines(t, compare, len(texts)); !slices.Equal(got, texts) {
+		t.Errorf("shown lines = %q, want %q", got, texts)
+	}
+
+	config.User().FileFormat = formats.FormatLogfmt
+	config.User().FileFormatFields = []string{"status"}
+	pp.ReparseKeys()
+
+	want := []string{"status=503"}
+	if got := shownLines(t, compare, len(texts)); !slices.Equal(got, want) {
+		t.Errorf("shown lines = %q, want %q", got, want)
+	}
+}
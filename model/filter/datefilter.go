@@ -4,9 +4,12 @@ import (
 	"log"
 	"math"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/claude42/infiltrator/fail"
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/formats"
 	"github.com/claude42/infiltrator/model/lines"
 
 	dateparser "github.com/markusmobius/go-dateparser"
@@ -17,10 +20,38 @@ const (
 	DateFilterTo   = "To"
 )
 
+// how far to look back for a line with a timestamp
+const maxInheritLines = 1000
+
+// at most this many lines get looked at to find out if the file is sorted
+const sortedSamples = 10_000
+
 type DateFilter struct {
 	FilterImpl
+	// the filter manager reevaluates the keys while the display gets refreshed
+	sync.Mutex
 	fromLineNo int
 	toLineNo   int
+
+	// Keys get parsed again whenever new lines arrive and once a second, so
+	// relative keys like "-5m" or "last 5m" slide along with the current time.
+	fromKey string
+	toKey   string
+
+	// If timestamps aren't in order (e.g. merged container output or clock
+	// jumps), a binary search would hide the wrong lines. In this case each
+	// line gets checked against fromTime and toTime on its own instead.
+	perLine  bool
+	fromTime time.Time
+	toTime   time.Time
+	// time of each line, continuation lines and the like inherit the time of
+	// the line before
+	times []time.Time
+	// number of lines when the order of timestamps was last checked
+	checkedLength int
+
+	fileFormat string
+	timeParser *formats.TimeParser
 }
 
 func NewDateFilter() *DateFilter {
@@ -32,28 +63,126 @@ func NewDateFilter() *DateFilter {
 }
 
 func (d *DateFilter) SetKey(name string, key string) error {
-	keyTime, err := dateparser.Parse(nil, key)
-	if err != nil {
-		// TODO: error handling
-		switch name {
-		case DateFilterFrom:
-			d.fromLineNo = 0
-		case DateFilterTo:
-			d.toLineNo = math.MaxInt
-		}
-		return err
-	}
+	d.Lock()
+	defer d.Unlock()
 
 	switch name {
 	case DateFilterFrom:
-		d.fromLineNo = d.findFirstAfter(keyTime.Time)
+		d.fromKey = key
 	case DateFilterTo:
-		d.toLineNo = d.findLastBefore(keyTime.Time)
+		d.toKey = key
 	default:
 		log.Panicf("Neither from nor to but '%s'", name)
 	}
 
-	return nil
+	d.updateTimeParser()
+	d.checkOrder()
+
+	_, err := d.evaluate(name)
+	return err
+}
+
+// Reevaluate catches up with lines added since the keys were set and with the
+// current time. Returns true if lines might be hidden or shown differently
+// now.
+func (d *DateFilter) Reevaluate() bool {
+	d.Lock()
+	defer d.Unlock()
+
+	perLine := d.perLine
+	changed := d.updateTimeParser()
+	d.checkOrder()
+	changed = changed || perLine != d.perLine
+
+	for _, name := range []string{DateFilterFrom, DateFilterTo} {
+		keyChanged, _ := d.evaluate(name)
+		changed = changed || keyChanged
+	}
+
+	return changed
+}
+
+// the format of the file might not have been known when the filter was
+// created
+func (d *DateFilter) updateTimeParser() bool {
+	if d.timeParser != nil && d.fileFormat == config.User().FileFormat {
+		return false
+	}
+
+	d.fileFormat = config.User().FileFormat
+	d.timeParser = formats.NewFileTimeParser()
+	d.times = nil
+	d.checkedLength = 0
+	d.perLine = false
+	return true
+}
+
+// Only lines added since the last check get looked at. Once out of order,
+// timestamps stay out of order.
+func (d *DateFilter) checkOrder() {
+	length := d.source.Length()
+	if d.perLine || length == d.checkedLength {
+		return
+	}
+
+	d.perLine = !d.isSorted(max(0, d.checkedLength-1), length)
+	d.checkedLength = length
+	if d.perLine {
+		log.Printf("timestamps aren't sorted, checking each line on its own")
+	}
+}
+
+// Parses the key of the from or to input and updates the bounds accordingly.
+// Returns true if they changed. Relative keys like "-5m" give a new time on
+// each call, that only matters if each line gets checked on its own.
+func (d *DateFilter) evaluate(name string) (bool, error) {
+	key := d.fromKey
+	if name == DateFilterTo {
+		key = d.toKey
+	}
+
+	// TODO: error handling, for now an invalid key is the same as none
+	keyTime, err := parseDateKey(key)
+
+	switch name {
+	case DateFilterFrom:
+		fromLineNo := 0
+		if !keyTime.IsZero() {
+			fromLineNo = d.findFirstAfter(keyTime)
+		}
+		changed := fromLineNo != d.fromLineNo ||
+			(d.perLine && !keyTime.Equal(d.fromTime))
+		d.fromTime, d.fromLineNo = keyTime, fromLineNo
+		return changed, err
+	default:
+		toLineNo := math.MaxInt
+		if !keyTime.IsZero() {
+			toLineNo = d.findLastBefore(keyTime)
+		}
+		changed := toLineNo != d.toLineNo ||
+			(d.perLine && !keyTime.Equal(d.toTime))
+		d.toTime, d.toLineNo = keyTime, toLineNo
+		return changed, err
+	}
+}
+
+// Relative dates like "-1h" and dates without a zone are meant in the
+// timezone of the log. "last 5m" is the same as "5m ago".
+func parseDateKey(key string) (time.Time, error) {
+	if duration, ok := strings.CutPrefix(strings.ToLower(key), "last "); ok {
+		key = duration + " ago"
+	}
+
+	location := config.User().Location
+	keyTime, err := dateparser.Parse(&dateparser.Configuration{
+		DefaultTimezone: location,
+		CurrentTime:     time.Now().In(location),
+	}, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return keyTime.Time, nil
 }
 
 func (d *DateFilter) GetLine(lineNo int) (*lines.Line, error) {
@@ -62,7 +191,17 @@ func (d *DateFilter) GetLine(lineNo int) (*lines.Line, error) {
 		return sourceLine, err
 	}
 
-	if sourceLine.Status == lines.LineHidden {
+	if sourceLine.Status == lines.LineHidden || sourceLine.Marker {
+		return sourceLine, nil
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.perLine {
+		if !d.inRange(d.lineTime(sourceLine.No)) {
+			sourceLine.Status = lines.LineHidden
+		}
 		return sourceLine, nil
 	}
 
@@ -77,50 +216,92 @@ func (d *DateFilter) GetLine(lineNo int) (*lines.Line, error) {
 	return sourceLine, nil
 }
 
+// same bounds as the binary search, i.e. fromTime itself is excluded
+func (d *DateFilter) inRange(lineTime time.Time) bool {
+	if !d.fromTime.IsZero() && !d.fromTime.Before(lineTime) {
+		return false
+	}
+	return d.toTime.IsZero() || !lineTime.After(d.toTime)
+}
+
+// Only looks at a sample of lines for big files, so a few lines out of order
+// might go unnoticed.
+func (d *DateFilter) isSorted(start int, length int) bool {
+	step := max(1, (length-start)/sortedSamples)
+
+	var previous time.Time
+	for lineNo := start; lineNo < length; lineNo += step {
+		lineTime := d.getDateForLineNo(lineNo)
+		if lineTime.IsZero() {
+			continue
+		}
+		if lineTime.Before(previous) {
+			return false
+		}
+		previous = lineTime
+	}
+
+	return true
+}
+
+// lineTime returns the time of lineNo from the index, which gets extended up
+// to lineNo if necessary. Lines only ever get appended so the index stays
+// valid.
+func (d *DateFilter) lineTime(lineNo int) time.Time {
+	for i := len(d.times); i <= lineNo; i++ {
+		record, pos, err := d.source.Record(i)
+		if err != nil {
+			log.Printf("error reading line %d: %+v", i, err)
+			return time.Time{}
+		}
+
+		var lineTime time.Time
+		if i > 0 {
+			lineTime = d.times[i-1]
+		}
+		if pos == 0 {
+			if t, ok := d.timeParser.Parse(record[0]); ok {
+				lineTime = t
+			}
+		}
+		d.times = append(d.times, lineTime)
+	}
+
+	return d.times[lineNo]
+}
+
 func (d *DateFilter) findFirstAfter(fromTime time.Time) int {
 	lineNo := sort.Search(d.Length(), func(lineNo int) bool {
-		lineTime, err := d.getDateForLineNo(lineNo)
-		// TODO error handling
-		fail.OnError(err, "Paaanik")
-		return fromTime.Before(lineTime)
+		return fromTime.Before(d.getDateForLineNo(lineNo))
 	})
-	// TODO error handling
 	return lineNo
 }
 
 func (d *DateFilter) findLastBefore(toTime time.Time) int {
 	lineNo := sort.Search(d.source.Length(), func(lineNo int) bool {
-		lineTime, err := d.getDateForLineNo(lineNo)
-		// TODO error handling
-		fail.OnError(err, "Paaanik")
-		return lineTime.After(toTime)
+		return d.getDateForLineNo(lineNo).After(toTime)
 	})
 	return lineNo - 1
 }
 
-func (d *DateFilter) calculateLineDate(line *lines.Line) (time.Time, error) {
-	if !line.When.IsZero() {
-		return line.When, nil
-	}
-
-	// x, results, err := dateparser.Search(nil, line.Str)
-	lineTime, err := dateparser.Parse(nil, line.Str[:15], "Jan 02 03:04:05")
-	if err != nil {
-		// TODO: error handling
-		return time.Time{}, err
-	}
+// Lines without a timestamp (e.g. continuation lines of multi-line records)
+// inherit the time of the line before. If there's none at all, the zero time
+// is returned.
+func (d *DateFilter) getDateForLineNo(lineNo int) time.Time {
+	for i := lineNo; i >= 0 && lineNo-i < maxInheritLines; i-- {
+		record, pos, err := d.source.Record(i)
+		if err != nil {
+			log.Printf("error reading line %d: %+v", i, err)
+			return time.Time{}
+		}
 
-	// line.When = results[0].Date.Time
-	line.When = lineTime.Time
-	return lineTime.Time, nil
-}
+		if lineTime, ok := d.timeParser.Parse(record[0]); ok {
+			return lineTime
+		}
 
-func (d *DateFilter) getDateForLineNo(lineNo int) (time.Time, error) {
-	line, err := d.GetLine(lineNo)
-	if err != nil {
-		// TODO: error handling
-		return time.Time{}, err
+		// no need to look at the rest of the record again
+		i -= pos
 	}
 
-	return d.calculateLineDate(line)
+	return time.Time{}
 }
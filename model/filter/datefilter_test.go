@@ -0,0 +1,255 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+// Timestamps at the beginning of lines of an unknown format in UTC.
+func useUnknownFormatInUTC(t *testing.T) {
+	t.Helper()
+
+	user := config.User()
+	oldFormat, oldRegex, oldLocation := user.FileFormat, user.FileFormatRegex, user.Location
+	t.Cleanup(func() {
+		user.FileFormat, user.FileFormatRegex, user.Location = oldFormat, oldRegex, oldLocation
+	})
+	user.FileFormat, user.FileFormatRegex, user.Location = "", nil, time.UTC
+}
+
+func newDatePipeline(t *testing.T, texts []string, from string, to string) (*Pipeline, *DateFilter) {
+	t.Helper()
+
+	dateFilter := NewDateFilter()
+	pp := &Pipeline{}
+	pp.Add(newTestSource(texts))
+	pp.Add(NewCache())
+	pp.Add(dateFilter)
+
+	if err := dateFilter.SetKey(DateFilterFrom, from); err != nil && from != "" {
+		t.Fatalf("SetKey(From, %q) error = %v", from, err)
+	}
+	if err := dateFilter.SetKey(DateFilterTo, to); err != nil && to != "" {
+		t.Fatalf("SetKey(To, %q) error = %v", to, err)
+	}
+	return pp, dateFilter
+}
+
+// x = shown, - = hidden
+func visibility(t *testing.T, pp *Pipeline, length int) string {
+	t.Helper()
+
+	var sb strings.Builder
+	for i := range length {
+		line, err := pp.GetLine(i)
+		if err != nil {
+			t.Fatalf("GetLine(%d) error = %v", i, err)
+		}
+		if line.Status == lines.LineHidden {
+			sb.WriteString("-")
+		} else {
+			sb.WriteString("x")
+		}
+	}
+	return sb.String()
+}
+
+func TestDateFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		texts       []string
+		from        string
+		to          string
+		wantPerLine bool
+		want        string
+	}{
+		{
+			name: "sorted",
+			texts: []string{
+				"2024-03-05T10:00:00Z a",
+				"2024-03-05T10:01:00Z b",
+				"2024-03-05T10:02:00Z c",
+				"2024-03-05T10:03:00Z d",
+			},
+			from: "2024-03-05 10:00:30",
+			to:   "2024-03-05 10:02:00",
+			want: "-xx-",
+		},
+		{
+			name: "no keys",
+			texts: []string{
+				"2024-03-05T10:00:00Z a",
+				"2024-03-05T10:01:00Z b",
+			},
+			want: "xx",
+		},
+		{
+			name: "lines without timestamp inherit it",
+			texts: []string{
+				"2024-03-05T10:00:00Z a",
+				"  continued",
+				"2024-03-05T10:02:00Z c",
+				"  continued",
+			},
+			from: "2024-03-05 10:01:00",
+			want: "--xx",
+		},
+		{
+			name: "unsorted",
+			texts: []string{
+				"2024-03-05T10:03:00Z d",
+				"2024-03-05T10:00:00Z a",
+				"2024-03-05T10:02:00Z c",
+				"2024-03-05T10:01:00Z b",
+			},
+			from:        "2024-03-05 10:01:30",
+			wantPerLine: true,
+			want:        "x-x-",
+		},
+		{
+			name: "unsorted with continuation lines",
+			texts: []string{
+				"2024-03-05T10:03:00Z d",
+				"  continued",
+				"2024-03-05T10:00:00Z a",
+				"  continued",
+			},
+			to:          "2024-03-05 10:01:00",
+			wantPerLine: true,
+			want:        "--xx",
+		},
+	}
+
+	useUnknownFormatInUTC(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pp, dateFilter := newDatePipeline(t, tt.texts, tt.from, tt.to)
+
+			if dateFilter.perLine != tt.wantPerLine {
+				t.Errorf("perLine = %v, want %v", dateFilter.perLine, tt.wantPerLine)
+			}
+			if got := visibility(t, pp, len(tt.texts)); got != tt.want {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// New lines out of order switch over to checking each line on its own.
+func TestDateFilterBecomesUnsorted(t *testing.T) {
+	useUnknownFormatInUTC(t)
+
+	pp, dateFilter := newDatePipeline(t, []string{
+		"2024-03-05T10:00:00Z a",
+		"2024-03-05T10:02:00Z c",
+	}, "2024-03-05 10:01:00", "")
+	if dateFilter.perLine {
+		t.Fatalf("perLine = true for sorted lines")
+	}
+
+	pp.Source().StoreNewLines([]*lines.Line{
+		lines.NewLine(0, "2024-03-05T10:03:00Z d"),
+		lines.NewLine(0, "2024-03-05T09:00:00Z early"),
+	})
+	if !dateFilter.Reevaluate() {
+		t.Errorf("Reevaluate() = false, want true")
+	}
+	if !dateFilter.perLine {
+		t.Errorf("perLine = false after lines out of order")
+	}
+
+	pp.InvalidateCaches()
+	if got, want := visibility(t, pp, 4), "-xx-"; got != want {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
+
+func TestParseDateKey(t *testing.T) {
+	tests := []struct {
+		key string
+		// zero for absolute keys
+		ago     time.Duration
+		want    time.Time
+		wantErr bool
+	}{
+		{key: "last 5m", ago: 5 * time.Minute},
+		{key: "Last 2h", ago: 2 * time.Hour},
+		{key: "5 minutes ago", ago: 5 * time.Minute},
+		{key: "2024-03-05 10:00", want: time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)},
+		{key: "2024-03-05T10:00:00+02:00", want: time.Date(2024, 3, 5, 8, 0, 0, 0, time.UTC)},
+		{key: "no date at all", wantErr: true},
+	}
+
+	useUnknownFormatInUTC(t)
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			before := time.Now()
+			got, err := parseDateKey(tt.key)
+			after := time.Now()
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDateKey(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+			switch {
+			case tt.wantErr:
+			case tt.ago > 0:
+				// dateparser drops fractions of a second
+				earliest := before.Add(-tt.ago).Add(-time.Second)
+				if got.Before(earliest) || got.After(after.Add(-tt.ago)) {
+					t.Errorf("parseDateKey(%q) = %v, want about %v", tt.key, got, before.Add(-tt.ago))
+				}
+			case !got.Equal(tt.want):
+				t.Errorf("parseDateKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+// "last 5m" moves along with the current time.
+func TestDateFilterSlidingWindow(t *testing.T) {
+	useUnknownFormatInUTC(t)
+
+	now := time.Now().UTC()
+	stamp := func(ago time.Duration) string {
+		return now.Add(-ago).Format(time.RFC3339) + " line"
+	}
+	texts := []string{stamp(10 * time.Minute), stamp(3 * time.Minute), stamp(time.Minute)}
+
+	for _, tt := range []struct {
+		name string
+		// the first line is out of order for per-line checks
+		texts []string
+		want  string
+	}{
+		{name: "sorted", texts: texts, want: "-xx"},
+		{name: "unsorted", texts: []string{texts[1], texts[0], texts[2]}, want: "x-x"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pp, dateFilter := newDatePipeline(t, tt.texts, "last 5m", "")
+			if got := visibility(t, pp, len(tt.texts)); got != tt.want {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+
+			// the lines stay where they are, but in a few minutes the window
+			// moves past them
+			dateFilter.Lock()
+			dateFilter.fromKey = "last 2m"
+			dateFilter.Unlock()
+			if !dateFilter.Reevaluate() {
+				t.Errorf("Reevaluate() = false, want true")
+			}
+			pp.InvalidateCaches()
+
+			want := strings.Repeat("-", len(tt.texts)-1) + "x"
+			if got := visibility(t, pp, len(tt.texts)); got != want {
+				t.Errorf("lines = %q after moving on, want %q", got, want)
+			}
+		})
+	}
+}
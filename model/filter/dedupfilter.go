@@ -0,0 +1,293 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+const (
+	DedupExact = "exact"
+	DedupFuzzy = "fuzzy"
+)
+
+var DedupModes = []string{DedupExact, DedupFuzzy}
+
+// UUIDs, hex IDs (with at least one digit) and any other numbers, which also
+// takes care of timestamps
+var fuzzyRegex = regexp.MustCompile(
+	`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}` +
+		`|\b0[xX][0-9a-fA-F]+\b|\b[0-9a-fA-F]*[0-9][0-9a-fA-F]*\b|[0-9]+`)
+
+// DedupFilter folds runs of identical lines into the first line of the run
+// shown by the filters before it. Lines get compared as they are in the file,
+// so the run doesn't get interrupted by lines hidden by other filters.
+type DedupFilter struct {
+	FilterImpl
+	sync.Mutex
+
+	// lines only differing in numbers, IDs or timestamps are identical
+	fuzzy bool
+	// first lines of the runs the user wants to see in full
+	expanded map[int]bool
+	// runs found so far, sorted by their first line
+	runs []*dedupRun
+	// whether the filters before this one show a line, see isShownUpstream()
+	shown lineMemo
+}
+
+type dedupRun struct {
+	// lines from first (including) to end (excluding)
+	first int
+	end   int
+	// once a different line follows, the run can't grow anymore
+	complete bool
+
+	// how many lines of the run up to counted the filters before this one
+	// show and the first of them (-1 if none)
+	counted    int
+	shownLines int
+	firstShown int
+}
+
+func NewDedupFilter() *DedupFilter {
+	return &DedupFilter{}
+}
+
+// SetKey sets the mode, DedupExact or DedupFuzzy. Expanded runs get folded
+// again.
+func (d *DedupFilter) SetKey(name string, key string) error {
+	// don't care about the name
+	var fuzzy bool
+	switch key {
+	case "", DedupExact:
+		fuzzy = false
+	case DedupFuzzy:
+		fuzzy = true
+	default:
+		return fmt.Errorf("unknown dedup mode %s", key)
+	}
+
+	d.Lock()
+	d.fuzzy = fuzzy
+	d.expanded = nil
+	d.runs = nil
+	d.Unlock()
+	return nil
+}
+
+func (d *DedupFilter) GetLine(lineNo int) (*lines.Line, error) {
+	sourceLine, err := d.source.GetLine(lineNo)
+	if err != nil {
+		return sourceLine, err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if sourceLine.Status == lines.LineHidden {
+		d.shown.set(lineNo, lineHiddenUpstream)
+		return sourceLine, nil
+	}
+	d.shown.set(lineNo, lineShownUpstream)
+
+	if sourceLine.Marker {
+		return sourceLine, nil
+	}
+
+	run, err := d.runOf(lineNo)
+	if err != nil || d.expanded[run.first] {
+		return sourceLine, nil
+	}
+
+	d.count(run)
+	if lineNo != run.firstShown {
+		sourceLine.Status = lines.LineHidden
+		sourceLine.Matched = false
+		return sourceLine, nil
+	}
+
+	if run.shownLines > 1 {
+		sourceLine.Repeats = run.shownLines
+	}
+
+	return sourceLine, nil
+}
+
+// Expand shows all lines of the run lineNo belongs to. Returns false if
+// there's no such run.
+func (d *DedupFilter) Expand(lineNo int) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	run, err := d.runOf(lineNo)
+	if err != nil || d.expanded[run.first] {
+		return false
+	}
+
+	d.count(run)
+	if run.shownLines <= 1 {
+		return false
+	}
+
+	if d.expanded == nil {
+		d.expanded = make(map[int]bool)
+	}
+	d.expanded[run.first] = true
+	return true
+}
+
+// Collapse folds all expanded runs again. Returns false if there were none.
+func (d *DedupFilter) Collapse() bool {
+	d.Lock()
+	defer d.Unlock()
+
+	collapsed := len(d.expanded) > 0
+	d.expanded = nil
+	return collapsed
+}
+
+// runStart returns the first line of the run lineNo belongs to, that's how
+// far back new lines continuing the run change things.
+func (d *DedupFilter) runStart(lineNo int) int {
+	d.Lock()
+	defer d.Unlock()
+
+	run, err := d.runOf(lineNo)
+	if err != nil {
+		return lineNo
+	}
+	return run.first
+}
+
+// does not lock! Every line belongs to a run, even if it's the only line of
+// the run. Each run gets scanned only once (and when it grows).
+func (d *DedupFilter) runOf(lineNo int) (*dedupRun, error) {
+	i, found := slices.BinarySearchFunc(d.runs, lineNo,
+		func(run *dedupRun, lineNo int) int {
+			switch {
+			case lineNo < run.first:
+				return 1
+			case lineNo >= run.end:
+				return -1
+			default:
+				return 0
+			}
+		})
+	if found {
+		return d.runs[i], nil
+	}
+
+	// In follow mode the run before might have gotten longer
+	bound := 0
+	if i > 0 {
+		previous := d.runs[i-1]
+		d.grow(previous)
+		if lineNo < previous.end {
+			return previous, nil
+		}
+		bound = previous.end
+	}
+
+	text, err := d.text(lineNo)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &dedupRun{first: lineNo, end: lineNo + 1, firstShown: -1}
+	for run.first > bound {
+		if prev, err := d.text(run.first - 1); err != nil || prev != text {
+			break
+		}
+		run.first--
+	}
+	run.counted = run.first
+	d.grow(run)
+
+	d.runs = slices.Insert(d.runs, i, run)
+	return run, nil
+}
+
+// does not lock! In follow mode a run at the end might get longer.
+func (d *DedupFilter) grow(run *dedupRun) {
+	if run.complete {
+		return
+	}
+
+	text, err := d.text(run.first)
+	if err != nil {
+		return
+	}
+
+	for {
+		next, err := d.text(run.end)
+		if err != nil {
+			// maybe there will be more lines
+			return
+		}
+		if next != text {
+			run.complete = true
+			return
+		}
+		run.end++
+	}
+}
+
+// does not lock! Only lines shown by the filters before this one count.
+func (d *DedupFilter) count(run *dedupRun) {
+	for ; run.counted < run.end; run.counted++ {
+		if !d.isShownUpstream(run.counted) {
+			continue
+		}
+		if run.firstShown < 0 {
+			run.firstShown = run.counted
+		}
+		run.shownLines++
+	}
+}
+
+// does not lock!
+func (d *DedupFilter) isShownUpstream(lineNo int) bool {
+	if state := d.shown.get(lineNo); state != lineUnknown {
+		return state == lineShownUpstream
+	}
+
+	// never went through GetLine() so far, so it's not cached either
+	line, err := d.source.GetLine(lineNo)
+	if err != nil {
+		return false
+	}
+	state := lineShownUpstream
+	if line.Status == lines.LineHidden {
+		state = lineHiddenUpstream
+	}
+	d.shown.set(lineNo, state)
+	return state == lineShownUpstream
+}
+
+// Runs with forgotten lines get scanned again.
+func (d *DedupFilter) forget(from int, to int) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.shown.forget(from, to)
+	d.runs = slices.DeleteFunc(d.runs, func(run *dedupRun) bool {
+		return run.first < to && run.end > from
+	})
+}
+
+// does not lock!
+func (d *DedupFilter) text(lineNo int) (string, error) {
+	record, pos, err := d.source.Record(lineNo)
+	if err != nil {
+		return "", err
+	}
+
+	if d.fuzzy {
+		return fuzzyRegex.ReplaceAllString(record[pos], "#"), nil
+	}
+	return record[pos], nil
+}
@@ -0,0 +1,161 @@
+package filter
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+// texts of the lines shown, with the number of repeats if folded
+func shownLines(t *testing.T, f Filter, length int) []string {
+	t.Helper()
+
+	var shown []string
+	for i := range length {
+		line, err := f.GetLine(i)
+		if err != nil {
+			t.Fatalf("GetLine(%d) error = %v", i, err)
+		}
+		if line.Status == lines.LineHidden {
+			continue
+		}
+		text := line.Str
+		if line.Repeats > 0 {
+			text = fmt.Sprintf("%s x%d", text, line.Repeats)
+		}
+		shown = append(shown, text)
+	}
+	return shown
+}
+
+func newTestSource(texts []string) *Source {
+	source := NewSource()
+	newLines := make([]*lines.Line, len(texts))
+	for i, text := range texts {
+		newLines[i] = lines.NewLine(i, text)
+	}
+	source.StoreNewLines(newLines)
+	return source
+}
+
+func TestDedupFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		texts []string
+		// hidden by a filter before the dedup filter
+		hide  string
+		fuzzy bool
+		want  []string
+	}{
+		{
+			name:  "runs",
+			texts: []string{"a", "a", "a", "b", "a", "a"},
+			want:  []string{"a x3", "b", "a x2"},
+		},
+		{
+			name:  "fuzzy",
+			texts: []string{"took 3ms", "took 12ms", "took x"},
+			fuzzy: true,
+			want:  []string{"took 3ms x2", "took x"},
+		},
+		{
+			name:  "first line of run hidden upstream",
+			texts: []string{"b", "a 1", "a 2", "a 3", "c"},
+			hide:  "a 1",
+			fuzzy: true,
+			want:  []string{"b", "a 2 x2", "c"},
+		},
+		{
+			name:  "line within run hidden upstream",
+			texts: []string{"a 1", "a 2", "a 3", "b"},
+			hide:  "a 2",
+			fuzzy: true,
+			want:  []string{"a 1 x2", "b"},
+		},
+		{
+			name:  "all but one line of run hidden upstream",
+			texts: []string{"a 1", "a 2", "a 1"},
+			hide:  "a 1",
+			fuzzy: true,
+			want:  []string{"a 2"},
+		},
+		{
+			name:  "line hidden upstream still ends the run",
+			texts: []string{"a", "x", "a"},
+			hide:  "x",
+			want:  []string{"a", "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newTestSource(tt.texts)
+			var upstream Filter = source
+			if tt.hide != "" {
+				hide := NewStringFilter(nil, config.FilterHide)
+				hide.SetSource(source)
+				if err := hide.SetKey("", tt.hide); err != nil {
+					t.Fatal(err)
+				}
+				upstream = hide
+			}
+
+			dedup := NewDedupFilter()
+			dedup.SetSource(upstream)
+			if tt.fuzzy {
+				dedup.SetKey("", DedupFuzzy)
+			}
+
+			if got := shownLines(t, dedup, len(tt.texts)); !slices.Equal(got, tt.want) {
+				t.Errorf("shown lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDedupFilterExpandedRunGrows(t *testing.T) {
+	source := newTestSource([]string{"b", "a", "a"})
+	dedup := NewDedupFilter()
+	dedup.SetSource(source)
+
+	if !dedup.Expand(1) {
+		t.Fatal("Expand(1) = false, want true")
+	}
+	source.StoreNewLines([]*lines.Line{lines.NewLine(3, "a"), lines.NewLine(4, "c")})
+
+	want := []string{"b", "a", "a", "a", "c"}
+	if got := shownLines(t, dedup, 5); !slices.Equal(got, want) {
+		t.Errorf("shown lines = %q, want %q", got, want)
+	}
+}
+
+// In follow mode only the last run needs to be looked at again.
+func TestDedupFilterFollow(t *testing.T) {
+	source := newTestSource([]string{"a", "b", "b"})
+	cache := NewCache()
+	pp := &Pipeline{}
+	pp.Add(source)
+	pp.Add(cache)
+	pp.Add(NewDedupFilter())
+
+	want := []string{"a", "b x2"}
+	if got := shownLines(t, cache, 3); !slices.Equal(got, want) {
+		t.Fatalf("shown lines = %q, want %q", got, want)
+	}
+
+	newLines := []*lines.Line{lines.NewLine(0, "b"), lines.NewLine(0, "c")}
+	source.StoreNewLines(newLines)
+	first := newLines[0].No
+	if got := pp.LooksAhead(first); got != 2 {
+		t.Fatalf("LooksAhead(%d) = %d, want 2", first, got)
+	}
+	pp.InvalidateCachedLines(first-2, first)
+
+	want = []string{"a", "b x3", "c"}
+	if got := shownLines(t, cache, 5); !slices.Equal(got, want) {
+		t.Errorf("shown lines = %q, want %q", got, want)
+	}
+}
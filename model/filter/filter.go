@@ -11,6 +11,9 @@ type Filter interface {
 	SetSource(source Filter)
 	Size() (int, int)
 	Length() int
+	// Record returns the raw text of all lines of the (multi-line) record
+	// lineNo belongs to and the position of lineNo within the record.
+	Record(lineNo int) ([]string, int, error)
 
 	SetKey(name string, key string) error
 	SetColorIndex(colorIndex uint8)
@@ -42,6 +45,10 @@ func (f *FilterImpl) Length() int {
 	return f.source.Length()
 }
 
+func (f *FilterImpl) Record(lineNo int) ([]string, int, error) {
+	return f.source.Record(lineNo)
+}
+
 func (f *FilterImpl) SetColorIndex(colorIndex uint8) {
 	f.colorIndex = colorIndex
 }
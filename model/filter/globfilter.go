@@ -0,0 +1,80 @@
+package filter
+
+import (
+	"regexp"
+	"strings"
+)
+
+// GlobFilterFuncFactory matches shell-style patterns: * matches anything, ?
+// any single character and [...] one of the characters given. The pattern
+// may match anywhere in the line.
+func GlobFilterFuncFactory(key string, caseSensitive bool) (func(input string) (string, [][]int, bool), error) {
+	pattern := globPattern(key)
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, ErrRegex
+	}
+
+	return func(input string) (string, [][]int, bool) {
+		indeces := re.FindAllStringIndex(input, -1)
+		if indeces == nil {
+			return "", indeces, false
+		}
+
+		return input, indeces, true
+	}, nil
+}
+
+func globPattern(glob string) string {
+	var sb strings.Builder
+
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		case '[':
+			end := i + 1
+			if end < len(runes) && (runes[end] == '!' || runes[end] == '^') {
+				end++
+			}
+			// a ] right at the beginning is part of the class
+			if end < len(runes) && runes[end] == ']' {
+				end++
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end == len(runes) {
+				// no closing bracket, so it's just a bracket
+				sb.WriteString(`\[`)
+				continue
+			}
+
+			class := string(runes[i+1 : end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i = end
+		case '\\':
+			// escapes the next character, just like in the shell
+			if i+1 < len(runes) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(runes[i])))
+			} else {
+				sb.WriteString(`\\`)
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return sb.String()
+}
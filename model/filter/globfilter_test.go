@@ -0,0 +1,46 @@
+package filter
+
+import "testing"
+
+func TestGlobFilter(t *testing.T) {
+	tests := []struct {
+		glob          string
+		input         string
+		caseSensitive bool
+		want          bool
+	}{
+		{glob: "error", input: "an error occurred", want: true},
+		{glob: "err*red", input: "an error occurred", want: true},
+		{glob: "err?r", input: "an error occurred", want: true},
+		{glob: "err?r", input: "an errr occurred", want: false},
+		{glob: "ERROR", input: "an error occurred", want: true},
+		{glob: "ERROR", input: "an error occurred", caseSensitive: true, want: false},
+		{glob: "web-0[12]", input: "host web-02", want: true},
+		{glob: "web-0[12]", input: "host web-03", want: false},
+		{glob: "web-0[1-3]", input: "host web-03", want: true},
+		{glob: "web-0[!12]", input: "host web-03", want: true},
+		{glob: "web-0[^12]", input: "host web-02", want: false},
+		{glob: "[]]", input: "a]b", want: true},
+		{glob: "[ab", input: "x[ab", want: true},
+		{glob: "[ab", input: "a", want: false},
+		{glob: `\*`, input: "a*b", want: true},
+		{glob: `\*`, input: "ab", want: false},
+		{glob: `a\`, input: `a\`, want: true},
+		{glob: "a.c", input: "abc", want: false},
+		{glob: "(x)", input: "f(x)", want: true},
+		{glob: "ü?", input: "über", want: true},
+		{glob: `[\]`, input: `a\b`, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.glob+" "+tt.input, func(t *testing.T) {
+			filterFunc, err := GlobFilterFuncFactory(tt.glob, tt.caseSensitive)
+			if err != nil {
+				t.Fatalf("GlobFilterFuncFactory() error = %v", err)
+			}
+			if _, _, matched := filterFunc(tt.input); matched != tt.want {
+				t.Errorf("%q matches %q = %v, want %v", tt.glob, tt.input, matched, tt.want)
+			}
+		})
+	}
+}
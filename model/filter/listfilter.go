@@ -0,0 +1,169 @@
+package filter
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"weak"
+)
+
+// prefix of keys naming a keyword list file
+const keywordListFilePrefix = "@"
+
+// KeywordListFilterFuncFactory matches lines containing any of a list of
+// keywords, e.g. IPs or user names. The key is either @ followed by the path
+// of a file with one keyword per line (empty lines and lines starting with #
+// are ignored) or the keywords themselves, separated by commas or spaces.
+// Files get reloaded by ReloadKeywordLists() once they change.
+func KeywordListFilterFuncFactory(key string, caseSensitive bool) (func(input string) (string, [][]int, bool), error) {
+	var list *keywordList
+	if path, ok := strings.CutPrefix(key, keywordListFilePrefix); ok {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			return nil, errors.New("no keyword list file given")
+		}
+		var err error
+		list, err = fileKeywordList(path, caseSensitive)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		keywords := strings.FieldsFunc(key, func(r rune) bool {
+			return r == ',' || r == ';' || r == ' ' || r == '\t'
+		})
+		list = &keywordList{matcher: newAhoCorasick(keywords, !caseSensitive)}
+	}
+
+	return func(input string) (string, [][]int, bool) {
+		indeces := list.find(input)
+		if indeces == nil {
+			return "", nil, false
+		}
+
+		return input, indeces, true
+	}, nil
+}
+
+type keywordList struct {
+	sync.RWMutex
+	matcher *ahoCorasick
+
+	// only set for lists read from a file
+	path          string
+	caseSensitive bool
+	modTime       time.Time
+	size          int64
+}
+
+func (k *keywordList) find(input string) [][]int {
+	k.RLock()
+	defer k.RUnlock()
+
+	return k.matcher.find(input)
+}
+
+// lists read from files, so they can be reloaded. Only the filter funcs keep
+// them alive, so lists no filter uses anymore are dropped.
+var (
+	keywordLists      []weak.Pointer[keywordList]
+	keywordListsMutex sync.Mutex
+)
+
+// Filters using the same file share the list.
+func fileKeywordList(path string, caseSensitive bool) (*keywordList, error) {
+	keywordListsMutex.Lock()
+	defer keywordListsMutex.Unlock()
+
+	for _, list := range liveKeywordLists() {
+		if list.path == path && list.caseSensitive == caseSensitive {
+			return list, nil
+		}
+	}
+
+	list := &keywordList{path: path, caseSensitive: caseSensitive}
+	if _, err := list.reload(); err != nil {
+		return nil, err
+	}
+	keywordLists = append(keywordLists, weak.Make(list))
+	return list, nil
+}
+
+// does not lock! Drops the lists which are gone.
+func liveKeywordLists() []*keywordList {
+	var lists []*keywordList
+	live := keywordLists[:0]
+	for _, pointer := range keywordLists {
+		if list := pointer.Value(); list != nil {
+			lists = append(lists, list)
+			live = append(live, pointer)
+		}
+	}
+	clear(keywordLists[len(live):])
+	keywordLists = live
+	return lists
+}
+
+// ReloadKeywordLists reads all keyword list files again which changed since
+// they were last read. Returns true if there was any.
+func ReloadKeywordLists() bool {
+	keywordListsMutex.Lock()
+	defer keywordListsMutex.Unlock()
+
+	changed := false
+	for _, list := range liveKeywordLists() {
+		reloaded, _ := list.reload()
+		changed = reloaded || changed
+	}
+	return changed
+}
+
+// HasKeywordLists returns true if any filter uses a keyword list file.
+func HasKeywordLists() bool {
+	keywordListsMutex.Lock()
+	defer keywordListsMutex.Unlock()
+
+	return len(liveKeywordLists()) > 0
+}
+
+// Returns true if the file changed. The old keywords are kept in case the
+// file can't be read.
+func (k *keywordList) reload() (bool, error) {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(k.modTime) && info.Size() == k.size {
+		return false, nil
+	}
+
+	file, err := os.Open(k.path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	var keywords []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		keyword := strings.TrimSpace(scanner.Text())
+		if keyword == "" || strings.HasPrefix(keyword, "#") {
+			continue
+		}
+		keywords = append(keywords, keyword)
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+
+	matcher := newAhoCorasick(keywords, !k.caseSensitive)
+
+	k.Lock()
+	k.matcher = matcher
+	k.modTime = info.ModTime()
+	k.size = info.Size()
+	k.Unlock()
+	return true, nil
+}
@@ -0,0 +1,133 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/claude42/infiltrator/config"
+)
+
+func TestKeywordListFilter(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeFile(t, "error", "# comment\n\nalice\n bob \n")
+
+	tests := []struct {
+		key     string
+		input   string
+		want    bool
+		wantErr bool
+	}{
+		// a file named like the keyword doesn't matter without @
+		{key: "error", input: "an error occurred", want: true},
+		{key: "error", input: "alice logged in", want: false},
+		{key: "alice, bob", input: "bob logged in", want: true},
+		{key: "@error", input: "bob logged in", want: true},
+		{key: "@error", input: "an error occurred", want: false},
+		{key: "@error", input: "# comment", want: false},
+		{key: "@ " + filepath.Join(dir, "error"), input: "alice logged in", want: true},
+		{key: "@missing", wantErr: true},
+		{key: "@", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+" "+tt.input, func(t *testing.T) {
+			filterFunc, err := KeywordListFilterFuncFactory(tt.key, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("KeywordListFilterFuncFactory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if _, _, got := filterFunc(tt.input); got != tt.want {
+				t.Errorf("filterFunc(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeywordListReload(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeFile(t, "users", "alice\n")
+
+	filterFunc, err := KeywordListFilterFuncFactory("@users", false)
+	if err != nil {
+		t.Fatalf("KeywordListFilterFuncFactory() error = %v", err)
+	}
+
+	writeFile(t, "users", "bob\ncarol\n")
+	if !ReloadKeywordLists() {
+		t.Errorf("ReloadKeywordLists() = false, want true")
+	}
+	if _, _, matched := filterFunc("alice logged in"); matched {
+		t.Errorf("alice still matches after reload")
+	}
+	if _, _, matched := filterFunc("carol logged in"); !matched {
+		t.Errorf("carol doesn't match after reload")
+	}
+	if ReloadKeywordLists() {
+		t.Errorf("ReloadKeywordLists() = true without changes, want false")
+	}
+}
+
+// A changed list must not be answered from what the filter remembers.
+func TestKeywordListReloadInvalidates(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeFile(t, "admins", "alice\n")
+
+	stringFilter := NewStringFilter(KeywordListFilterFuncFactory, config.FilterMatch)
+	if err := stringFilter.SetKey("", "@admins"); err != nil {
+		t.Fatalf("SetKey() error = %v", err)
+	}
+	pp := &Pipeline{}
+	pp.Add(newTestSource([]string{"alice logged in", "carol logged in"}))
+	pp.Add(NewCache())
+	pp.Add(stringFilter)
+
+	if got := statuses(t, pp, 1); got != "m" {
+		t.Fatalf("statuses = %q before reload, want %q", got, "m")
+	}
+	if !HasKeywordLists() {
+		t.Errorf("HasKeywordLists() = false, want true")
+	}
+
+	writeFile(t, "admins", "carol\ndave\n")
+	if !ReloadKeywordLists() {
+		t.Fatalf("ReloadKeywordLists() = false, want true")
+	}
+	pp.InvalidateCaches()
+	if got := statuses(t, pp, 2); got != "-m" {
+		t.Errorf("statuses = %q after reload, want %q", got, "-m")
+	}
+}
+
+func TestKeywordListDropped(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeFile(t, "users", "alice\n")
+
+	filterFunc, err := KeywordListFilterFuncFactory("@users", false)
+	if err != nil {
+		t.Fatalf("KeywordListFilterFuncFactory() error = %v", err)
+	}
+	runtime.KeepAlive(filterFunc)
+	filterFunc = nil
+	runtime.GC()
+
+	keywordListsMutex.Lock()
+	lists := liveKeywordLists()
+	keywordListsMutex.Unlock()
+	for _, list := range lists {
+		if list.path == "users" {
+			t.Errorf("list still there although no filter uses it")
+		}
+	}
+}
+
+func writeFile(t *testing.T, name string, content string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
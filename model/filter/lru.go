@@ -0,0 +1,66 @@
+package filter
+
+import (
+	"container/list"
+
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+// lineLRU keeps at most capacity lines, the least recently used line gets
+// dropped first. Not thread safe, callers have to take care of locking.
+type lineLRU struct {
+	capacity int
+	order    *list.List
+	entries  map[int]*list.Element
+}
+
+type lruEntry struct {
+	lineNo int
+	line   *lines.Line
+}
+
+func newLineLRU(capacity int) *lineLRU {
+	return &lineLRU{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[int]*list.Element),
+	}
+}
+
+func (l *lineLRU) get(lineNo int) (*lines.Line, bool) {
+	element, ok := l.entries[lineNo]
+	if !ok {
+		return nil, false
+	}
+
+	l.order.MoveToFront(element)
+	return element.Value.(*lruEntry).line, true
+}
+
+func (l *lineLRU) put(lineNo int, line *lines.Line) {
+	if element, ok := l.entries[lineNo]; ok {
+		element.Value.(*lruEntry).line = line
+		l.order.MoveToFront(element)
+		return
+	}
+
+	l.entries[lineNo] = l.order.PushFront(&lruEntry{lineNo, line})
+
+	if l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).lineNo)
+	}
+}
+
+func (l *lineLRU) remove(lineNo int) {
+	if element, ok := l.entries[lineNo]; ok {
+		l.order.Remove(element)
+		delete(l.entries, lineNo)
+	}
+}
+
+func (l *lineLRU) clear() {
+	l.order.Init()
+	l.entries = make(map[int]*list.Element)
+}
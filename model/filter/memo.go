@@ -0,0 +1,36 @@
+package filter
+
+// lineMemo remembers a small value for each line, 0 means nothing is known
+// about the line (yet).
+type lineMemo []int8
+
+func (m lineMemo) get(lineNo int) int8 {
+	if lineNo < 0 || lineNo >= len(m) {
+		return 0
+	}
+	return m[lineNo]
+}
+
+func (m *lineMemo) set(lineNo int, value int8) {
+	if lineNo < 0 {
+		return
+	}
+	if lineNo >= len(*m) {
+		*m = append(*m, make([]int8, lineNo+1-len(*m))...)
+	}
+	(*m)[lineNo] = value
+}
+
+// forget forgets the lines from (including) to to (excluding).
+func (m lineMemo) forget(from int, to int) {
+	clear(m[min(max(0, from), len(m)):min(max(0, to), len(m))])
+}
+
+// Filters looking at other lines than the one asked for (e.g. for context)
+// remember what they found out. Source.GetLine() hands out the lines
+// themselves, so running a line through the filters a second time would
+// change it while it's cached. Whenever cached lines get invalidated, filters
+// have to forget about them as well.
+type forgetter interface {
+	forget(from int, to int)
+}
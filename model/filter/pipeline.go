@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"sync"
 
 	"github.com/claude42/infiltrator/fail"
@@ -166,6 +167,108 @@ func (pp *Pipeline) DateFilter() (*DateFilter, error) {
 	return nil, util.ErrNotFound
 }
 
+// ReevaluateDates lets all date filters catch up with new lines and the
+// current time. Returns true if lines might be hidden or shown differently now.
+func (pp *Pipeline) ReevaluateDates() bool {
+	pipelineMutex.Lock()
+	defer pipelineMutex.Unlock()
+
+	changed := false
+	for _, f := range *pp {
+		if dateFilter, ok := f.(*DateFilter); ok {
+			changed = dateFilter.Reevaluate() || changed
+		}
+	}
+
+	return changed
+}
+
+// ReparseKeys parses the keys of all string filters again, see
+// StringFilter.ReparseKey().
+func (pp *Pipeline) ReparseKeys() {
+	pipelineMutex.Lock()
+	defer pipelineMutex.Unlock()
+
+	for _, f := range *pp {
+		if stringFilter, ok := f.(*StringFilter); ok {
+			if err := stringFilter.ReparseKey(); err != nil {
+				log.Printf("error parsing key again: %+v", err)
+			}
+		}
+	}
+}
+
+// LooksAhead returns how many lines before the new lines starting at first
+// might look different once the new lines are there, e.g. because a filter
+// shows context before its matches or the new lines continue a run of
+// repeated lines.
+func (pp *Pipeline) LooksAhead(first int) int {
+	pipelineMutex.Lock()
+	defer pipelineMutex.Unlock()
+
+	// each filter might change lines even further back than the filters
+	// before it
+	lookAhead := 0
+	for _, f := range *pp {
+		switch f := f.(type) {
+		case *StringFilter:
+			lookAhead += f.LooksAhead()
+		case *DedupFilter:
+			if start := first - max(lookAhead, 1); start >= 0 {
+				lookAhead = max(lookAhead, first-f.runStart(start))
+			}
+		}
+	}
+
+	return min(lookAhead, first)
+}
+
+// ShowsContext returns true if any filter shows lines around its matches, the
+// display then separates lines which aren't adjacent.
+func (pp *Pipeline) ShowsContext() bool {
+	pipelineMutex.Lock()
+	defer pipelineMutex.Unlock()
+
+	for _, f := range *pp {
+		if stringFilter, ok := f.(*StringFilter); ok && stringFilter.ShowsContext() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ExpandRun shows all lines of the run of repeated lines lineNo belongs to.
+// Returns false if no dedup filter folded such a run.
+func (pp *Pipeline) ExpandRun(lineNo int) bool {
+	pipelineMutex.Lock()
+	defer pipelineMutex.Unlock()
+
+	expanded := false
+	for _, f := range *pp {
+		if dedupFilter, ok := f.(*DedupFilter); ok {
+			expanded = dedupFilter.Expand(lineNo) || expanded
+		}
+	}
+
+	return expanded
+}
+
+// CollapseRuns folds all expanded runs again.
+func (pp *Pipeline) CollapseRuns() bool {
+	pipelineMutex.Lock()
+	defer pipelineMutex.Unlock()
+
+	collapsed := false
+	for _, f := range *pp {
+		if dedupFilter, ok := f.(*DedupFilter); ok {
+			collapsed = dedupFilter.Collapse() || collapsed
+		}
+	}
+
+	return collapsed
+}
+
 func (pp *Pipeline) Size() (int, int) {
 	filter, err := pp.OutputFilter()
 	if err != nil {
@@ -184,11 +287,35 @@ func (pp *Pipeline) SourceLength() int {
 	return filter.Length()
 }
 
+// BoundCaches limits how many lines get cached, see Cache.Bound().
+func (pp *Pipeline) BoundCaches() {
+	for _, f := range *pp {
+		if cache, ok := f.(*Cache); ok {
+			cache.Bound()
+		}
+	}
+}
+
+// InvalidateCachedLines makes the caches (and all filters remembering
+// anything about lines) forget the lines from (including) to to (excluding).
+func (pp *Pipeline) InvalidateCachedLines(from int, to int) {
+	for _, f := range *pp {
+		switch f := f.(type) {
+		case *Cache:
+			f.InvalidateLines(from, to)
+		case forgetter:
+			f.forget(from, to)
+		}
+	}
+}
+
 func (pp *Pipeline) InvalidateCaches() {
 	for _, f := range *pp {
-		cache, ok := f.(*Cache)
-		if ok {
-			cache.Invalidate()
+		switch f := f.(type) {
+		case *Cache:
+			f.Invalidate()
+		case forgetter:
+			f.forget(0, math.MaxInt)
 		}
 	}
 }
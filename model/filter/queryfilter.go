@@ -0,0 +1,356 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/claude42/infiltrator/model/formats"
+)
+
+// Queries combine terms with AND, OR, NOT and parentheses, e.g.
+//
+//	(error OR fatal) AND NOT healthcheck AND host:web-0*
+//
+// Terms next to each other are ANDed. Terms may contain * and ? wildcards or
+// be quoted to include spaces and operators. field:value only matches the
+// whole value of the field, other terms match anywhere in the line.
+
+// QueryError describes what's wrong with a query and where.
+type QueryError struct {
+	Pos int
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%s at %d", e.Msg, e.Pos+1)
+}
+
+func QueryFilterFuncFactory(key string, caseSensitive bool) (func(input string) (string, [][]int, bool), error) {
+	query, err := parseQuery(key, caseSensitive)
+	if err != nil {
+		return nil, err
+	}
+
+	// Highlighted are all terms which aren't negated and were found in the
+	// line, no matter if they made a difference for the result
+	return func(input string) (string, [][]int, bool) {
+		if query == nil {
+			return input, nil, true
+		}
+
+		var indeces [][]int
+		if !query.match(input, &indeces) {
+			return "", nil, false
+		}
+		return input, indeces, true
+	}, nil
+}
+
+type queryNode interface {
+	// Appends the positions of all matching terms to indeces, unless it's nil
+	match(text string, indeces *[][]int) bool
+}
+
+type queryAnd []queryNode
+type queryOr []queryNode
+type queryNot struct{ node queryNode }
+
+type queryTerm struct {
+	// empty for terms matching anywhere in the line
+	field string
+	regex *regexp.Regexp
+}
+
+// Unlike the usual && and ||, all nodes get evaluated so that every term
+// found gets highlighted.
+func (q queryAnd) match(text string, indeces *[][]int) bool {
+	matched := true
+	for _, node := range q {
+		matched = node.match(text, indeces) && matched
+	}
+	return matched
+}
+
+func (q queryOr) match(text string, indeces *[][]int) bool {
+	matched := false
+	for _, node := range q {
+		matched = node.match(text, indeces) || matched
+	}
+	return matched
+}
+
+func (q queryNot) match(text string, indeces *[][]int) bool {
+	return !q.node.match(text, nil)
+}
+
+func (q *queryTerm) match(text string, indeces *[][]int) bool {
+	start, end := 0, len(text)
+	if q.field != "" {
+		var ok bool
+		start, end, ok = formats.FieldSpan(text, q.field)
+		if !ok {
+			return false
+		}
+	}
+
+	found := q.regex.FindAllStringIndex(text[start:end], -1)
+	if found == nil {
+		return false
+	}
+
+	if indeces != nil {
+		for _, index := range found {
+			*indeces = append(*indeces, []int{index[0] + start, index[1] + start})
+		}
+	}
+	return true
+}
+
+// parseQuery compiles query. An empty query results in nil, which matches
+// everything.
+func parseQuery(query string, caseSensitive bool) (queryNode, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &queryParser{tokens: tokens, caseSensitive: caseSensitive, end: len(query)}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, &QueryError{Pos: p.tokens[p.pos].pos, Msg: "unexpected " + p.tokens[p.pos].text}
+	}
+
+	return node, nil
+}
+
+type queryToken struct {
+	text string
+	pos  int
+	// quoted terms are never operators and don't have wildcards
+	quoted bool
+	// set for quoted values of field terms, e.g. msg:"foo bar"
+	field string
+}
+
+func tokenizeQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+
+	for pos := 0; pos < len(query); {
+		switch c := query[pos]; {
+		case c == ' ' || c == '\t':
+			pos++
+		case c == '(' || c == ')':
+			tokens = append(tokens, queryToken{text: string(c), pos: pos})
+			pos++
+		default:
+			start := pos
+			for pos < len(query) && !strings.ContainsRune(" \t()\"", rune(query[pos])) {
+				pos++
+			}
+			word := query[start:pos]
+
+			if pos == len(query) || query[pos] != '"' || (word != "" && !strings.HasSuffix(word, ":")) {
+				tokens = append(tokens, queryToken{text: word, pos: start})
+				continue
+			}
+
+			end := closingQueryQuote(query, pos+1)
+			if end < 0 {
+				return nil, &QueryError{Pos: pos, Msg: "missing closing quote"}
+			}
+			text := strings.ReplaceAll(query[pos+1:end], `\"`, `"`)
+			tokens = append(tokens, queryToken{text: text, pos: start, quoted: true,
+				field: strings.TrimSuffix(word, ":")})
+			pos = end + 1
+		}
+	}
+
+	return tokens, nil
+}
+
+func closingQueryQuote(query string, start int) int {
+	for i := start; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+type queryParser struct {
+	tokens        []queryToken
+	pos           int
+	caseSensitive bool
+	// length of the query, for errors at its end
+	end int
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return queryToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *queryParser) isOperator(text string) bool {
+	token, ok := p.peek()
+	return ok && !token.quoted && token.text == text
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	var nodes queryOr
+	for {
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+
+		if !p.isOperator("OR") {
+			break
+		}
+		p.pos++
+	}
+
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	var nodes queryAnd
+	for {
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+
+		if p.isOperator("AND") {
+			p.pos++
+			continue
+		}
+		// no operator at all means AND, too
+		if token, ok := p.peek(); !ok || p.isOperator("OR") || (token.text == ")" && !token.quoted) {
+			break
+		}
+	}
+
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) parseNot() (queryNode, error) {
+	if p.isOperator("NOT") {
+		p.pos++
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return queryNot{node: node}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (queryNode, error) {
+	token, ok := p.peek()
+	if !ok {
+		return nil, &QueryError{Pos: p.end, Msg: "missing term"}
+	}
+
+	if !token.quoted {
+		switch token.text {
+		case "(":
+			p.pos++
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.isOperator(")") {
+				return nil, &QueryError{Pos: token.pos, Msg: "missing )"}
+			}
+			p.pos++
+			return node, nil
+		case ")", "AND", "OR":
+			return nil, &QueryError{Pos: token.pos, Msg: "unexpected " + token.text}
+		}
+	}
+
+	p.pos++
+	return p.newTerm(token)
+}
+
+func (p *queryParser) newTerm(token queryToken) (queryNode, error) {
+	field, value := token.field, token.text
+	if !token.quoted {
+		if name, rest, found := strings.Cut(value, ":"); found && rest != "" &&
+			formats.FieldName(name) != "" {
+
+			field, value = name, rest
+		}
+	}
+
+	var pattern string
+	if token.quoted {
+		pattern = regexp.QuoteMeta(value)
+	} else {
+		pattern = globToRegex(value, field == "")
+	}
+
+	if field != "" {
+		fieldName := formats.FieldName(field)
+		if fieldName == "" {
+			return nil, &QueryError{Pos: token.pos, Msg: "unknown field " + field}
+		}
+		field = fieldName
+		// the whole value has to match
+		pattern = "^" + pattern + "$"
+	}
+
+	if !p.caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, &QueryError{Pos: token.pos, Msg: "invalid term"}
+	}
+
+	return &queryTerm{field: field, regex: regex}, nil
+}
+
+// Within a line wildcards don't cross word boundaries, within a field they
+// do.
+func globToRegex(glob string, inLine bool) string {
+	anything, anyChar := ".*", "."
+	if inLine {
+		anything, anyChar = `\S*`, `\S`
+	}
+
+	var sb strings.Builder
+	for _, c := range glob {
+		switch c {
+		case '*':
+			sb.WriteString(anything)
+		case '?':
+			sb.WriteString(anyChar)
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
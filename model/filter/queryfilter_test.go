@@ -0,0 +1,193 @@
+package filter
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/formats"
+)
+
+func TestTokenizeQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    []queryToken
+		wantErr int
+	}{
+		{
+			query: "error",
+			want:  []queryToken{{text: "error", pos: 0}},
+		},
+		{
+			query: " (a OR\tb)",
+			want: []queryToken{{text: "(", pos: 1}, {text: "a", pos: 2}, {text: "OR", pos: 4},
+				{text: "b", pos: 7}, {text: ")", pos: 8}},
+		},
+		{
+			query: `"a OR b" x`,
+			want:  []queryToken{{text: "a OR b", pos: 0, quoted: true}, {text: "x", pos: 9}},
+		},
+		{
+			query: `msg:"foo \"bar\""`,
+			want:  []queryToken{{text: `foo "bar"`, pos: 0, quoted: true, field: "msg"}},
+		},
+		{
+			query: `host:web*`,
+			want:  []queryToken{{text: "host:web*", pos: 0}},
+		},
+		{
+			query:   `a "b`,
+			wantErr: 3,
+		},
+		{
+			query: "",
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := tokenizeQuery(tt.query)
+			if tt.wantErr > 0 {
+				var queryErr *QueryError
+				if !errors.As(err, &queryErr) || queryErr.Pos != tt.wantErr-1 {
+					t.Errorf("tokenizeQuery() error = %v, want one at %d", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("tokenizeQuery() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("tokenizeQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryFilter(t *testing.T) {
+	config.User().FileFormat = formats.FormatLogfmt
+	config.User().FileFormatFields = []string{"host", "msg", "level"}
+	defer func() {
+		config.User().FileFormat = ""
+		config.User().FileFormatFields = nil
+	}()
+
+	lines := []string{
+		`host=web-01 level=error msg="disk full"`,
+		`host=web-02 level=info msg="healthcheck ok"`,
+		`host=db-01 level=fatal msg="out of memory"`,
+		`host=web-03 level=warn msg="slow request AND retry"`,
+	}
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"error", []int{0}},
+		{"ERROR", []int{0}},
+		{"error OR fatal", []int{0, 2}},
+		{"web disk", []int{0}},
+		{"web AND NOT healthcheck", []int{0, 3}},
+		{"(error OR fatal) AND NOT memory", []int{0}},
+		{"NOT NOT fatal", []int{2}},
+		{"host:web-0*", []int{0, 1, 3}},
+		{"host:web", nil},
+		{"h?st=db*", []int{2}},
+		{"health*", []int{1}},
+		{"web*full", nil},
+		{`msg:"disk full"`, []int{0}},
+		{`"AND retry"`, []int{3}},
+		{"level:warn OR level:info", []int{1, 3}},
+		{"", []int{0, 1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			filterFunc, err := QueryFilterFuncFactory(tt.query, false)
+			if err != nil {
+				t.Fatalf("QueryFilterFuncFactory() error = %v", err)
+			}
+
+			var got []int
+			for i, line := range lines {
+				if _, _, matched := filterFunc(line); matched {
+					got = append(got, i)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%q matched lines %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	config.User().FileFormat = formats.FormatLogfmt
+	config.User().FileFormatFields = []string{"host"}
+	defer func() {
+		config.User().FileFormat = ""
+		config.User().FileFormatFields = nil
+	}()
+
+	tests := []struct {
+		query string
+		// 1-based like in the error message
+		pos int
+		msg string
+	}{
+		{"(a OR b", 1, "missing )"},
+		{"a)", 2, "unexpected )"},
+		{"a OR", 5, "missing term"},
+		{"AND a", 1, "unexpected AND"},
+		{"NOT", 4, "missing term"},
+		{`nohost:"x"`, 1, "unknown field nohost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := parseQuery(tt.query, false)
+			var queryErr *QueryError
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("parseQuery() error = %v, want a QueryError", err)
+			}
+			if queryErr.Pos+1 != tt.pos || queryErr.Msg != tt.msg {
+				t.Errorf("parseQuery() error = %q at %d, want %q at %d",
+					queryErr.Msg, queryErr.Pos+1, tt.msg, tt.pos)
+			}
+		})
+	}
+}
+
+// Field names of presets only get known once the file type got identified.
+func TestQueryFilterReparse(t *testing.T) {
+	defer func() {
+		config.User().FileFormat = ""
+		config.User().FileFormatFields = nil
+	}()
+
+	texts := []string{"host=web-01 msg=a", "host=db-01 msg=web"}
+	source := newTestSource(texts)
+	query := NewStringFilter(QueryFilterFuncFactory, config.FilterMatch)
+	pp := &Pipeline{}
+	pp.Add(source)
+	pp.Add(query)
+	if err := query.SetKey("", "host:web*"); err != nil {
+		t.Fatalf("SetKey() error = %v", err)
+	}
+
+	// not a field yet, so it's just text
+	if got := shownLines(t, query, len(texts)); got != nil {
+		t.Errorf("shown lines = %q, want none", got)
+	}
+
+	config.User().FileFormat = formats.FormatLogfmt
+	config.User().FileFormatFields = []string{"host", "msg"}
+	pp.ReparseKeys()
+
+	want := []string{"host=web-01 msg=a"}
+	if got := shownLines(t, query, len(texts)); !slices.Equal(got, want) {
+		t.Errorf("shown lines = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,59 @@
+package filter
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/claude42/infiltrator/model/formats"
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+// SeverityFilter hides all lines below a threshold. Lines without a level
+// are kept, there's no telling how important they are.
+type SeverityFilter struct {
+	FilterImpl
+	sync.Mutex
+
+	threshold lines.Severity
+}
+
+func NewSeverityFilter() *SeverityFilter {
+	return &SeverityFilter{}
+}
+
+// SetKey sets the threshold, e.g. "warning". An empty key shows all lines.
+func (s *SeverityFilter) SetKey(name string, key string) error {
+	// don't care about the name
+	threshold := lines.SeverityNone
+	if key != "" {
+		threshold = formats.ParseSeverity(key)
+		if threshold == lines.SeverityNone {
+			return fmt.Errorf("unknown severity %s", key)
+		}
+	}
+
+	s.Lock()
+	s.threshold = threshold
+	s.Unlock()
+	return nil
+}
+
+func (s *SeverityFilter) GetLine(lineNo int) (*lines.Line, error) {
+	sourceLine, err := s.source.GetLine(lineNo)
+	if err != nil {
+		return sourceLine, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if sourceLine.Status == lines.LineHidden || sourceLine.Marker {
+		return sourceLine, nil
+	}
+
+	if sourceLine.Severity != lines.SeverityNone && sourceLine.Severity < s.threshold {
+		sourceLine.Status = lines.LineHidden
+	}
+
+	return sourceLine, nil
+}
@@ -0,0 +1,89 @@
+package filter
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+func TestSeverityFilter(t *testing.T) {
+	texts := []string{
+		"2024-03-05 10:00:00 DEBUG starting",
+		"2024-03-05 10:00:01 INFO started",
+		"2024-03-05 10:00:02 ERROR failed",
+		"java.lang.NullPointerException",
+		"\tat Main.run(Main.java:42)",
+		"2024-03-05 10:00:03 WARN retrying",
+		"a line without any level",
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		// group lines into records like a detected format does
+		recordStart *regexp.Regexp
+		want        string
+	}{
+		{name: "no threshold", key: "", want: "xxxxxxx"},
+		{name: "warning", key: "warning", want: "--xxxxx"},
+		{name: "error", key: "err", want: "--xxx-x"},
+		{name: "syslog number", key: "6", want: "-xxxxxx"},
+		{name: "continuation lines inherit the level", key: "err",
+			recordStart: regexp.MustCompile(`^\d{4}-`), want: "--xxx--"},
+		{name: "continuation lines inherit the level below the threshold", key: "critical",
+			recordStart: regexp.MustCompile(`^\d{4}-`), want: "-------"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newTestSource(texts)
+			source.SetRecordStart(tt.recordStart)
+			severityFilter := NewSeverityFilter()
+			if err := severityFilter.SetKey("", tt.key); err != nil {
+				t.Fatalf("SetKey(%q) error = %v", tt.key, err)
+			}
+
+			pp := &Pipeline{}
+			pp.Add(source)
+			pp.Add(NewCache())
+			pp.Add(severityFilter)
+
+			if got := visibility(t, pp, len(texts)); got != tt.want {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeverityFilterUnknownKey(t *testing.T) {
+	if err := NewSeverityFilter().SetKey("", "verbose"); err == nil {
+		t.Errorf("SetKey(verbose) error = nil, want error")
+	}
+}
+
+// A continuation line with a level of its own keeps it.
+func TestSourceSeverityInheritance(t *testing.T) {
+	source := newTestSource([]string{
+		"2024-03-05 10:00:02 ERROR failed",
+		"  caused by",
+		"  WARN nested",
+		"  more",
+		"2024-03-05 10:00:03 something",
+		"  still nothing",
+	})
+	source.SetRecordStart(regexp.MustCompile(`^\d{4}-`))
+
+	want := []lines.Severity{lines.SeverityError, lines.SeverityError,
+		lines.SeverityWarning, lines.SeverityWarning, lines.SeverityNone, lines.SeverityNone}
+	// backwards, so no line is known already
+	for i := len(want) - 1; i >= 0; i-- {
+		line, err := source.GetLine(i)
+		if err != nil {
+			t.Fatalf("GetLine(%d) error = %v", i, err)
+		}
+		if line.Severity != want[i] {
+			t.Errorf("GetLine(%d).Severity = %v, want %v", i, line.Severity, want[i])
+		}
+	}
+}
@@ -1,16 +1,55 @@
 package filter
 
 import (
+	"fmt"
 	"log"
+	"math"
+	"os"
+	"regexp"
+	"sync"
 
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/formats"
 	"github.com/claude42/infiltrator/model/lines"
 	"github.com/claude42/infiltrator/util"
 )
 
+// how many lines read on demand from an indexed file are kept in memory
+const materializedLines = 10_000
+
+// records can't grow any bigger, just in case the record start regex never
+// matches
+const maxRecordLines = 1000
+
+const (
+	recordStartUnknown int8 = iota
+	recordStartYes
+	recordStartNo
+)
+
 type Source struct {
 	FilterImpl
+	sync.Mutex
 	lines []*lines.Line
 	width int
+
+	// Huge files don't get read into memory. Instead only the offsets where
+	// each line ends get stored and lines are read from the file on demand.
+	// Lines stored later on (e.g. in follow mode) are kept in memory and come
+	// after the indexed ones.
+	file         *os.File
+	filePath     string
+	ends         []int64
+	materialized *lineLRU
+
+	// Lines not matching recordStart are continuation lines (e.g. of a stack
+	// trace) and belong to the record of the line before. recordStarts caches
+	// whether a line matched.
+	recordStart  *regexp.Regexp
+	recordStarts []int8
+
+	// severity of each line plus one, 0 means not known yet
+	severities []int8
 }
 
 func NewSource() *Source {
@@ -25,31 +64,288 @@ func (s *Source) calculateNewWidthFrom(start int) {
 	}
 }
 
+// Line numbers get (re)assigned here so that several readers can feed the
+// same source.
 func (s *Source) StoreNewLines(newLines []*lines.Line) int {
-	start := len(s.lines)
+	s.Lock()
+	defer s.Unlock()
+
+	start := len(s.ends) + len(s.lines)
+	for i, line := range newLines {
+		line.No = start + i
+	}
+	oldLength := len(s.lines)
 	s.lines = append(s.lines, newLines...)
-	s.calculateNewWidthFrom(start)
-	return len(s.lines)
+	s.calculateNewWidthFrom(oldLength)
+	return len(s.ends) + len(s.lines)
+}
+
+// StoreNewIndex adds the end offsets of further lines of filePath. Must not be
+// called anymore once StoreNewLines() has been called.
+func (s *Source) StoreNewIndex(filePath string, ends []int64) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.file == nil {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return 0, fmt.Errorf("error opening %s: %w", filePath, err)
+		}
+		s.file = file
+		s.filePath = filePath
+		s.materialized = newLineLRU(materializedLines)
+	}
+
+	maxWidth := math.MaxInt
+	if maxLineLength := config.User().MaxLineLength; maxLineLength > 0 {
+		maxWidth = maxLineLength + len(lines.TruncatedMarker)
+	}
+
+	start := len(s.ends)
+	s.ends = append(s.ends, ends...)
+	for i := start; i < len(s.ends); i++ {
+		// width might be off by one or two because of line endings, that's ok
+		s.width = max(s.width, min(maxWidth, int(s.ends[i]-s.lineStart(i))))
+	}
+
+	return len(s.ends) + len(s.lines), nil
 }
 
 func (s *Source) Size() (int, int) {
-	return s.width, len(s.lines)
+	s.Lock()
+	defer s.Unlock()
+
+	return s.width, len(s.ends) + len(s.lines)
 }
 
 func (s *Source) Length() int {
-	return len(s.lines)
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.ends) + len(s.lines)
 }
 
+// GetLine hands out the line itself, filters change it in place. See
+// forgetter for what this means for filters looking at other lines.
 func (s *Source) GetLine(line int) (*lines.Line, error) {
-	length := len(s.lines)
+	s.Lock()
+	defer s.Unlock()
+
+	length := len(s.ends) + len(s.lines)
 
 	if line < 0 || line >= length {
 		return lines.NonExistingLine, util.ErrOutOfBounds
 	}
 
-	s.lines[line].CleanUp()
+	sourceLine, err := s.lineAt(line)
+	if err != nil {
+		return sourceLine, err
+	}
+
+	sourceLine.CleanUp()
+	sourceLine.Severity = s.severity(line)
+
+	return sourceLine, nil
+}
+
+// does not lock!
+func (s *Source) lineAt(line int) (*lines.Line, error) {
+	if line < len(s.ends) {
+		return s.materialize(line)
+	}
+
+	return s.lines[line-len(s.ends)], nil
+}
+
+func (s *Source) materialize(lineNo int) (*lines.Line, error) {
+	if line, ok := s.materialized.get(lineNo); ok {
+		return line, nil
+	}
+
+	start := s.lineStart(lineNo)
+	length := s.ends[lineNo] - start
+
+	// don't even read what would get truncated anyways, but enough to see
+	// the line ending
+	maxLineLength := config.User().MaxLineLength
+	readLength := length
+	if maxLineLength > 0 {
+		readLength = min(length, int64(maxLineLength)+2)
+	}
+
+	buf := make([]byte, readLength)
+	_, err := s.file.ReadAt(buf, start)
+	if err != nil {
+		return lines.NonExistingLine, fmt.Errorf("error reading %s: %w", s.filePath, err)
+	}
+
+	if readLength == length {
+		// same as bufio.ScanLines
+		if len(buf) > 0 && buf[len(buf)-1] == '\n' {
+			buf = buf[:len(buf)-1]
+		}
+		if len(buf) > 0 && buf[len(buf)-1] == '\r' {
+			buf = buf[:len(buf)-1]
+		}
+	}
+
+	// same as scanLinesTruncated
+	if maxLineLength > 0 && len(buf) > maxLineLength {
+		buf = append(buf[:maxLineLength], lines.TruncatedMarker...)
+	}
+
+	line := lines.NewLine(lineNo, string(buf))
+	line.Origin = s.filePath
+	s.materialized.put(lineNo, line)
+
+	return line, nil
+}
+
+// SetRecordStart groups lines into multi-line records. Each line matching
+// regex starts a new record. nil means each line is a record of its own.
+func (s *Source) SetRecordStart(regex *regexp.Regexp) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.recordStart = regex
+	s.recordStarts = nil
+	s.severities = nil
+}
+
+// ResetSeverities makes sure severities get determined again, e.g. after the
+// format of the file became known.
+func (s *Source) ResetSeverities() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.severities = nil
+}
+
+func (s *Source) Record(lineNo int) ([]string, int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	length := len(s.ends) + len(s.lines)
+	if lineNo < 0 || lineNo >= length {
+		return nil, 0, util.ErrOutOfBounds
+	}
+
+	if s.recordStart == nil {
+		line, err := s.lineAt(lineNo)
+		return []string{line.Str}, 0, err
+	}
+
+	head := lineNo
+	for head > 0 && lineNo-head < maxRecordLines && !s.startsRecord(head) {
+		head--
+	}
+
+	end := lineNo + 1
+	for end < length && end-head < maxRecordLines && !s.startsRecord(end) {
+		end++
+	}
+
+	record := make([]string, 0, end-head)
+	for i := head; i < end; i++ {
+		line, err := s.lineAt(i)
+		if err != nil {
+			return nil, 0, err
+		}
+		record = append(record, line.Str)
+	}
+
+	return record, lineNo - head, nil
+}
+
+// ContinuesRecord returns true if lineNo is a continuation line, i.e. belongs
+// to the same record as the line before.
+func (s *Source) ContinuesRecord(lineNo int) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.recordStart == nil || lineNo <= 0 || lineNo >= len(s.ends)+len(s.lines) {
+		return false
+	}
+
+	return !s.startsRecord(lineNo)
+}
+
+// does not lock! Lines which can't be read and marker lines always start a
+// record.
+func (s *Source) startsRecord(lineNo int) bool {
+	if lineNo >= len(s.recordStarts) {
+		length := len(s.ends) + len(s.lines)
+		s.recordStarts = append(s.recordStarts,
+			make([]int8, length-len(s.recordStarts))...)
+	}
+
+	switch s.recordStarts[lineNo] {
+	case recordStartYes:
+		return true
+	case recordStartNo:
+		return false
+	}
 
-	return s.lines[line], nil
+	starts := true
+	line, err := s.lineAt(lineNo)
+	if err == nil && !line.Marker {
+		starts = s.recordStart.MatchString(line.Str)
+	}
+
+	if starts {
+		s.recordStarts[lineNo] = recordStartYes
+	} else {
+		s.recordStarts[lineNo] = recordStartNo
+	}
+	return starts
+}
+
+// does not lock! Continuation lines get the severity of the record they belong
+// to, unless they have one of their own.
+func (s *Source) severity(lineNo int) lines.Severity {
+	if lineNo >= len(s.severities) {
+		length := len(s.ends) + len(s.lines)
+		s.severities = append(s.severities,
+			make([]int8, length-len(s.severities))...)
+	}
+
+	if s.severities[lineNo] != 0 {
+		return lines.Severity(s.severities[lineNo] - 1)
+	}
+
+	severity := s.ownSeverity(lineNo)
+	if severity == lines.SeverityNone && s.recordStart != nil {
+		// walk back to the closest line which is either known already or
+		// has a severity of its own
+		for i := lineNo; i > 0 && lineNo-i < maxRecordLines && !s.startsRecord(i); i-- {
+			if s.severities[i-1] != 0 {
+				severity = lines.Severity(s.severities[i-1] - 1)
+				break
+			}
+			if severity = s.ownSeverity(i - 1); severity != lines.SeverityNone {
+				break
+			}
+		}
+	}
+
+	s.severities[lineNo] = int8(severity) + 1
+	return severity
+}
+
+// does not lock!
+func (s *Source) ownSeverity(lineNo int) lines.Severity {
+	line, err := s.lineAt(lineNo)
+	if err != nil || line.Marker {
+		return lines.SeverityNone
+	}
+	return formats.Severity(line.Str)
+}
+
+func (s *Source) lineStart(lineNo int) int64 {
+	if lineNo == 0 {
+		return 0
+	}
+	return s.ends[lineNo-1]
 }
 
 func (s *Source) SetSource(source Filter) {
@@ -60,10 +356,54 @@ func (s *Source) SetColorIndex(colorIndex uint8) {
 	// Buffers don't have a color
 }
 
+// FirstLines returns copies of up to n lines from the beginning of the source
+// without touching the lines in the pipeline.
+func (s *Source) FirstLines(n int) []*lines.Line {
+	s.Lock()
+	defer s.Unlock()
+
+	n = min(n, len(s.ends)+len(s.lines))
+	firstLines := make([]*lines.Line, 0, n)
+	for i := range n {
+		line, err := s.lineAt(i)
+		if err != nil {
+			break
+		}
+		firstLines = append(firstLines, lines.NewLine(i, line.Str))
+	}
+
+	return firstLines
+}
+
+// Text returns the text of lineNo without touching the line in the pipeline.
+func (s *Source) Text(lineNo int) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if lineNo < 0 || lineNo >= len(s.ends)+len(s.lines) {
+		return "", util.ErrOutOfBounds
+	}
+
+	line, err := s.lineAt(lineNo)
+	if err != nil {
+		return "", err
+	}
+	return line.Str, nil
+}
+
 func (s *Source) IsEmpty() bool {
-	return len(s.lines) == 0
+	return s.Length() == 0
 }
 
 func (s *Source) LastLine() *lines.Line {
-	return s.lines[len(s.lines)-1]
+	s.Lock()
+	defer s.Unlock()
+
+	length := len(s.ends) + len(s.lines)
+	if length == 0 {
+		return lines.NonExistingLine
+	}
+
+	line, _ := s.lineAt(length - 1)
+	return line
 }
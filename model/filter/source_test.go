@@ -0,0 +1,163 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+// Writes content to a file and stores its line ends in a new source, like
+// reader.IndexFile() does.
+func newIndexedSource(t *testing.T, content string) *Source {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "indexed.log")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var ends []int64
+	for i, c := range content {
+		if c == '\n' {
+			ends = append(ends, int64(i+1))
+		}
+	}
+	if !strings.HasSuffix(content, "\n") && content != "" {
+		ends = append(ends, int64(len(content)))
+	}
+
+	source := NewSource()
+	if _, err := source.StoreNewIndex(path, ends); err != nil {
+		t.Fatalf("StoreNewIndex() error = %v", err)
+	}
+	t.Cleanup(func() { source.file.Close() })
+	return source
+}
+
+func sourceTexts(t *testing.T, source *Source) []string {
+	t.Helper()
+
+	var texts []string
+	for i := range source.Length() {
+		line, err := source.GetLine(i)
+		if err != nil {
+			t.Fatalf("GetLine(%d) error = %v", i, err)
+		}
+		if line.No != i {
+			t.Errorf("GetLine(%d).No = %d", i, line.No)
+		}
+		texts = append(texts, line.Str)
+	}
+	return texts
+}
+
+func TestSourceMaterialize(t *testing.T) {
+	truncated := "12345" + lines.TruncatedMarker
+
+	tests := []struct {
+		name          string
+		content       string
+		maxLineLength int
+		want          []string
+	}{
+		{
+			name:    "lf",
+			content: "a\nbc\n",
+			want:    []string{"a", "bc"},
+		},
+		{
+			name:    "crlf",
+			content: "a\r\nbc\r\n",
+			want:    []string{"a", "bc"},
+		},
+		{
+			name:    "last line without line ending",
+			content: "a\nbc",
+			want:    []string{"a", "bc"},
+		},
+		{
+			name:    "empty lines",
+			content: "\n\na\n",
+			want:    []string{"", "", "a"},
+		},
+		{
+			name:          "truncated",
+			content:       "123456789\nab\n",
+			maxLineLength: 5,
+			want:          []string{truncated, "ab"},
+		},
+		{
+			name:          "exactly max with crlf",
+			content:       "12345\r\nab\r\n",
+			maxLineLength: 5,
+			want:          []string{"12345", "ab"},
+		},
+		{
+			name:          "one too long with crlf",
+			content:       "123456\r\nab\r\n",
+			maxLineLength: 5,
+			want:          []string{truncated, "ab"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxLineLength := config.User().MaxLineLength
+			defer func() { config.User().MaxLineLength = maxLineLength }()
+			config.User().MaxLineLength = tt.maxLineLength
+
+			source := newIndexedSource(t, tt.content)
+			if got := sourceTexts(t, source); !slices.Equal(got, tt.want) {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceMaterializeEviction(t *testing.T) {
+	source := newIndexedSource(t, "a\nb\nc\n")
+	source.materialized = newLineLRU(2)
+
+	want := []string{"a", "b", "c"}
+	if got := sourceTexts(t, source); !slices.Equal(got, want) {
+		t.Fatalf("lines = %q, want %q", got, want)
+	}
+	if _, ok := source.materialized.get(0); ok {
+		t.Error("line 0 still materialized, want it evicted")
+	}
+
+	// read again after being evicted
+	line, err := source.GetLine(0)
+	if err != nil {
+		t.Fatalf("GetLine(0) error = %v", err)
+	}
+	if line.Str != "a" {
+		t.Errorf("GetLine(0) = %q, want %q", line.Str, "a")
+	}
+}
+
+// In follow mode new lines come after the indexed ones.
+func TestSourceIndexedAndFollowed(t *testing.T) {
+	source := newIndexedSource(t, "a\nb\n")
+
+	newLines := []*lines.Line{lines.NewLine(0, "c"), lines.NewLine(0, "d")}
+	if length := source.StoreNewLines(newLines); length != 4 {
+		t.Errorf("StoreNewLines() = %d, want 4", length)
+	}
+	if newLines[0].No != 2 {
+		t.Errorf("first new line got number %d, want 2", newLines[0].No)
+	}
+
+	want := []string{"a", "b", "c", "d"}
+	if got := sourceTexts(t, source); !slices.Equal(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+	if _, err := source.GetLine(4); err == nil {
+		t.Error("GetLine(4) found a line, want error")
+	}
+}
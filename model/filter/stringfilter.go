@@ -5,9 +5,11 @@ import (
 	"sync"
 
 	"log"
+	"slices"
 	"strings"
 
 	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/formats"
 	"github.com/claude42/infiltrator/model/lines"
 )
 
@@ -19,8 +21,35 @@ type StringFilter struct {
 	mode              config.FilterMode
 	key               string
 	caseSensitive     bool
+
+	// if set, only lines coming from this file get filtered
+	origin string
+	// if set, only this field of each line gets matched
+	field string
+
+	// in match mode, how many lines before and after a match are shown as
+	// well (like grep -B and -A)
+	before int
+	after  int
+
+	// the record recordMatches() looked at last, its lines usually get asked
+	// for one after the other
+	lastRecord        []string
+	lastRecordStart   int
+	lastRecordMatched bool
+
+	// what context needs to know about other lines, see lineState()
+	states lineMemo
 }
 
+// how the filters before a StringFilter treat a line and whether it matches
+const (
+	lineUnknown int8 = iota
+	lineHiddenUpstream
+	lineShownUpstream
+	lineShownMatch
+)
+
 type StringFilterFuncFactory func(key string, caseSensitive bool) (func(input string) (string, [][]int, bool), error)
 
 func DefaultStringFilterFuncFactory(key string, caseSensitive bool) (func(input string) (string, [][]int, bool), error) {
@@ -82,6 +111,10 @@ func NewStringFilter(fn StringFilterFuncFactory, mode config.FilterMode) *String
 }
 
 func (s *StringFilter) updateFilterFunc(key string, caseSensitive bool) error {
+	s.Lock()
+	s.lastRecord = nil
+	s.Unlock()
+
 	var err error
 	if s.filterFuncFactory != nil {
 		s.filterFunc, err = s.filterFuncFactory(key, caseSensitive)
@@ -101,6 +134,15 @@ func (s *StringFilter) SetKey(name string, key string) error {
 	return s.updateFilterFunc(s.key, s.caseSensitive)
 }
 
+// ReparseKey parses the key again, e.g. field names in queries and comparisons
+// only get known once the file type has been identified.
+func (s *StringFilter) ReparseKey() error {
+	s.Lock()
+	key, caseSensitive := s.key, s.caseSensitive
+	s.Unlock()
+	return s.updateFilterFunc(key, caseSensitive)
+}
+
 func (s *StringFilter) SetCaseSensitive(on bool) error {
 	s.Lock()
 	s.caseSensitive = on
@@ -108,6 +150,60 @@ func (s *StringFilter) SetCaseSensitive(on bool) error {
 	return s.updateFilterFunc(s.key, s.caseSensitive)
 }
 
+func (s *StringFilter) SetOrigin(origin string) {
+	s.Lock()
+	s.origin = origin
+	s.Unlock()
+}
+
+func (s *StringFilter) SetField(field string) {
+	s.Lock()
+	s.field = field
+	s.lastRecord = nil
+	s.Unlock()
+}
+
+func (s *StringFilter) SetContext(before int, after int) {
+	s.Lock()
+	s.before = before
+	s.after = after
+	s.Unlock()
+}
+
+// LooksAhead returns how many lines before a match might become its context.
+func (s *StringFilter) LooksAhead() int {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.showsContext() {
+		return 0
+	}
+	return s.before
+}
+
+// ShowsContext returns true if lines around matches are shown as well.
+func (s *StringFilter) ShowsContext() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.showsContext()
+}
+
+// does not lock!
+func (s *StringFilter) showsContext() bool {
+	return s.mode == config.FilterMatch && s.key != "" && (s.before > 0 || s.after > 0)
+}
+
+// The record of a line might have been looked at before the key list got
+// reloaded, so that's forgotten as well.
+func (s *StringFilter) forget(from int, to int) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.states.forget(from, to)
+	s.lastRecord = nil
+}
+
 func (s *StringFilter) SetMode(mode config.FilterMode) {
 	s.Lock()
 	s.mode = mode
@@ -126,6 +222,7 @@ func (s *StringFilter) GetLine(line int) (*lines.Line, error) {
 	defer s.Unlock()
 
 	if sourceLine.Status == lines.LineHidden {
+		s.states.set(line, lineHiddenUpstream)
 		return sourceLine, nil
 	}
 
@@ -133,9 +230,33 @@ func (s *StringFilter) GetLine(line int) (*lines.Line, error) {
 		return sourceLine, nil
 	}
 
-	_, indeces, matched := s.filterFunc(sourceLine.Str)
+	if sourceLine.Marker || (s.origin != "" && sourceLine.Origin != s.origin) {
+		s.states.set(line, lineShownUpstream)
+		return sourceLine, nil
+	}
 
-	s.updateStatusAndMatched(matched, indeces, sourceLine)
+	indeces, matched := s.match(sourceLine.Str)
+
+	// with multi-line records a match anywhere in the record counts
+	recordMatched := matched && !zeroWidth(indeces)
+	if !recordMatched {
+		recordMatched, err = s.recordMatches(line)
+		if err != nil {
+			return sourceLine, err
+		}
+	}
+
+	if recordMatched {
+		s.states.set(line, lineShownMatch)
+	} else {
+		s.states.set(line, lineShownUpstream)
+	}
+
+	s.updateStatusAndMatched(matched, recordMatched, sourceLine)
+
+	if sourceLine.Status == lines.LineHidden && s.showsContext() {
+		s.updateStatusForContext(line, sourceLine)
+	}
 
 	if !matched {
 		// no further coloring necessary, bail out here
@@ -149,15 +270,74 @@ func (s *StringFilter) GetLine(line int) (*lines.Line, error) {
 	return sourceLine, nil
 }
 
-func (s *StringFilter) updateStatusAndMatched(matched bool, indeces [][]int, sourceLine *lines.Line) {
+// Matches either the whole text or only the field the filter is restricted to.
+// In the latter case text without this field never matches. Indeces are
+// always relative to the whole text.
+func (s *StringFilter) match(text string) ([][]int, bool) {
+	if s.field == "" {
+		_, indeces, matched := s.filterFunc(text)
+		return indeces, matched
+	}
+
+	start, end, ok := formats.FieldSpan(text, s.field)
+	if !ok {
+		return nil, false
+	}
+
+	_, indeces, matched := s.filterFunc(text[start:end])
+	for _, index := range indeces {
+		index[0] += start
+		index[1] += start
+	}
+	return indeces, matched
+}
+
+// e.g. "x*" matches everywhere, that shouldn't count. A match without any
+// positions (e.g. the query "NOT x") does count.
+func zeroWidth(indeces [][]int) bool {
+	return len(indeces) > 0 && indeces[0][0] == indeces[0][1]
+}
+
+// Whether there's a (non zero-width) match anywhere in the record of lineNo.
+func (s *StringFilter) recordMatches(lineNo int) (bool, error) {
+	record, pos, err := s.source.Record(lineNo)
+	if err != nil {
+		return false, err
+	}
+
+	if lineNo-pos == s.lastRecordStart && slices.Equal(record, s.lastRecord) {
+		return s.lastRecordMatched, nil
+	}
+
+	matched := false
+	for _, text := range record {
+		if indeces, ok := s.match(text); ok && !zeroWidth(indeces) {
+			matched = true
+			break
+		}
+	}
+
+	s.lastRecord = record
+	s.lastRecordStart = lineNo - pos
+	s.lastRecordMatched = matched
+	return matched, nil
+}
+
+// matched tells whether the line itself matched, recordMatched whether there
+// was a (non zero-width) match anywhere in the line's record. Status follows
+// the record, the Matched flag (used for jumping to the next match) only the
+// line itself.
+func (s *StringFilter) updateStatusAndMatched(matched bool, recordMatched bool,
+	sourceLine *lines.Line) {
+
 	newStatus := sourceLine.Status
 	newMatched := sourceLine.Matched
 	switch s.mode {
 	case config.FilterMatch:
 		// Status
-		if sourceLine.Status == lines.LineWithoutStatus && matched {
+		if sourceLine.Status == lines.LineWithoutStatus && (matched || recordMatched) {
 			newStatus = lines.LineMatched
-		} else if !matched {
+		} else if !matched && !recordMatched {
 			newStatus = lines.LineHidden
 		}
 
@@ -171,13 +351,13 @@ func (s *StringFilter) updateStatusAndMatched(matched bool, indeces [][]int, sou
 		// Status
 		switch sourceLine.Status {
 		case lines.LineWithoutStatus:
-			if matched {
+			if matched || recordMatched {
 				newStatus = lines.LineMatched
 			} else {
 				newStatus = lines.LineDimmed
 			}
 		case lines.LineMatched:
-			if !matched {
+			if !matched && !recordMatched {
 				newStatus = lines.LineDimmed
 			}
 		}
@@ -190,12 +370,12 @@ func (s *StringFilter) updateStatusAndMatched(matched bool, indeces [][]int, sou
 		}
 	case config.FilterHide:
 		// Status
-		if matched && indeces[0][1] != 0 {
+		if recordMatched {
 			newStatus = lines.LineHidden
 		}
 
 		// Matched
-		if sourceLine.Matched && matched &&
+		if sourceLine.Matched && recordMatched &&
 			(sourceLine.Status == lines.LineMatched || sourceLine.Status == lines.LineDimmed) {
 			newMatched = false
 		}
@@ -207,6 +387,44 @@ func (s *StringFilter) updateStatusAndMatched(matched bool, indeces [][]int, sou
 	sourceLine.Matched = newMatched
 }
 
+// Hidden lines close enough to a match are shown dimmed.
+func (s *StringFilter) updateStatusForContext(lineNo int, sourceLine *lines.Line) {
+	for i := max(0, lineNo-s.after); i <= lineNo+s.before; i++ {
+		if i != lineNo && s.lineState(i) == lineShownMatch {
+			sourceLine.Status = lines.LineDimmed
+			return
+		}
+	}
+}
+
+// Like GetLine() would find out, but without touching the line itself.
+// Matches hidden by the filters before this one don't have any context.
+func (s *StringFilter) lineState(lineNo int) int8 {
+	if state := s.states.get(lineNo); state != lineUnknown {
+		return state
+	}
+
+	// never went through GetLine() so far, so it's not cached either
+	line, err := s.source.GetLine(lineNo)
+	if err != nil {
+		return lineHiddenUpstream
+	}
+
+	state := lineShownUpstream
+	switch {
+	case line.Status == lines.LineHidden:
+		state = lineHiddenUpstream
+	case line.Marker || (s.origin != "" && line.Origin != s.origin):
+	default:
+		if matched, err := s.recordMatches(lineNo); err == nil && matched {
+			state = lineShownMatch
+		}
+	}
+
+	s.states.set(lineNo, state)
+	return state
+}
+
 func (s *StringFilter) colorizeLine(line *lines.Line, indeces [][]int) {
 	for _, index := range indeces {
 		for i := index[0]; i < index[1]; i++ {
@@ -0,0 +1,184 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+// m = matched, d = dimmed context, - = hidden
+func statuses(t *testing.T, pp *Pipeline, length int) string {
+	t.Helper()
+
+	var sb strings.Builder
+	for i := range length {
+		line, err := pp.GetLine(i)
+		if err != nil {
+			t.Fatalf("GetLine(%d) error = %v", i, err)
+		}
+		switch line.Status {
+		case lines.LineMatched:
+			sb.WriteString("m")
+		case lines.LineDimmed:
+			sb.WriteString("d")
+		case lines.LineHidden:
+			sb.WriteString("-")
+		default:
+			sb.WriteString("?")
+		}
+	}
+	return sb.String()
+}
+
+func newContextPipeline(t *testing.T, texts []string, key string, before int,
+	after int) (*Pipeline, *Source) {
+
+	t.Helper()
+
+	source := newTestSource(texts)
+	stringFilter := NewStringFilter(nil, config.FilterMatch)
+	if err := stringFilter.SetKey("", key); err != nil {
+		t.Fatalf("SetKey() error = %v", err)
+	}
+	stringFilter.SetContext(before, after)
+
+	pp := &Pipeline{}
+	pp.Add(source)
+	pp.Add(NewCache())
+	pp.Add(stringFilter)
+	return pp, source
+}
+
+func TestStringFilterContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		texts  []string
+		before int
+		after  int
+		want   string
+	}{
+		{
+			name:  "no context",
+			texts: []string{"a", "x", "b", "c"},
+			want:  "-m--",
+		},
+		{
+			name:   "before",
+			texts:  []string{"a", "b", "c", "x", "d"},
+			before: 2,
+			want:   "-ddm-",
+		},
+		{
+			name:  "after",
+			texts: []string{"x", "a", "b", "c"},
+			after: 2,
+			want:  "mdd-",
+		},
+		{
+			name:   "overlapping",
+			texts:  []string{"x", "a", "b", "x", "c", "d", "e"},
+			before: 1,
+			after:  1,
+			want:   "mddmd--",
+		},
+		{
+			name:   "at the edges",
+			texts:  []string{"a", "x", "b"},
+			before: 3,
+			after:  3,
+			want:   "dmd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pp, _ := newContextPipeline(t, tt.texts, "x", tt.before, tt.after)
+
+			if got := statuses(t, pp, len(tt.texts)); got != tt.want {
+				t.Errorf("statuses = %q, want %q", got, tt.want)
+			}
+			// now from the cache
+			if got := statuses(t, pp, len(tt.texts)); got != tt.want {
+				t.Errorf("cached statuses = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Context lines before a match aren't asked for first, asking for them
+// mustn't change the lines already cached.
+func TestStringFilterContextBackwards(t *testing.T) {
+	texts := []string{"a", "b", "x", "c", "d", "x"}
+	pp, _ := newContextPipeline(t, texts, "x", 1, 1)
+
+	for i := len(texts) - 1; i >= 0; i-- {
+		if _, err := pp.GetLine(i); err != nil {
+			t.Fatalf("GetLine(%d) error = %v", i, err)
+		}
+	}
+
+	if got, want := statuses(t, pp, len(texts)), "-dmddm"; got != want {
+		t.Errorf("statuses = %q, want %q", got, want)
+	}
+}
+
+func TestFindNonHiddenLineWithContext(t *testing.T) {
+	texts := []string{"a", "x", "b", "c", "d", "e", "x", "f"}
+	pp, _ := newContextPipeline(t, texts, "x", 1, 1)
+
+	tests := []struct {
+		lineNo    int
+		direction ScrollDirection
+		want      int
+	}{
+		{lineNo: 2, direction: DirectionDown, want: 5},
+		{lineNo: 5, direction: DirectionUp, want: 2},
+		{lineNo: 0, direction: DirectionDown, want: 1},
+		{lineNo: 6, direction: DirectionDown, want: 7},
+	}
+
+	for _, tt := range tests {
+		line, err := pp.FindNonHiddenLine(tt.lineNo, tt.direction)
+		if err != nil {
+			t.Errorf("FindNonHiddenLine(%d, %d) error = %v", tt.lineNo,
+				tt.direction, err)
+			continue
+		}
+		if line.No != tt.want {
+			t.Errorf("FindNonHiddenLine(%d, %d) = %d, want %d", tt.lineNo,
+				tt.direction, line.No, tt.want)
+		}
+	}
+
+	if _, err := pp.FindNonHiddenLine(7, DirectionDown); err == nil {
+		t.Error("FindNonHiddenLine(7, DirectionDown) found a line, want error")
+	}
+}
+
+// In follow mode a new match turns lines already cached into its context.
+func TestStringFilterContextFollow(t *testing.T) {
+	texts := []string{"x", "a", "b", "c", "d"}
+	pp, source := newContextPipeline(t, texts, "x", 2, 1)
+
+	if got, want := statuses(t, pp, len(texts)), "md---"; got != want {
+		t.Fatalf("statuses = %q, want %q", got, want)
+	}
+
+	newLines := []*lines.Line{lines.NewLine(0, "x"), lines.NewLine(0, "e")}
+	source.StoreNewLines(newLines)
+	first := newLines[0].No
+	lookAhead := pp.LooksAhead(first)
+	if lookAhead != 2 {
+		t.Fatalf("LooksAhead() = %d, want 2", lookAhead)
+	}
+	pp.InvalidateCachedLines(first-lookAhead, first)
+
+	if got, want := statuses(t, pp, 7), "md-ddmd"; got != want {
+		t.Errorf("statuses = %q, want %q", got, want)
+	}
+	if !pp.ShowsContext() {
+		t.Error("ShowsContext() = false, want true")
+	}
+}
@@ -0,0 +1,122 @@
+package filter
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/claude42/infiltrator/model/lines"
+	"github.com/claude42/infiltrator/model/templates"
+)
+
+// TemplateSelection tells the template filter what to do with which
+// template. Templates are referred to by their pattern as IDs change each
+// time the templates get mined.
+type TemplateSelection struct {
+	Hidden []string
+	// only lines of this template are shown
+	Focused string
+	// lines of this template count as matches
+	Marked string
+	// lines of rare templates count as matches and get highlighted
+	Anomalies bool
+}
+
+// TemplateFilter hides or marks lines depending on their template. It's not
+// part of any panel but gets added once templates got mined.
+type TemplateFilter struct {
+	FilterImpl
+	sync.Mutex
+
+	index     *templates.Index
+	selection TemplateSelection
+
+	// the selection translated to the IDs of the current index, -1 if none
+	hidden  map[int]bool
+	focused int
+	marked  int
+}
+
+func NewTemplateFilter() *TemplateFilter {
+	return &TemplateFilter{focused: -1, marked: -1}
+}
+
+func (t *TemplateFilter) SetIndex(index *templates.Index) {
+	t.Lock()
+	t.index = index
+	t.resolveSelection()
+	t.Unlock()
+}
+
+func (t *TemplateFilter) SetSelection(selection TemplateSelection) {
+	t.Lock()
+	t.selection = selection
+	t.resolveSelection()
+	t.Unlock()
+}
+
+// does not lock!
+func (t *TemplateFilter) resolveSelection() {
+	t.hidden = make(map[int]bool)
+	t.focused, t.marked = -1, -1
+	if t.index == nil {
+		return
+	}
+
+	for _, template := range t.index.Templates() {
+		pattern := template.String()
+		if slices.Contains(t.selection.Hidden, pattern) {
+			t.hidden[template.ID] = true
+		}
+		if pattern == t.selection.Focused {
+			t.focused = template.ID
+		}
+		if pattern == t.selection.Marked {
+			t.marked = template.ID
+		}
+	}
+}
+
+func (t *TemplateFilter) GetLine(lineNo int) (*lines.Line, error) {
+	sourceLine, err := t.source.GetLine(lineNo)
+	if err != nil {
+		return sourceLine, err
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	if t.index == nil || sourceLine.Status == lines.LineHidden || sourceLine.Marker {
+		return sourceLine, nil
+	}
+
+	template := t.index.Template(lineNo, sourceLine.Str)
+	if template == nil {
+		// a new kind of line, only hidden if focusing on something else
+		if t.focused != -1 {
+			sourceLine.Status = lines.LineHidden
+			sourceLine.Matched = false
+		}
+		return sourceLine, nil
+	}
+
+	if t.hidden[template.ID] || (t.focused != -1 && template.ID != t.focused) {
+		sourceLine.Status = lines.LineHidden
+		sourceLine.Matched = false
+		return sourceLine, nil
+	}
+
+	marked := template.ID == t.marked
+	if t.selection.Anomalies && t.index.IsRare(template) {
+		sourceLine.Anomaly = true
+		marked = true
+	}
+	if marked {
+		// like a match of a string filter in match mode
+		sourceLine.Matched = true
+		if sourceLine.Status == lines.LineWithoutStatus {
+			sourceLine.Status = lines.LineMatched
+		}
+	}
+
+	return sourceLine, nil
+}
@@ -0,0 +1,58 @@
+package filter
+
+import "strings"
+
+// separates the values given to ValuesFilterFuncFactory, within values it's
+// escaped with a backslash
+const valuesSeparator = ','
+
+// ValuesFilterFuncFactory matches if the whole input is one of the values in
+// key, e.g. "sshd,cron" as created by JoinValues. Meant for filters
+// restricted to a field, e.g. the host of syslog lines.
+func ValuesFilterFuncFactory(key string, caseSensitive bool) (func(input string) (string, [][]int, bool), error) {
+	values := SplitValues(key)
+
+	return func(input string) (string, [][]int, bool) {
+		for _, value := range values {
+			if input == value || (!caseSensitive && strings.EqualFold(input, value)) {
+				return input, [][]int{{0, len(input)}}, true
+			}
+		}
+
+		return "", nil, false
+	}, nil
+}
+
+// JoinValues turns values into a key for ValuesFilterFuncFactory.
+func JoinValues(values []string) string {
+	escaped := make([]string, len(values))
+	for i, value := range values {
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		escaped[i] = strings.ReplaceAll(value, string(valuesSeparator), `\`+string(valuesSeparator))
+	}
+	return strings.Join(escaped, string(valuesSeparator))
+}
+
+// SplitValues is the reverse of JoinValues. An empty key results in no
+// values at all.
+func SplitValues(key string) []string {
+	if key == "" {
+		return nil
+	}
+
+	var values []string
+	var sb strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; {
+		case c == '\\' && i+1 < len(key):
+			i++
+			sb.WriteByte(key[i])
+		case c == valuesSeparator:
+			values = append(values, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return append(values, sb.String())
+}
@@ -0,0 +1,57 @@
+package filter
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestJoinValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		key    string
+	}{
+		{"none", nil, ""},
+		{"one", []string{"sshd"}, "sshd"},
+		{"several", []string{"sshd", "cron"}, "sshd,cron"},
+		{"with separator", []string{"a,b", "c"}, `a\,b,c`},
+		{"with backslash", []string{`C:\logs`, `x\`}, `C:\\logs,x\\`},
+		{"empty value", []string{"", "a"}, ",a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := JoinValues(tt.values)
+			if key != tt.key {
+				t.Errorf("JoinValues() = %q, want %q", key, tt.key)
+			}
+			if values := SplitValues(key); !slices.Equal(values, tt.values) {
+				t.Errorf("SplitValues(%q) = %q, want %q", key, values, tt.values)
+			}
+		})
+	}
+}
+
+func TestValuesFilter(t *testing.T) {
+	filterFunc, err := ValuesFilterFuncFactory(JoinValues([]string{"web,01", "db"}), false)
+	if err != nil {
+		t.Fatalf("ValuesFilterFuncFactory() error = %v", err)
+	}
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"web,01", true},
+		{"DB", true},
+		{"web", false},
+		{"01", false},
+		{"db2", false},
+	}
+
+	for _, tt := range tests {
+		if _, _, matched := filterFunc(tt.input); matched != tt.want {
+			t.Errorf("%q matched = %v, want %v", tt.input, matched, tt.want)
+		}
+	}
+}
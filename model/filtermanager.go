@@ -2,9 +2,12 @@ package model
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"path/filepath"
 	"runtime/debug"
 	"sync"
+	"time"
 
 	"github.com/claude42/infiltrator/config"
 	"github.com/claude42/infiltrator/fail"
@@ -16,6 +19,12 @@ import (
 	"github.com/claude42/infiltrator/util"
 )
 
+// how often relative date filters get moved along with the current time
+const dateReevaluationInterval = time.Second
+
+// how often keyword list files are checked for changes
+const keywordListReloadInterval = time.Second
+
 var (
 	filterManagerInstance *FilterManager
 	identifyFileTypeOnce  sync.Once
@@ -29,15 +38,20 @@ type FilterManager struct {
 	quit chan<- string
 
 	readerCancelFunc context.CancelFunc
+	// closed once the currently running command has exited
+	commandDone chan struct{}
 
 	refresherCancelFunc context.CancelFunc
 	refresherWg         sync.WaitGroup
 
 	contentUpdate  chan []*lines.Line
+	indexUpdate    chan []int64
 	commandChannel chan Command
 
 	filters     filter.Pipeline
 	currentLine int
+	// only there once templates got mined
+	templateFilter *filter.TemplateFilter
 
 	display *Display
 }
@@ -55,6 +69,7 @@ func NewFilterManager(ctx context.Context, wg *sync.WaitGroup, quit chan<- strin
 		quit:           quit,
 		display:        NewDisplay(),
 		contentUpdate:  make(chan []*lines.Line, 10),
+		indexUpdate:    make(chan []int64),
 		commandChannel: make(chan Command, 10),
 	}
 
@@ -65,7 +80,7 @@ func NewFilterManager(ctx context.Context, wg *sync.WaitGroup, quit chan<- strin
 	return fm
 }
 
-func (fm *FilterManager) ReadFromFile(filePath string) {
+func (fm *FilterManager) ReadFromFiles(filePaths []string) {
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -74,14 +89,78 @@ func (fm *FilterManager) ReadFromFile(filePath string) {
 		}
 	}()
 
+	cfg := config.User()
+	if cfg.Rotated || len(filePaths) > 1 {
+		family, err := reader.RotationFamily(filePaths, cfg.Rotated)
+		if err == nil {
+			filePaths = family
+
+			// the newest file is the one that will be followed
+			cfg.FilePath = filePaths[len(filePaths)-1]
+			cfg.FileName = filepath.Base(cfg.FilePath)
+		} else if cfg.Rotated {
+			fm.quit <- err.Error()
+			close(fm.quit)
+			return
+		} else {
+			// unrelated files get merged by their timestamps. Paths get
+			// cleaned so they can be compared to the origin of each line.
+			for i := range filePaths {
+				filePaths[i] = filepath.Clean(filePaths[i])
+			}
+			cfg.Merge = true
+			cfg.FilePaths = filePaths
+			cfg.FileName = fmt.Sprintf("%d files", len(filePaths))
+		}
+	}
+
 	var readCtx context.Context
 	readCtx, fm.readerCancelFunc = context.WithCancel(fm.ctx)
 	fm.wg.Add(1)
-	go reader.GetReader().ReadFromFile(readCtx, fm.wg, fm.quit, filePath,
-		fm.contentUpdate, config.User().Follow)
+	if cfg.Merge {
+		go reader.GetReader().MergeFiles(readCtx, fm.wg, fm.quit, filePaths,
+			fm.contentUpdate)
+	} else if len(filePaths) == 1 && reader.GetReader().ShouldIndex(filePaths[0]) {
+		go reader.GetReader().IndexFile(readCtx, fm.wg, fm.quit, filePaths[0],
+			fm.indexUpdate, fm.contentUpdate)
+	} else {
+		go reader.GetReader().ReadFromFiles(readCtx, fm.wg, fm.quit, filePaths,
+			fm.contentUpdate, cfg.Follow)
+	}
 	// GetLoremIpsumReader().Read(fm.contentUpdate)
 }
 
+func (fm *FilterManager) ReadFromCommand() {
+	fm.startCommand()
+}
+
+// Starts the command from the command line as soon as the previous instance
+// (if any) has exited.
+func (fm *FilterManager) startCommand() {
+	cfg := config.User()
+
+	previousDone := fm.commandDone
+	done := make(chan struct{})
+	fm.commandDone = done
+
+	var ctx context.Context
+	ctx, fm.readerCancelFunc = context.WithCancel(fm.ctx)
+	fm.wg.Add(1)
+	go func() {
+		if previousDone != nil {
+			<-previousDone
+		}
+		reader.GetReader().RunCommand(ctx, fm.wg, cfg.Command, cfg.Stderr,
+			fm.contentUpdate)
+		close(done)
+	}()
+}
+
+func (fm *FilterManager) Listen(address string) {
+	fm.wg.Add(1)
+	go reader.GetReader().Listen(fm.ctx, fm.wg, fm.quit, address, fm.contentUpdate)
+}
+
 func (fm *FilterManager) ReadFromStdin() {
 	defer func() {
 		if r := recover(); r != nil {
@@ -106,11 +185,44 @@ func (fm *FilterManager) EventLoop() {
 
 	defer fm.wg.Done()
 
+	// relative date filters like "last 5m" have to move on even without new
+	// lines
+	dateTicker := time.NewTicker(dateReevaluationInterval)
+	defer dateTicker.Stop()
+
+	// keyword list files might get edited, they're only checked while a
+	// filter uses one
+	var keywordListTicker *time.Ticker
+	defer func() {
+		if keywordListTicker != nil {
+			keywordListTicker.Stop()
+		}
+	}()
+
 	for {
+		var keywordListTick <-chan time.Time
+		keywordListTicker = updateKeywordListTicker(keywordListTicker)
+		if keywordListTicker != nil {
+			keywordListTick = keywordListTicker.C
+		}
+
 		select {
 		case newLines := <-fm.contentUpdate:
 			log.Printf("Received contentupdate, lines %d-%d", newLines[0].No, newLines[len(newLines)-1].No)
 			fm.processContentUpdate(newLines)
+		case ends := <-fm.indexUpdate:
+			log.Printf("Received indexupdate, %d lines", len(ends))
+			fm.processIndexUpdate(ends)
+		case <-dateTicker.C:
+			if fm.filters.ReevaluateDates() {
+				fm.filters.InvalidateCaches()
+				fm.asyncRefreshScreenBuffer()
+			}
+		case <-keywordListTick:
+			if filter.ReloadKeywordLists() {
+				fm.filters.InvalidateCaches()
+				fm.asyncRefreshScreenBuffer()
+			}
 		case command := <-fm.commandChannel:
 			log.Printf("Received Command: %T", command)
 			fm.processCommand(command)
@@ -121,9 +233,23 @@ func (fm *FilterManager) EventLoop() {
 	}
 }
 
+// Starts the ticker once a filter uses a keyword list file and stops it again
+// once none does anymore.
+func updateKeywordListTicker(ticker *time.Ticker) *time.Ticker {
+	hasLists := filter.HasKeywordLists()
+	if hasLists && ticker == nil {
+		return time.NewTicker(keywordListReloadInterval)
+	}
+	if !hasLists && ticker != nil {
+		ticker.Stop()
+		return nil
+	}
+	return ticker
+}
+
 func (fm *FilterManager) processContentUpdate(newLines []*lines.Line) {
 	identifyFileTypeOnce.Do(func() {
-		go formats.Identify(newLines)
+		go fm.identifyFileType(newLines)
 	})
 
 	// If we're in Follow mode we'll automatically jump to the new end of the
@@ -133,7 +259,65 @@ func (fm *FilterManager) processContentUpdate(newLines []*lines.Line) {
 		goToEnd = true
 	}
 
-	length := fm.filters.Source().StoreNewLines(newLines)
+	source := fm.filters.Source()
+	length := source.StoreNewLines(newLines)
+
+	// The lines of a continued record which are already there might now
+	// match (or not match anymore). Date filters might show more (or less)
+	// lines now. Lines before a new match might become its context.
+	first := newLines[0].No
+	reevaluated := fm.filters.ReevaluateDates()
+	lookAhead := fm.filters.LooksAhead(first)
+	invalidated := true
+	switch {
+	case source.ContinuesRecord(first) || reevaluated:
+		fm.filters.InvalidateCaches()
+	case lookAhead > 0:
+		fm.filters.InvalidateCachedLines(first-lookAhead, first)
+	default:
+		invalidated = false
+	}
+	if invalidated && !goToEnd {
+		fm.syncRefreshScreenBuffer()
+	}
+
+	fm.processNewLength(length, goToEnd)
+}
+
+// Once the format is known, lines might get grouped into multi-line records
+// which means all filters have to be applied again.
+func (fm *FilterManager) identifyFileType(firstLines []*lines.Line) {
+	formats.Identify(firstLines)
+
+	select {
+	case fm.commandChannel <- CommandFileTypeIdentified{}:
+	case <-fm.ctx.Done():
+	}
+}
+
+func (fm *FilterManager) processIndexUpdate(ends []int64) {
+	goToEnd := false
+	if config.User().Follow && fm.alreadyAtTheEnd() {
+		goToEnd = true
+	}
+
+	// huge files must not end up in memory through the cache either
+	fm.filters.BoundCaches()
+	length, err := fm.filters.Source().StoreNewIndex(config.User().FilePath, ends)
+	if err != nil {
+		fm.quit <- err.Error()
+		close(fm.quit)
+		return
+	}
+
+	identifyFileTypeOnce.Do(func() {
+		go fm.identifyFileType(fm.filters.Source().FirstLines(100))
+	})
+
+	fm.processNewLength(length, goToEnd)
+}
+
+func (fm *FilterManager) processNewLength(length int, goToEnd bool) {
 	fm.display.SetTotalLength(length)
 
 	// refresh display as necessary
@@ -216,6 +400,53 @@ func (fm *FilterManager) UpdateFilterKey(filter filter.Filter, name string, key
 	fm.commandChannel <- CommandFilterKeyUpdate{filter, name, key}
 }
 
+func (fm *FilterManager) UpdateFilterOrigin(filter filter.Filter, origin string) {
+	fm.commandChannel <- CommandFilterOriginUpdate{filter, origin}
+}
+
+func (fm *FilterManager) UpdateFilterField(filter filter.Filter, field string) {
+	fm.commandChannel <- CommandFilterFieldUpdate{filter, field}
+}
+
+func (fm *FilterManager) UpdateFilterContext(filter filter.Filter, before int, after int) {
+	fm.commandChannel <- CommandFilterContextUpdate{filter, before, after}
+}
+
+// CountFieldValues counts how often each value of field occurs. The result
+// gets posted as EventFieldValues.
+func (fm *FilterManager) CountFieldValues(field string) {
+	fm.commandChannel <- CommandCountFieldValues{field}
+}
+
+// ExpandRun shows all lines of the folded run of repeated lines starting at
+// line.
+func (fm *FilterManager) ExpandRun(line int) {
+	fm.commandChannel <- CommandExpandRun{line}
+}
+
+func (fm *FilterManager) CollapseRuns() {
+	fm.commandChannel <- CommandCollapseRuns{}
+}
+
+// MineTemplates clusters all lines into templates. The result gets posted as
+// EventTemplates.
+func (fm *FilterManager) MineTemplates() {
+	fm.commandChannel <- CommandMineTemplates{}
+}
+
+func (fm *FilterManager) UpdateTemplateSelection(selection filter.TemplateSelection) {
+	fm.commandChannel <- CommandTemplateSelectionUpdate{selection}
+}
+
+// JumpToLine makes line the current match and scrolls to it.
+func (fm *FilterManager) JumpToLine(line int) {
+	fm.commandChannel <- CommandJumpToLine{line}
+}
+
+func (fm *FilterManager) RestartCommand() {
+	fm.commandChannel <- CommandRestartCommand{}
+}
+
 func (fm *FilterManager) ToggleFollowMode() {
 	fm.commandChannel <- CommandToggleFollowMode{}
 }
@@ -235,6 +466,7 @@ func (fm *FilterManager) processCommand(command Command) {
 		config.PostEventFunc(NewEventDisplay(*fm.display))
 	case CommandScrollHorizontal:
 		err = fm.internalScrollHorizontal(command.offset)
+		config.PostEventFunc(NewEventDisplay(*fm.display))
 	case CommandPgDown:
 		err = fm.internalScrollPage(filter.DirectionDown)
 		config.PostEventFunc(NewEventDisplay(*fm.display))
@@ -258,6 +490,9 @@ func (fm *FilterManager) processCommand(command Command) {
 		fm.syncRefreshScreenBuffer()
 	case CommandRemoveFilter:
 		err = fm.filters.Remove(command.Filter)
+		if commandFilter, ok := command.Filter.(*filter.CommandFilter); ok && err == nil {
+			commandFilter.Close()
+		}
 		fm.syncRefreshScreenBuffer()
 	case CommandSetDisplayHeight:
 		fm.display.SetHeight(command.Lines)
@@ -282,11 +517,85 @@ func (fm *FilterManager) processCommand(command Command) {
 		fm.filters.InvalidateCaches()
 		fm.display.UnsetCurrentMatch()
 		fm.asyncRefreshScreenBuffer()
+	case CommandFilterOriginUpdate:
+		stringFilter := command.Filter.(*filter.StringFilter)
+		stringFilter.SetOrigin(command.Origin)
+		fm.filters.InvalidateCaches()
+		fm.display.UnsetCurrentMatch()
+		fm.asyncRefreshScreenBuffer()
+	case CommandFilterFieldUpdate:
+		stringFilter := command.Filter.(*filter.StringFilter)
+		stringFilter.SetField(command.Field)
+		fm.filters.InvalidateCaches()
+		fm.display.UnsetCurrentMatch()
+		fm.asyncRefreshScreenBuffer()
+	case CommandFilterContextUpdate:
+		stringFilter := command.Filter.(*filter.StringFilter)
+		stringFilter.SetContext(command.Before, command.After)
+		fm.filters.InvalidateCaches()
+		fm.display.UnsetCurrentMatch()
+		fm.asyncRefreshScreenBuffer()
 	case CommandFilterKeyUpdate:
 		fm.filters.InvalidateCaches()
 		err = command.Filter.SetKey(command.Name, command.Key)
 		fm.display.UnsetCurrentMatch()
 		fm.asyncRefreshScreenBuffer()
+	case CommandFileTypeIdentified:
+		// levels might be found in fields now, field names in keys (e.g.
+		// from presets) might be known now
+		fm.filters.Source().ResetSeverities()
+		fm.filters.ReparseKeys()
+		fm.filters.InvalidateCaches()
+		if recordStart := config.User().RecordStartRegex; recordStart != nil {
+			fm.filters.Source().SetRecordStart(recordStart)
+			fm.display.UnsetCurrentMatch()
+		}
+		// lines might get rendered differently now
+		fm.asyncRefreshScreenBuffer()
+	case CommandCountFieldValues:
+		go fm.countFieldValues(command.Field)
+	case CommandMineTemplates:
+		go fm.mineTemplates()
+	case CommandTemplatesMined:
+		if fm.templateFilter == nil {
+			fm.templateFilter = filter.NewTemplateFilter()
+			fm.filters.Add(fm.templateFilter)
+		}
+		fm.templateFilter.SetIndex(command.Index)
+		fm.filters.InvalidateCaches()
+		fm.asyncRefreshScreenBuffer()
+		config.PostEventFunc(NewEventTemplates(command.Index))
+	case CommandTemplateSelectionUpdate:
+		if fm.templateFilter == nil {
+			err = util.ErrNotFound
+			break
+		}
+		fm.templateFilter.SetSelection(command.Selection)
+		fm.filters.InvalidateCaches()
+		fm.display.UnsetCurrentMatch()
+		fm.asyncRefreshScreenBuffer()
+	case CommandJumpToLine:
+		fm.display.CurrentMatch = command.Line
+		firstLine, _ := fm.arrangeLine(command.Line, 25)
+		fm.internalSetCurrentLine(firstLine)
+		// a refresh might still be running for the selection
+		fm.asyncRefreshScreenBuffer()
+	case CommandExpandRun:
+		if !fm.filters.ExpandRun(command.Line) {
+			err = util.ErrNotFound
+			break
+		}
+		fm.filters.InvalidateCaches()
+		fm.syncRefreshScreenBuffer()
+	case CommandCollapseRuns:
+		if !fm.filters.CollapseRuns() {
+			err = util.ErrNotFound
+			break
+		}
+		fm.filters.InvalidateCaches()
+		fm.syncRefreshScreenBuffer()
+	case CommandRestartCommand:
+		err = fm.internalRestartCommand()
 	case CommandToggleFollowMode:
 		fm.internalToggleFollowMode()
 		config.PostEventFunc(NewEventDisplay(*fm.display))
@@ -329,10 +638,11 @@ func (fm *FilterManager) internalScrollVertical(
 		return err
 	}
 
+	showsContext := fm.filters.ShowsContext()
 	if direction == filter.DirectionDown {
-		fm.display.addLineAtBottomRemoveLineAtTop(nextLine)
+		fm.display.addLineAtBottomRemoveLineAtTop(nextLine, showsContext)
 	} else {
-		fm.display.addLineAtTopRemoveLineAtBottom(nextLine)
+		fm.display.addLineAtTopRemoveLineAtBottom(nextLine, showsContext)
 	}
 
 	fm.currentLine = fm.display.firstLine().No
@@ -343,8 +653,9 @@ func (fm *FilterManager) internalScrollVertical(
 func (fm *FilterManager) internalScrollHorizontal(offset int) error {
 	width, _ := fm.filters.Size()
 
+	// bigger steps stop at the beginning or end of the lines
 	newCol, err := util.InBetween(fm.display.CurrentCol+offset, 0, width)
-	if err != nil {
+	if err != nil && newCol == fm.display.CurrentCol {
 		return util.ErrOutOfBounds
 	}
 
@@ -392,16 +703,29 @@ func (fm *FilterManager) internalTail() {
 }
 
 func (fm *FilterManager) internalScrollEnd() {
-	y := fm.display.Height() - 1
+	showsContext := fm.filters.ShowsContext()
+	height := fm.display.Height()
+	y := height - 1
 	lineNo := fm.filters.SourceLength() - 1
 	for ; y >= 0 && lineNo >= 0; lineNo-- {
 		line, _ := fm.filters.GetLine(lineNo)
-		if line.Status != lines.LineHidden &&
-			line.Status != lines.LineDoesNotExist {
+		if line.Status == lines.LineHidden ||
+			line.Status == lines.LineDoesNotExist {
+
+			continue
+		}
 
-			fm.display.Buffer[y] = line
+		if showsContext && y < height-1 &&
+			needsDivider(line, fm.display.Buffer[y+1]) {
+
+			fm.display.Buffer[y] = lines.DividerLine
 			y--
+			if y < 0 {
+				break
+			}
 		}
+		fm.display.Buffer[y] = line
+		y--
 	}
 
 	if y >= 0 {
@@ -508,7 +832,7 @@ func (fm *FilterManager) internalToggleFollowMode() {
 
 	if cfg.Follow {
 		if fm.alreadyAtTheEnd() {
-			if !cfg.Stdin {
+			if fm.readsFromFiles() {
 				fm.readerCancelFunc()
 			}
 			cfg.Follow = false
@@ -518,16 +842,39 @@ func (fm *FilterManager) internalToggleFollowMode() {
 	} else {
 		cfg.Follow = true
 		fm.internalTail()
-		if !cfg.Stdin {
+		if fm.readsFromFiles() {
 			fm.wg.Add(1)
 			var ctx context.Context
 			ctx, fm.readerCancelFunc = context.WithCancel(fm.ctx)
-			go reader.GetReader().ReopenForWatching(ctx, fm.wg, cfg.FilePath,
-				fm.contentUpdate, fm.filters.Source().LastLine().No+1)
+			if cfg.Merge {
+				go reader.GetReader().ReopenAllForWatching(ctx, fm.wg,
+					cfg.FilePaths, fm.contentUpdate)
+			} else {
+				go reader.GetReader().ReopenForWatching(ctx, fm.wg, cfg.FilePath,
+					fm.contentUpdate, fm.filters.Source().LastLine().No+1)
+			}
 		}
 	}
 }
 
+// Only files stop getting watched when follow mode gets switched off. Stdin,
+// commands and listeners just keep on running.
+func (fm *FilterManager) readsFromFiles() bool {
+	cfg := config.User()
+	return !cfg.Stdin && len(cfg.Command) == 0 && cfg.Listen == ""
+}
+
+func (fm *FilterManager) internalRestartCommand() error {
+	if len(config.User().Command) == 0 {
+		return util.ErrNotFound
+	}
+
+	fm.readerCancelFunc()
+	fm.startCommand()
+
+	return nil
+}
+
 func (fm *FilterManager) percentage() int {
 	length := fm.filters.SourceLength()
 	if length <= 0 || fm.currentLine < 0 || fm.currentLine > length {
@@ -0,0 +1,100 @@
+package formats
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/claude42/infiltrator/config"
+)
+
+// CanCompact returns true if lines of the detected format can be rendered
+// using Compact().
+func CanCompact() bool {
+	fileFormat := config.User().FileFormat
+	return fileFormat == FormatJSON || fileFormat == FormatLogfmt
+}
+
+// CompactLine is a JSON or logfmt line rendered like "ts level msg key=val ...".
+type CompactLine struct {
+	Str string
+	// position of each byte of Str within the original line, -1 for bytes
+	// which got added like the keys
+	RawPos []int
+	// where the values of the leading fields are within Str
+	Leading []Field
+
+	sb strings.Builder
+}
+
+// Compact renders the fields configured as leading first (just their values),
+// then all other fields as key=value. Returns nil if str can't be split into
+// fields.
+func Compact(str string) *CompactLine {
+	fields := Fields(str)
+	if fields == nil {
+		return nil
+	}
+
+	c := &CompactLine{}
+	used := make([]bool, len(fields))
+
+	for _, alternatives := range config.User().LeadingFields {
+		for _, name := range strings.Split(alternatives, "|") {
+			i := slices.IndexFunc(fields, func(f Field) bool {
+				return strings.TrimPrefix(f.Name, ".") == name
+			})
+			if i == -1 || used[i] {
+				continue
+			}
+			used[i] = true
+			c.addSeparator()
+			start := c.sb.Len()
+			c.addRaw(str, fields[i])
+			c.Leading = append(c.Leading, Field{
+				Name:  fields[i].Name,
+				Value: fields[i].Value,
+				Start: start,
+				End:   c.sb.Len(),
+			})
+			break
+		}
+	}
+
+	for i, field := range fields {
+		if used[i] {
+			continue
+		}
+		c.addSeparator()
+		if field.Start == field.End && field.Value != "" {
+			// logfmt key without a value
+			c.add(field.Name)
+			continue
+		}
+		c.add(strings.TrimPrefix(field.Name, ".") + "=")
+		c.addRaw(str, field)
+	}
+
+	c.Str = c.sb.String()
+	return c
+}
+
+func (c *CompactLine) addSeparator() {
+	if c.sb.Len() > 0 {
+		c.add(" ")
+	}
+}
+
+func (c *CompactLine) add(str string) {
+	c.sb.WriteString(str)
+	for range len(str) {
+		c.RawPos = append(c.RawPos, -1)
+	}
+}
+
+// adds the value as it's written in the original line
+func (c *CompactLine) addRaw(str string, field Field) {
+	c.sb.WriteString(str[field.Start:field.End])
+	for i := field.Start; i < field.End; i++ {
+		c.RawPos = append(c.RawPos, i)
+	}
+}
@@ -0,0 +1,117 @@
+package formats
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Decompressor knows how to recognize and decompress one compression format.
+type Decompressor struct {
+	Name        string
+	magicLength int
+	matches     func(magic []byte) bool
+	newReader   func(io.Reader) (io.ReadCloser, error)
+}
+
+// NewReader returns a reader decompressing r. Closing it doesn't close r.
+func (d *Decompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return d.newReader(r)
+}
+
+var decompressors []*Decompressor
+
+func init() {
+	RegisterDecompressor("gzip", []byte{0x1f, 0x8b}, func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	})
+	RegisterDecompressor("zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}, func(r io.Reader) (io.ReadCloser, error) {
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	})
+	RegisterDecompressor("xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, func(r io.Reader) (io.ReadCloser, error) {
+		xzReader, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xzReader), nil
+	})
+	// "BZh" alone is too common at the beginning of text, so check the block
+	// size and the magic of the first block as well
+	RegisterDecompressorFunc("bzip2", len(bzip2Magic), isBzip2, func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	})
+}
+
+// "BZh", block size '1'-'9', block magic
+const bzip2Magic = "BZh91AY&SY"
+
+func isBzip2(magic []byte) bool {
+	return len(magic) >= len(bzip2Magic) &&
+		string(magic[:3]) == bzip2Magic[:3] &&
+		magic[3] >= '1' && magic[3] <= '9' &&
+		string(magic[4:len(bzip2Magic)]) == bzip2Magic[4:]
+}
+
+// RegisterDecompressor makes files starting with magic get decompressed
+// using readers created by newReader.
+func RegisterDecompressor(name string, magic []byte,
+	newReader func(io.Reader) (io.ReadCloser, error)) {
+
+	RegisterDecompressorFunc(name, len(magic), func(fileStart []byte) bool {
+		return bytes.HasPrefix(fileStart, magic)
+	}, newReader)
+}
+
+// RegisterDecompressorFunc is like RegisterDecompressor for magic numbers
+// that aren't fixed. matches gets the first magicLength bytes of the file, or
+// less if the file is shorter.
+func RegisterDecompressorFunc(name string, magicLength int,
+	matches func(magic []byte) bool,
+	newReader func(io.Reader) (io.ReadCloser, error)) {
+
+	decompressors = append(decompressors, &Decompressor{
+		Name:        name,
+		magicLength: magicLength,
+		matches:     matches,
+		newReader:   newReader,
+	})
+}
+
+// DetectCompression returns the decompressor matching the magic number at the
+// beginning of file or nil if the file isn't compressed (in a known format).
+// Afterwards file is positioned at its beginning again.
+func DetectCompression(file io.ReadSeeker) (*Decompressor, error) {
+	maxLength := 0
+	for _, d := range decompressors {
+		maxLength = max(maxLength, d.magicLength)
+	}
+
+	magicBytes := make([]byte, maxLength)
+	n, err := io.ReadFull(file, magicBytes)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	magicBytes = magicBytes[:n]
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range decompressors {
+		if d.matches(magicBytes[:min(len(magicBytes), d.magicLength)]) {
+			return d, nil
+		}
+	}
+
+	return nil, nil
+}
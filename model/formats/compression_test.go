@@ -0,0 +1,41 @@
+package formats
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectCompression(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		want  string
+	}{
+		{"gzip", "\x1f\x8b\x08\x00", "gzip"},
+		{"zstd", "\x28\xb5\x2f\xfd\x00", "zstd"},
+		{"xz", "\xfd7zXZ\x00\x00", "xz"},
+		{"bzip2", "BZh91AY&SY\x00\x00", "bzip2"},
+		{"bzip2 smallest block size", "BZh11AY&SY", "bzip2"},
+		{"text starting with BZh", "BZh is not bzip2\n", ""},
+		{"bzip2 without block", "BZh9", ""},
+		{"bzip2 invalid block size", "BZh01AY&SY", ""},
+		{"text", "2024-10-11 app started\n", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := DetectCompression(strings.NewReader(tt.start))
+			if err != nil {
+				t.Fatalf("DetectCompression() error = %v", err)
+			}
+			var got string
+			if d != nil {
+				got = d.Name
+			}
+			if got != tt.want {
+				t.Errorf("DetectCompression() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
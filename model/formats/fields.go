@@ -0,0 +1,145 @@
+package formats
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+
+	"github.com/claude42/infiltrator/config"
+)
+
+// Field is the value of a named group of the format regex, e.g. the host a
+// syslog line came from. Start and End are the position within the line.
+type Field struct {
+	Name  string
+	Value string
+	Start int
+	End   int
+}
+
+// Fields splits str into the named groups of the detected format. Returns nil
+// if no format has been detected or str doesn't match.
+func Fields(str string) []Field {
+	switch config.User().FileFormat {
+	case FormatJSON:
+		return jsonFields(str)
+	case FormatLogfmt:
+		return logfmtFields(str)
+	}
+
+	regex := config.User().FileFormatRegex
+	if regex == nil {
+		return nil
+	}
+
+	matches := regex.FindStringSubmatchIndex(str)
+	if matches == nil {
+		return nil
+	}
+
+	var fields []Field
+	for i, name := range regex.SubexpNames() {
+		if name == "" || matches[2*i] < 0 {
+			continue
+		}
+		start, end := matches[2*i], matches[2*i+1]
+		fields = append(fields, Field{
+			Name:  name,
+			Value: str[start:end],
+			Start: start,
+			End:   end,
+		})
+	}
+
+	return fields
+}
+
+// FieldSpan returns the position of the field called name within str. ok is
+// false if str doesn't have such a field.
+func FieldSpan(str string, name string) (start int, end int, ok bool) {
+	if fileFormat := config.User().FileFormat; fileFormat == FormatJSON || fileFormat == FormatLogfmt {
+		fields := Fields(str)
+		i := slices.IndexFunc(fields, func(f Field) bool {
+			return f.Name == name
+		})
+		if i == -1 {
+			return 0, 0, false
+		}
+		return fields[i].Start, fields[i].End, true
+	}
+
+	regex := config.User().FileFormatRegex
+	if regex == nil {
+		return 0, 0, false
+	}
+
+	i := regex.SubexpIndex(name)
+	if i < 0 {
+		return 0, 0, false
+	}
+
+	matches := regex.FindStringSubmatchIndex(str)
+	if matches == nil || matches[2*i] < 0 {
+		return 0, 0, false
+	}
+
+	return matches[2*i], matches[2*i+1], true
+}
+
+// FieldName returns the name of the field called name, or an empty string if
+// there's no such field. The leading dot of JSON fields may be left out.
+func FieldName(name string) string {
+	names := FieldNames()
+	if slices.Contains(names, name) {
+		return name
+	}
+	if slices.Contains(names, "."+name) {
+		return "." + name
+	}
+	return ""
+}
+
+// FieldNames returns the names of all fields of the detected format in the
+// order they appear in the regex. As long as no format has been detected
+// (e.g. while a preset gets loaded), the fields of all formats are returned.
+func FieldNames() []string {
+	if config.User().FileFormatFields != nil {
+		return config.User().FileFormatFields
+	}
+
+	if regex := config.User().FileFormatRegex; regex != nil {
+		return namedGroups(regex)
+	}
+
+	formats := config.Formats()
+	formatNames := make([]string, 0, len(formats))
+	for name := range formats {
+		formatNames = append(formatNames, name)
+	}
+	sort.Strings(formatNames)
+
+	var fieldNames []string
+	for _, formatName := range formatNames {
+		regex, err := regexp.Compile(formats[formatName].Regex)
+		if err != nil {
+			continue
+		}
+		for _, name := range namedGroups(regex) {
+			if !slices.Contains(fieldNames, name) {
+				fieldNames = append(fieldNames, name)
+			}
+		}
+	}
+
+	return fieldNames
+}
+
+func namedGroups(regex *regexp.Regexp) []string {
+	var names []string
+	for _, name := range regex.SubexpNames() {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
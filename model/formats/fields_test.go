@@ -0,0 +1,141 @@
+package formats
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/claude42/infiltrator/config"
+)
+
+// Sets the detected file format for the rest of the test.
+func useFileFormat(t *testing.T, fileFormat string, regex *regexp.Regexp, fields []string) {
+	t.Helper()
+
+	user := config.User()
+	oldFormat, oldRegex, oldFields := user.FileFormat, user.FileFormatRegex, user.FileFormatFields
+	t.Cleanup(func() {
+		user.FileFormat, user.FileFormatRegex, user.FileFormatFields = oldFormat, oldRegex, oldFields
+	})
+	user.FileFormat, user.FileFormatRegex, user.FileFormatFields = fileFormat, regex, fields
+}
+
+var syslogTestRegex = regexp.MustCompile(
+	`^(?P<date>\w{3} +\d+ [\d:]+) (?P<host>\S+) (?P<program>[^:\[]+)(?:\[(?P<pid>\d+)\])?: `)
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		name string
+		str  string
+		want []Field
+	}{
+		{
+			name: "all groups",
+			str:  "Oct 11 22:14:15 web1 sshd[42]: accepted",
+			want: []Field{
+				{Name: "date", Value: "Oct 11 22:14:15", Start: 0, End: 15},
+				{Name: "host", Value: "web1", Start: 16, End: 20},
+				{Name: "program", Value: "sshd", Start: 21, End: 25},
+				{Name: "pid", Value: "42", Start: 26, End: 28},
+			},
+		},
+		{
+			name: "optional group missing",
+			str:  "Oct  1 08:00:00 db kernel: oops",
+			want: []Field{
+				{Name: "date", Value: "Oct  1 08:00:00", Start: 0, End: 15},
+				{Name: "host", Value: "db", Start: 16, End: 18},
+				{Name: "program", Value: "kernel", Start: 19, End: 25},
+			},
+		},
+		{
+			name: "no match",
+			str:  "continued line",
+			want: nil,
+		},
+	}
+
+	useFileFormat(t, "syslog", syslogTestRegex, nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Fields(tt.str); !slices.Equal(got, tt.want) {
+				t.Errorf("Fields(%q) = %v, want %v", tt.str, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldsWithoutFormat(t *testing.T) {
+	useFileFormat(t, "", nil, nil)
+
+	if got := Fields("Oct 11 22:14:15 web1 sshd[42]: accepted"); got != nil {
+		t.Errorf("Fields() = %v without a format, want nil", got)
+	}
+	if _, _, ok := FieldSpan("Oct 11 22:14:15 web1 sshd[42]: accepted", "host"); ok {
+		t.Errorf("FieldSpan() found a field without a format")
+	}
+}
+
+func TestFieldSpan(t *testing.T) {
+	tests := []struct {
+		name      string
+		str       string
+		field     string
+		wantStart int
+		wantEnd   int
+		wantOk    bool
+	}{
+		{name: "found", str: "Oct 11 22:14:15 web1 sshd[42]: x", field: "host",
+			wantStart: 16, wantEnd: 20, wantOk: true},
+		{name: "unknown field", str: "Oct 11 22:14:15 web1 sshd[42]: x", field: "user"},
+		{name: "optional group missing", str: "Oct 11 22:14:15 web1 sshd: x", field: "pid"},
+		{name: "no match", str: "continued line", field: "host"},
+	}
+
+	useFileFormat(t, "syslog", syslogTestRegex, nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok := FieldSpan(tt.str, tt.field)
+			if ok != tt.wantOk || (ok && (start != tt.wantStart || end != tt.wantEnd)) {
+				t.Errorf("FieldSpan(%q, %q) = %d, %d, %v, want %d, %d, %v", tt.str, tt.field,
+					start, end, ok, tt.wantStart, tt.wantEnd, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestFieldName(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []string
+		field  string
+		want   string
+	}{
+		{name: "regex group", field: "host", want: "host"},
+		{name: "unknown", field: "user", want: ""},
+		{name: "json path", fields: []string{".level", ".req.status"}, field: ".level", want: ".level"},
+		{name: "json without dot", fields: []string{".level", ".req.status"}, field: "req.status",
+			want: ".req.status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFileFormat(t, "syslog", syslogTestRegex, tt.fields)
+
+			if got := FieldName(tt.field); got != tt.want {
+				t.Errorf("FieldName(%q) = %q, want %q", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldNames(t *testing.T) {
+	useFileFormat(t, "syslog", syslogTestRegex, nil)
+
+	want := []string{"date", "host", "program", "pid"}
+	if got := FieldNames(); !slices.Equal(got, want) {
+		t.Errorf("FieldNames() = %q, want %q", got, want)
+	}
+}
@@ -1,68 +1,121 @@
 package formats
 
 import (
-	"bytes"
-	"io"
-	"os"
+	"log"
+	"maps"
 	"regexp"
+	"slices"
 
 	"github.com/claude42/infiltrator/config"
-	"github.com/claude42/infiltrator/fail"
 	"github.com/claude42/infiltrator/model/lines"
 )
 
-const testCases = 100
+const (
+	testCases = 100
+	// at least that many records must be among the test cases
+	minRecords = 5
+	// and at least that share of the test cases must start a record
+	minRecordShare = 0.1
+)
 
+// Identify tries to find out the format of the file and stores it in the
+// user configuration.
 func Identify(lines []*lines.Line) {
+	fileFormat, regex := Detect(lines)
+	if fileFormat == "" {
+		return
+	}
+
+	config.User().FileFormat = fileFormat
+	config.User().FileFormatRegex = regex
+
+	switch fileFormat {
+	case FormatJSON:
+		config.User().FileFormatFields = jsonFieldNames(lines)
+	case FormatLogfmt:
+		config.User().FileFormatFields = logfmtFieldNames(lines)
+	default:
+		config.User().RecordStartRegex = recordStartRegex(fileFormat)
+	}
+}
+
+// Detect returns the name of the format most of the lines match and its
+// regex. JSON lines and logfmt don't have a regex. The name will be empty if
+// no format was found.
+func Detect(lines []*lines.Line) (string, *regexp.Regexp) {
+	if detectJSON(lines) {
+		return FormatJSON, nil
+	}
+
+	if detectLogfmt(lines) {
+		return FormatLogfmt, nil
+	}
+
+	return detectRegexFormat(lines)
+}
+
+// Continuation lines of multi-line records (e.g. stack traces) don't have to
+// match. If several formats match, the one matching the most lines wins, ties
+// go to the first one by name.
+func detectRegexFormat(lines []*lines.Line) (string, *regexp.Regexp) {
 	formats := config.Formats()
 	regexs := make(map[string]*regexp.Regexp)
-	results := make(map[string]int, len(formats))
+	recordStarts := make(map[string]*regexp.Regexp)
 
 	for key := range formats {
-		regexs[key] = regexp.MustCompile(formats[key])
-		results[key] = 0
+		regexs[key] = regexp.MustCompile(formats[key].Regex)
+		recordStarts[key] = recordStartRegex(key)
 	}
 
 	n := min(testCases, len(lines))
+	if n == 0 {
+		return "", nil
+	}
 
-nextLine:
-	for i := range n {
-		for format, regex := range regexs {
-			if regex.MatchString(lines[i].Str) {
-				results[format]++
-				continue nextLine
+	maxMatched := 0
+	var fileFormat string
+	for _, format := range slices.Sorted(maps.Keys(regexs)) {
+		regex := regexs[format]
+		tested, matched := 0, 0
+		for _, line := range lines[:n] {
+			recordStart := recordStarts[format]
+			if recordStart != nil && !recordStart.MatchString(line.Str) {
+				continue
+			}
+			tested++
+			if regex.MatchString(line.Str) {
+				matched++
 			}
 		}
-	}
 
-	var maxResult int
-	var fileFormat string
-	for format, result := range results {
-		if result > maxResult {
-			maxResult = result
+		// a handful of lines looking right doesn't mean much
+		if tested < min(n, minRecords) || float64(tested) < minRecordShare*float64(n) {
+			continue
+		}
+
+		if float64(matched)/float64(tested) > 0.9 && matched > maxMatched {
+			maxMatched = matched
 			fileFormat = format
 		}
 	}
 
-	if float64(maxResult)/float64(n) > 0.9 {
-		config.User().FileFormat = fileFormat
-		config.User().FileFormatRegex = regexs[fileFormat]
+	if fileFormat == "" {
+		return "", nil
 	}
+	return fileFormat, regexs[fileFormat]
 }
 
-func IsGzip(file *os.File) (bool, error) {
-	var gzipMagicNumber = []byte{0x1f, 0x8b}
+// Returns nil if the format doesn't have multi-line records.
+func recordStartRegex(fileFormat string) *regexp.Regexp {
+	recordStart := config.Formats()[fileFormat].RecordStart
+	if recordStart == "" {
+		return nil
+	}
 
-	// Read the first two bytes of the file.
-	magicBytes := make([]byte, 2)
-	n, err := file.Read(magicBytes)
+	regex, err := regexp.Compile(recordStart)
 	if err != nil {
-		return false, err
+		log.Printf("invalid record start regex for format %s: %+v", fileFormat, err)
+		return nil
 	}
-
-	_, err = file.Seek(0, io.SeekStart)
-	fail.OnError(err, "Seek() failed")
-
-	// Check if the two bytes match the gzip magic number.
-	return n == 2 && bytes.Equal(magicBytes, gzipMagicNumber), nil
+	return regex
 }
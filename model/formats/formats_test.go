@@ -0,0 +1,100 @@
+package formats
+
+import (
+	"fmt"
+	"maps"
+	"testing"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+func detectTestLines(texts ...[]string) []*lines.Line {
+	var result []*lines.Line
+	for _, part := range texts {
+		for _, text := range part {
+			result = append(result, lines.NewLine(len(result), text))
+		}
+	}
+	return result
+}
+
+func repeatLines(n int, format string) []string {
+	texts := make([]string, n)
+	for i := range texts {
+		texts[i] = fmt.Sprintf(format, i)
+	}
+	return texts
+}
+
+func TestDetectRegexFormat(t *testing.T) {
+	testFormats := map[string]config.Format{
+		"javaLog": {
+			Regex:       `^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2} (INFO|ERROR) `,
+			RecordStart: `^\d{4}-\d{2}-\d{2} `,
+		},
+		"kv":      {Regex: `^\w+=\d+$`},
+		"kvAlias": {Regex: `^\w+=\d+$`},
+		"simple":  {Regex: `^\[\w+\] `},
+	}
+
+	javaRecord := "2024-10-11 22:14:%02d INFO started"
+	trace := "\tat Main.run(Main.java:%d)"
+
+	tests := []struct {
+		name  string
+		lines []*lines.Line
+		want  string
+	}{
+		{
+			name:  "single line format",
+			lines: detectTestLines(repeatLines(100, "[main] line %d")),
+			want:  "simple",
+		},
+		{
+			name:  "records with stack traces",
+			lines: detectTestLines(repeatLines(20, javaRecord), repeatLines(80, trace)),
+			want:  "javaLog",
+		},
+		{
+			name:  "a few record starts don't win over most lines matching",
+			lines: detectTestLines(repeatLines(5, javaRecord), repeatLines(95, "[main] line %d")),
+			want:  "simple",
+		},
+		{
+			name:  "too few record starts",
+			lines: detectTestLines(repeatLines(5, javaRecord), repeatLines(95, "whatever %d")),
+			want:  "",
+		},
+		{
+			name:  "ties go to the first format by name",
+			lines: detectTestLines(repeatLines(100, "key=%d")),
+			want:  "kv",
+		},
+		{
+			name:  "nothing matches",
+			lines: detectTestLines(repeatLines(100, "whatever %d")),
+			want:  "",
+		},
+	}
+
+	formats := config.Formats()
+	saved := maps.Clone(formats)
+	defer func() {
+		clear(formats)
+		maps.Copy(formats, saved)
+	}()
+	clear(formats)
+	maps.Copy(formats, testFormats)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// map order is random, so try a few times
+			for range 10 {
+				if got, _ := detectRegexFormat(tt.lines); got != tt.want {
+					t.Fatalf("detectRegexFormat() = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package formats
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+// FormatJSON is the name of the JSON lines (NDJSON) format, which doesn't need
+// an entry in formats.toml.
+const FormatJSON = "json"
+
+// IsJSON returns true if the line looks like a JSON object.
+func IsJSON(str string) bool {
+	str = strings.TrimSpace(str)
+	return strings.HasPrefix(str, "{") && strings.HasSuffix(str, "}") &&
+		json.Valid([]byte(str))
+}
+
+func detectJSON(testLines []*lines.Line) bool {
+	n := min(testCases, len(testLines))
+	if n == 0 {
+		return false
+	}
+
+	matched := 0
+	for _, line := range testLines[:n] {
+		if IsJSON(line.Str) {
+			matched++
+		}
+	}
+
+	return float64(matched)/float64(n) > 0.9
+}
+
+// all paths seen in testLines, in the order they appeared first
+func jsonFieldNames(testLines []*lines.Line) []string {
+	var names []string
+	for _, line := range testLines[:min(testCases, len(testLines))] {
+		for _, field := range jsonFields(line.Str) {
+			if !slices.Contains(names, field.Name) {
+				names = append(names, field.Name)
+			}
+		}
+	}
+	return names
+}
+
+type jsonLevel struct {
+	array bool
+	// within objects the next token is either a key or a value
+	expectKey bool
+	key       string
+	index     int
+}
+
+// Turns each scalar value of a JSON object into a field. Names are paths like
+// ".req.status" or ".tags[0]". The position of string values excludes the
+// quotes. Returns nil if str isn't a valid JSON object.
+func jsonFields(str string) []Field {
+	decoder := json.NewDecoder(strings.NewReader(str))
+	decoder.UseNumber()
+
+	var fields []Field
+	var levels []*jsonLevel
+	end := 0
+
+	for {
+		// the top level object returns below once it's complete, so running
+		// out of input is an error as well
+		token, err := decoder.Token()
+		if err != nil {
+			return nil
+		}
+
+		start := skipJSONSeparators(str, end)
+		end = int(decoder.InputOffset())
+
+		if len(levels) == 0 {
+			// only objects are supported at the top level
+			if token != json.Delim('{') {
+				return nil
+			}
+			levels = append(levels, &jsonLevel{expectKey: true})
+			continue
+		}
+
+		level := levels[len(levels)-1]
+		if level.expectKey && token != json.Delim('}') {
+			key, ok := token.(string)
+			if !ok {
+				return nil
+			}
+			level.key = key
+			level.expectKey = false
+			continue
+		}
+
+		switch token {
+		case json.Delim('{'):
+			levels = append(levels, &jsonLevel{expectKey: true})
+			continue
+		case json.Delim('['):
+			levels = append(levels, &jsonLevel{array: true})
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			levels = levels[:len(levels)-1]
+			if len(levels) == 0 {
+				if fields == nil {
+					// make sure an empty object doesn't count as invalid
+					fields = []Field{}
+				}
+				return fields
+			}
+		default:
+			valueStart, valueEnd := start, end
+			if _, ok := token.(string); ok {
+				valueStart++
+				valueEnd--
+			}
+			fields = append(fields, Field{
+				Name:  jsonPath(levels),
+				Value: jsonValueString(token),
+				Start: valueStart,
+				End:   valueEnd,
+			})
+		}
+
+		// the value for the current key or array element is done
+		level = levels[len(levels)-1]
+		if level.array {
+			level.index++
+		} else {
+			level.expectKey = true
+		}
+	}
+}
+
+func skipJSONSeparators(str string, pos int) int {
+	for pos < len(str) && strings.IndexByte(" \t\r\n:,", str[pos]) >= 0 {
+		pos++
+	}
+	return pos
+}
+
+func jsonPath(levels []*jsonLevel) string {
+	var sb strings.Builder
+	for _, level := range levels {
+		if level.array {
+			sb.WriteString("[" + strconv.Itoa(level.index) + "]")
+		} else {
+			sb.WriteString("." + level.key)
+		}
+	}
+	return sb.String()
+}
+
+func jsonValueString(token json.Token) string {
+	switch token := token.(type) {
+	case string:
+		return token
+	case json.Number:
+		return token.String()
+	case bool:
+		return strconv.FormatBool(token)
+	case nil:
+		return "null"
+	default:
+		return ""
+	}
+}
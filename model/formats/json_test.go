@@ -0,0 +1,110 @@
+package formats
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestJSONFields(t *testing.T) {
+	tests := []struct {
+		name string
+		str  string
+		want []Field
+	}{
+		{
+			name: "nested objects and arrays",
+			str:  `{"level":"warn","req":{"status":404},"tags":["a",true],"n":null}`,
+			want: []Field{
+				{Name: ".level", Value: "warn", Start: 10, End: 14},
+				{Name: ".req.status", Value: "404", Start: 32, End: 35},
+				{Name: ".tags[0]", Value: "a", Start: 46, End: 47},
+				{Name: ".tags[1]", Value: "true", Start: 49, End: 53},
+				{Name: ".n", Value: "null", Start: 59, End: 63},
+			},
+		},
+		{
+			name: "spaces",
+			str:  `{ "msg" : "a b" , "n" : 1.5 }`,
+			want: []Field{
+				{Name: ".msg", Value: "a b", Start: 11, End: 14},
+				{Name: ".n", Value: "1.5", Start: 24, End: 27},
+			},
+		},
+		{
+			name: "escaped string",
+			str:  `{"msg":"say \"hi\""}`,
+			want: []Field{
+				{Name: ".msg", Value: `say "hi"`, Start: 8, End: 18},
+			},
+		},
+		{
+			name: "empty object",
+			str:  `{}`,
+			want: []Field{},
+		},
+		{name: "array at the top level", str: `[1,2]`, want: nil},
+		{name: "not json", str: `level=warn`, want: nil},
+		{name: "incomplete", str: `{"level":"warn"`, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := jsonFields(tt.str)
+			if !slices.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+				t.Errorf("jsonFields(%q) = %#v, want %#v", tt.str, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONFieldSpan(t *testing.T) {
+	useFileFormat(t, FormatJSON, nil, []string{".level", ".req.status"})
+
+	str := `{"level":"warn","req":{"status":404}}`
+	if start, end, ok := FieldSpan(str, ".req.status"); !ok || str[start:end] != "404" {
+		t.Errorf("FieldSpan(.req.status) = %d, %d, %v, want the position of 404", start, end, ok)
+	}
+	if _, _, ok := FieldSpan(str, ".user"); ok {
+		t.Errorf("FieldSpan(.user) found a field which isn't there")
+	}
+	if got := FieldName("level"); got != ".level" {
+		t.Errorf("FieldName(level) = %q, want %q", got, ".level")
+	}
+}
+
+func TestDetectJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		texts []string
+		want  bool
+	}{
+		{name: "json lines", texts: repeatLines(100, `{"n":%d,"msg":"x"}`), want: true},
+		{name: "a few broken lines", texts: append(repeatLines(95, `{"n":%d}`),
+			repeatLines(5, `{"n":%d`)...), want: true},
+		{name: "too many broken lines", texts: append(repeatLines(80, `{"n":%d}`),
+			repeatLines(20, `{"n":%d`)...), want: false},
+		{name: "arrays", texts: repeatLines(100, `[%d]`), want: false},
+		{name: "no lines", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectJSON(detectTestLines(tt.texts)); got != tt.want {
+				t.Errorf("detectJSON() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONFieldNames(t *testing.T) {
+	testLines := detectTestLines([]string{
+		`{"level":"info","msg":"a"}`,
+		`{"level":"warn","req":{"status":500}}`,
+		`not json`,
+	})
+
+	want := []string{".level", ".msg", ".req.status"}
+	if got := jsonFieldNames(testLines); !slices.Equal(got, want) {
+		t.Errorf("jsonFieldNames() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,127 @@
+package formats
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+// FormatLogfmt is the name of the logfmt format (ts=... level=warn msg="..."),
+// which doesn't need an entry in formats.toml.
+const FormatLogfmt = "logfmt"
+
+// lines with fewer pairs are most likely just text containing a '='
+const minLogfmtPairs = 2
+
+func detectLogfmt(testLines []*lines.Line) bool {
+	n := min(testCases, len(testLines))
+	if n == 0 {
+		return false
+	}
+
+	matched := 0
+	for _, line := range testLines[:n] {
+		if logfmtPairs(line.Str) >= minLogfmtPairs {
+			matched++
+		}
+	}
+
+	return float64(matched)/float64(n) > 0.9
+}
+
+// Counts only real key=value pairs, otherwise any line consisting of a few
+// words would look like a bunch of bare keys.
+func logfmtPairs(str string) int {
+	pairs := 0
+	for _, field := range logfmtFields(str) {
+		if field.Start > 0 && (str[field.Start-1] == '=' || str[field.Start-1] == '"') {
+			pairs++
+		}
+	}
+	return pairs
+}
+
+func logfmtFieldNames(testLines []*lines.Line) []string {
+	var names []string
+	for _, line := range testLines[:min(testCases, len(testLines))] {
+		for _, field := range logfmtFields(line.Str) {
+			if !slices.Contains(names, field.Name) {
+				names = append(names, field.Name)
+			}
+		}
+	}
+	return names
+}
+
+// Splits str into key=value pairs. Values are either bare or quoted, in the
+// latter case the position excludes the quotes. Keys without a value are
+// allowed and get the value "true". Returns nil if str isn't logfmt at all.
+func logfmtFields(str string) []Field {
+	var fields []Field
+
+	pos := 0
+	for {
+		for pos < len(str) && str[pos] == ' ' {
+			pos++
+		}
+		if pos == len(str) {
+			return fields
+		}
+
+		keyStart := pos
+		for pos < len(str) && str[pos] != '=' && str[pos] != ' ' && str[pos] != '"' {
+			pos++
+		}
+		if pos == keyStart {
+			return nil
+		}
+		key := str[keyStart:pos]
+
+		if pos == len(str) || str[pos] == ' ' {
+			fields = append(fields, Field{Name: key, Value: "true", Start: pos, End: pos})
+			continue
+		}
+		if str[pos] != '=' {
+			return nil
+		}
+		pos++
+
+		if pos < len(str) && str[pos] == '"' {
+			end := closingQuote(str, pos+1)
+			if end < 0 {
+				return nil
+			}
+			value, err := strconv.Unquote(str[pos : end+1])
+			if err != nil {
+				value = str[pos+1 : end]
+			}
+			fields = append(fields, Field{Name: key, Value: value, Start: pos + 1, End: end})
+			pos = end + 1
+		} else {
+			valueStart := pos
+			for pos < len(str) && str[pos] != ' ' {
+				pos++
+			}
+			fields = append(fields, Field{Name: key, Value: str[valueStart:pos],
+				Start: valueStart, End: pos})
+		}
+
+		if pos < len(str) && str[pos] != ' ' {
+			return nil
+		}
+	}
+}
+
+// returns the position of the quote ending the string starting at start or -1
+func closingQuote(str string, start int) int {
+	for i := start; i < len(str); i++ {
+		switch str[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,108 @@
+package formats
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLogfmtFields(t *testing.T) {
+	tests := []struct {
+		name string
+		str  string
+		want []Field
+	}{
+		{
+			name: "bare, quoted and valueless keys",
+			str:  `ts=12:00 level=warn msg="a \"b\"" debug`,
+			want: []Field{
+				{Name: "ts", Value: "12:00", Start: 3, End: 8},
+				{Name: "level", Value: "warn", Start: 15, End: 19},
+				{Name: "msg", Value: `a "b"`, Start: 25, End: 32},
+				{Name: "debug", Value: "true", Start: 39, End: 39},
+			},
+		},
+		{
+			name: "empty values and extra spaces",
+			str:  `a=  b=""`,
+			want: []Field{
+				{Name: "a", Value: "", Start: 2, End: 2},
+				{Name: "b", Value: "", Start: 7, End: 7},
+			},
+		},
+		{name: "unterminated quote", str: `msg="oops level=warn`, want: nil},
+		{name: "garbage after quoted value", str: `msg="a"b level=warn`, want: nil},
+		{name: "quote in key", str: `"msg"=a`, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := logfmtFields(tt.str); !slices.Equal(got, tt.want) {
+				t.Errorf("logfmtFields(%q) = %#v, want %#v", tt.str, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogfmtPairs(t *testing.T) {
+	tests := []struct {
+		str  string
+		want int
+	}{
+		{str: `level=warn msg="x y" debug`, want: 2},
+		{str: `just some words`, want: 0},
+		{str: `see a=b in the docs`, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.str, func(t *testing.T) {
+			if got := logfmtPairs(tt.str); got != tt.want {
+				t.Errorf("logfmtPairs(%q) = %d, want %d", tt.str, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogfmtFieldSpan(t *testing.T) {
+	useFileFormat(t, FormatLogfmt, nil, []string{"level", "msg"})
+
+	str := `level=warn msg="disk full"`
+	if start, end, ok := FieldSpan(str, "msg"); !ok || str[start:end] != "disk full" {
+		t.Errorf("FieldSpan(msg) = %d, %d, %v, want the position of disk full", start, end, ok)
+	}
+	if _, _, ok := FieldSpan(str, "host"); ok {
+		t.Errorf("FieldSpan(host) found a field which isn't there")
+	}
+}
+
+func TestDetectLogfmt(t *testing.T) {
+	tests := []struct {
+		name  string
+		texts []string
+		want  bool
+	}{
+		{name: "logfmt", texts: repeatLines(100, `level=info n=%d msg="x y"`), want: true},
+		{name: "a single pair per line", texts: repeatLines(100, `n=%d and more`), want: false},
+		{name: "words", texts: repeatLines(100, `line %d`), want: false},
+		{name: "no lines", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectLogfmt(detectTestLines(tt.texts)); got != tt.want {
+				t.Errorf("detectLogfmt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogfmtFieldNames(t *testing.T) {
+	testLines := detectTestLines([]string{
+		`level=info msg=a`,
+		`level=warn status=500 msg=b`,
+	})
+
+	want := []string{"level", "msg", "status"}
+	if got := logfmtFieldNames(testLines); !slices.Equal(got, want) {
+		t.Errorf("logfmtFieldNames() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,132 @@
+package formats
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+// The level of a line is taken from (in this order)
+//
+//   - a syslog priority like <3> at the beginning of the line
+//   - a field called level, severity etc., e.g. JSON "severity" or level=warn
+//   - a level in capitals like ERROR or WARN near the beginning of the line
+
+// only this much of a line gets searched for a level in capitals
+const severitySearchLength = 200
+
+var priorityRegex = regexp.MustCompile(`^<(\d{1,3})>`)
+
+var severityWordRegex = regexp.MustCompile(
+	`\b(TRACE|DEBUG|INFO|NOTICE|WARN|WARNING|ERROR|ERR|SEVERE|CRIT|CRITICAL|FATAL|PANIC|ALERT|EMERG)\b`)
+
+// e.g. level=warn in lines which aren't in logfmt otherwise
+var severityPairRegex = regexp.MustCompile(`(?i)\b(?:level|lvl|severity)[=:]\s*"?([a-z]+)`)
+
+var severityFieldNames = []string{"level", "lvl", "severity", "loglevel",
+	"log_level", "levelname"}
+
+var severityWords = map[string]lines.Severity{
+	"trace":         lines.SeverityTrace,
+	"finest":        lines.SeverityTrace,
+	"finer":         lines.SeverityTrace,
+	"debug":         lines.SeverityDebug,
+	"dbg":           lines.SeverityDebug,
+	"fine":          lines.SeverityDebug,
+	"info":          lines.SeverityInfo,
+	"information":   lines.SeverityInfo,
+	"informational": lines.SeverityInfo,
+	"notice":        lines.SeverityNotice,
+	"warn":          lines.SeverityWarning,
+	"warning":       lines.SeverityWarning,
+	"error":         lines.SeverityError,
+	"err":           lines.SeverityError,
+	"severe":        lines.SeverityError,
+	"crit":          lines.SeverityCritical,
+	"critical":      lines.SeverityCritical,
+	"fatal":         lines.SeverityCritical,
+	"panic":         lines.SeverityCritical,
+	"alert":         lines.SeverityCritical,
+	"emerg":         lines.SeverityCritical,
+	"emergency":     lines.SeverityCritical,
+}
+
+// syslog severities 0 (emerg) to 7 (debug)
+var syslogSeverities = []lines.Severity{
+	lines.SeverityCritical,
+	lines.SeverityCritical,
+	lines.SeverityCritical,
+	lines.SeverityError,
+	lines.SeverityWarning,
+	lines.SeverityNotice,
+	lines.SeverityInfo,
+	lines.SeverityDebug,
+}
+
+// Severity returns the normalized level of str or lines.SeverityNone if it
+// doesn't have one.
+func Severity(str string) lines.Severity {
+	if parts := priorityRegex.FindStringSubmatch(str); parts != nil {
+		priority, _ := strconv.Atoi(parts[1])
+		return syslogSeverities[priority%8]
+	}
+
+	for _, field := range Fields(str) {
+		if !isSeverityField(field.Name) {
+			continue
+		}
+		if severity := ParseSeverity(field.Value); severity != lines.SeverityNone {
+			return severity
+		}
+	}
+
+	head := str[:min(len(str), severitySearchLength)]
+	if parts := severityPairRegex.FindStringSubmatch(head); parts != nil {
+		if severity := ParseSeverity(parts[1]); severity != lines.SeverityNone {
+			return severity
+		}
+	}
+	if word := severityWordRegex.FindString(head); word != "" {
+		return ParseSeverity(word)
+	}
+
+	return lines.SeverityNone
+}
+
+// e.g. level, .severity or .log.level
+func isSeverityField(name string) bool {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, fieldName := range severityFieldNames {
+		if strings.EqualFold(name, fieldName) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSeverity understands the usual names of levels as well as syslog
+// (0-7) and bunyan / pino (10-60) numbers.
+func ParseSeverity(value string) lines.Severity {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if severity, ok := severityWords[value]; ok {
+		return severity
+	}
+
+	number, err := strconv.Atoi(value)
+	switch {
+	case err != nil:
+		return lines.SeverityNone
+	case number >= 0 && number < len(syslogSeverities):
+		return syslogSeverities[number]
+	case number >= 10 && number <= 60 && number%10 == 0:
+		return []lines.Severity{lines.SeverityTrace, lines.SeverityDebug,
+			lines.SeverityInfo, lines.SeverityWarning, lines.SeverityError,
+			lines.SeverityCritical}[number/10-1]
+	}
+
+	return lines.SeverityNone
+}
@@ -0,0 +1,70 @@
+package formats
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+func TestSeverity(t *testing.T) {
+	tests := []struct {
+		name       string
+		fileFormat string
+		fields     []string
+		str        string
+		want       lines.Severity
+	}{
+		{name: "syslog priority", str: "<11>Oct 11 22:14:15 web1 sshd: x", want: lines.SeverityError},
+		{name: "syslog priority with facility", str: "<165>1 2024-03-05T10:00:00Z host app", want: lines.SeverityNotice},
+		{name: "word in capitals", str: "2024-03-05 10:00:00 WARN disk almost full", want: lines.SeverityWarning},
+		{name: "word too far in", str: strings.Repeat("x", severitySearchLength) + " ERROR", want: lines.SeverityNone},
+		{name: "lower case word doesn't count", str: "no error here", want: lines.SeverityNone},
+		{name: "pair", str: "ts=1 level=debug msg=x", want: lines.SeverityDebug},
+		{name: "quoted pair", str: `x severity: "critical"`, want: lines.SeverityCritical},
+		{name: "json field", fileFormat: FormatJSON, fields: []string{".log.level", ".msg"},
+			str: `{"log":{"level":"error"},"msg":"INFO is not the level"}`, want: lines.SeverityError},
+		{name: "bunyan number", fileFormat: FormatJSON, fields: []string{".level"},
+			str: `{"level":30,"msg":"x"}`, want: lines.SeverityInfo},
+		{name: "logfmt field", fileFormat: FormatLogfmt, fields: []string{"lvl", "msg"},
+			str: `lvl=warn msg="ERROR in the message"`, want: lines.SeverityWarning},
+		{name: "nothing", str: "just a line", want: lines.SeverityNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFileFormat(t, tt.fileFormat, nil, tt.fields)
+
+			if got := Severity(tt.str); got != tt.want {
+				t.Errorf("Severity(%q) = %v, want %v", tt.str, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		value string
+		want  lines.Severity
+	}{
+		{value: "warn", want: lines.SeverityWarning},
+		{value: " Warning ", want: lines.SeverityWarning},
+		{value: "FATAL", want: lines.SeverityCritical},
+		{value: "fine", want: lines.SeverityDebug},
+		{value: "3", want: lines.SeverityError},
+		{value: "7", want: lines.SeverityDebug},
+		{value: "50", want: lines.SeverityError},
+		{value: "10", want: lines.SeverityTrace},
+		{value: "8", want: lines.SeverityNone},
+		{value: "35", want: lines.SeverityNone},
+		{value: "verbose", want: lines.SeverityNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := ParseSeverity(tt.value); got != tt.want {
+				t.Errorf("ParseSeverity(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package formats
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+// Layouts tried (in this order) to find out how a format writes its
+// timestamps. dateparser would be more flexible but is way too slow to be
+// called for each and every line.
+var commonLayouts = []string{
+	"Jan _2 15:04:05",
+	"Jan _2 15:04:05.999999999",
+	"02/Jan/2006:15:04:05 -0700",
+	"Mon Jan _2 15:04:05 2006",
+	"Mon Jan _2 15:04:05.999999999 2006",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999-0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05,999999999",
+	"2006/01/02 15:04:05.999999999",
+}
+
+// Used for files of unknown format: a timestamp at the very beginning of the
+// line, optionally in brackets.
+var leadingTimestampRegex = regexp.MustCompile(
+	`^\[?(\w{3}\s+\d{1,2}\s+\d{2}:\d{2}:\d{2}(?:\.\d+)?|` +
+		`\d{4}[-/]\d{2}[-/]\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?)`)
+
+// Layouts for timestamps given as seconds or milliseconds since the epoch
+const (
+	LayoutUnix      = "unix"
+	LayoutUnixMilli = "unixms"
+)
+
+// fields of JSON and logfmt lines which usually hold the timestamp
+var timestampFields = []string{"time", "ts", "timestamp", "@timestamp", "t", "date"}
+
+// TimeParser extracts the timestamps of lines of a specific format. Which
+// capture group (or field) holds the timestamp and which layout it uses is
+// either declared in formats.toml or gets learned from the first line that
+// contains a recognizable timestamp.
+type TimeParser struct {
+	regex *regexp.Regexp
+	group int
+
+	// used instead of regex for formats like JSON lines
+	fields func(string) []Field
+	field  string
+
+	layout string
+
+	// timestamps without a zone are in this location
+	location *time.Location
+	// timestamps without a year are assumed to be not (much) newer than this
+	reference time.Time
+
+	// lines without a timestamp inherit the one of the previous line
+	last time.Time
+}
+
+// how much newer than the reference time a timestamp without a year may be
+// before it's considered to be from the year before
+const yearSlack = 24 * time.Hour
+
+// NewTimeParser returns a parser for lines of fileFormat, matching regex. If
+// the format is unknown, only timestamps at the beginning of lines are
+// recognized. Timestamps without a year get the year of reference (usually
+// the modification time of the file), or the year before if that would put
+// them after reference.
+func NewTimeParser(fileFormat string, regex *regexp.Regexp,
+	reference time.Time) *TimeParser {
+
+	tp := &TimeParser{
+		group:     -1,
+		location:  config.User().Location,
+		reference: reference,
+	}
+	if tp.location == nil {
+		tp.location = time.Local
+	}
+
+	switch fileFormat {
+	case FormatJSON:
+		tp.fields = jsonFields
+	case FormatLogfmt:
+		tp.fields = logfmtFields
+	default:
+		if regex == nil {
+			tp.regex = leadingTimestampRegex
+			break
+		}
+		format := config.Formats()[fileFormat]
+		tp.regex = regex
+		tp.group = timestampGroup(regex, format.Timestamp)
+		tp.layout = format.Layout
+	}
+
+	return tp
+}
+
+// NewFileTimeParser returns a parser for the format detected for the file(s)
+// currently shown.
+func NewFileTimeParser() *TimeParser {
+	return NewTimeParser(config.User().FileFormat, config.User().FileFormatRegex,
+		ReferenceTime(config.User().FilePaths))
+}
+
+// ReferenceTime returns the modification time of the newest of filePaths.
+// Without any files (e.g. stdin) that's now.
+func ReferenceTime(filePaths []string) time.Time {
+	var reference time.Time
+	for _, filePath := range filePaths {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(reference) {
+			reference = info.ModTime()
+		}
+	}
+
+	if reference.IsZero() {
+		return time.Now()
+	}
+	return reference
+}
+
+// Returns -1 if the group isn't declared and there's no group with a name
+// like "timestamp" either.
+func timestampGroup(regex *regexp.Regexp, declared string) int {
+	if declared == "" {
+		for _, name := range timestampFields {
+			if i := regex.SubexpIndex(name); i > 0 {
+				return i
+			}
+		}
+		return -1
+	}
+
+	if group, err := strconv.Atoi(declared); err == nil {
+		return group
+	}
+
+	i := regex.SubexpIndex(declared)
+	if i < 0 {
+		log.Printf("format regex doesn't have a group named %s", declared)
+	}
+	return i
+}
+
+// Parse returns the timestamp of str and true, or false if it doesn't
+// contain one.
+func (tp *TimeParser) Parse(str string) (time.Time, bool) {
+	if tp.fields != nil {
+		return tp.parseFields(str)
+	}
+
+	matches := tp.regex.FindStringSubmatch(str)
+	if matches == nil {
+		return time.Time{}, false
+	}
+
+	if tp.group < 0 {
+		return tp.learn(matches)
+	}
+
+	if tp.group >= len(matches) {
+		return time.Time{}, false
+	}
+
+	return tp.parseValue(matches[tp.group], true)
+}
+
+// Stamp sets line.When to the timestamp of the line. If the line doesn't
+// have one, the timestamp of the previously stamped line is used instead.
+func (tp *TimeParser) Stamp(line *lines.Line) {
+	if t, ok := tp.Parse(line.Str); ok {
+		tp.last = t
+	}
+	line.When = tp.last
+}
+
+func (tp *TimeParser) parseFields(str string) (time.Time, bool) {
+	fields := tp.fields(str)
+
+	if tp.field == "" {
+		for _, field := range fields {
+			if slices.Contains(timestampFields, strings.TrimPrefix(field.Name, ".")) {
+				tp.field = field.Name
+				break
+			}
+		}
+	}
+
+	for _, field := range fields {
+		if field.Name == tp.field {
+			return tp.parseValue(field.Value, true)
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Uses the layout if it's already known, otherwise tries to guess it. Numbers
+// only get taken as seconds or milliseconds since the epoch if value is known
+// to be a timestamp.
+func (tp *TimeParser) parseValue(value string, isTimestamp bool) (time.Time, bool) {
+	if tp.layout != "" {
+		return tp.parseWithLayout(tp.layout, value)
+	}
+
+	for _, layout := range commonLayouts {
+		if t, ok := tp.parseWithLayout(layout, value); ok {
+			tp.layout = layout
+			return t, true
+		}
+	}
+
+	if !isTimestamp {
+		return time.Time{}, false
+	}
+
+	integer, _, _ := strings.Cut(strings.TrimSpace(value), ".")
+	var layout string
+	switch len(integer) {
+	case 10:
+		layout = LayoutUnix
+	case 13:
+		layout = LayoutUnixMilli
+	default:
+		return time.Time{}, false
+	}
+
+	t, ok := tp.parseWithLayout(layout, value)
+	if ok {
+		tp.layout = layout
+	}
+	return t, ok
+}
+
+func (tp *TimeParser) learn(matches []string) (time.Time, bool) {
+	for group := 1; group < len(matches); group++ {
+		if !strings.ContainsAny(matches[group], "0123456789") ||
+			!strings.Contains(matches[group], ":") {
+
+			continue
+		}
+
+		if t, ok := tp.parseValue(matches[group], false); ok {
+			tp.group = group
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func (tp *TimeParser) parseWithLayout(layout string, value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+
+	switch layout {
+	case LayoutUnix:
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(0, int64(seconds*float64(time.Second))), true
+	case LayoutUnixMilli:
+		milliseconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.UnixMilli(milliseconds), true
+	}
+
+	t, err := time.ParseInLocation(layout, value, tp.location)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// traditional syslog timestamps don't have a year
+	if t.Year() == 0 {
+		t = tp.inferYear(t)
+	}
+
+	return t, true
+}
+
+// Doesn't depend on any other lines so it works for lines looked at in
+// random order, e.g. by sort.Search(). A file written from December to
+// January gets December of the year before.
+func (tp *TimeParser) inferYear(t time.Time) time.Time {
+	reference := tp.reference.In(tp.location)
+	withYear := func(year int) time.Time {
+		return time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(),
+			t.Nanosecond(), t.Location())
+	}
+
+	if inYear := withYear(reference.Year()); !inYear.After(reference.Add(yearSlack)) {
+		return inYear
+	}
+	return withYear(reference.Year() - 1)
+}
@@ -0,0 +1,182 @@
+package formats
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+func TestTimeParser(t *testing.T) {
+	reference := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		format string
+		regex  string
+		// declared in formats.toml
+		timestamp string
+		layout    string
+		lines     []string
+		// RFC3339, empty if the line has no timestamp
+		want []string
+	}{
+		{
+			name: "leading timestamp",
+			lines: []string{
+				"2024-03-05T10:11:12Z started",
+				"no timestamp",
+				"2024-03-05T10:11:13.5+01:00 stopped",
+			},
+			want: []string{"2024-03-05T10:11:12Z", "", "2024-03-05T09:11:13.5Z"},
+		},
+		{
+			name: "layout is learned from the first line",
+			lines: []string{
+				"[2024/03/05 10:11:12] started",
+				"Mar  5 10:11:13 host stopped",
+				"[2024/03/05 10:11:14.25] stopped",
+			},
+			want: []string{"2024-03-05T10:11:12Z", "", "2024-03-05T10:11:14.25Z"},
+		},
+		{
+			name: "syslog without year",
+			lines: []string{
+				"Mar  5 10:11:12 host started",
+				"Dec 31 23:00:00 host stopped",
+			},
+			want: []string{"2024-03-05T10:11:12Z", "2023-12-31T23:00:00Z"},
+		},
+		{
+			name:   "group learned from regex",
+			format: "test",
+			regex:  `^(\w+) \[([^]]+)\] (.*)$`,
+			lines: []string{
+				"web [05/Mar/2024:10:11:12 +0000] GET /",
+				"web [05/Mar/2024:10:11:13 +0200] GET /x",
+			},
+			want: []string{"2024-03-05T10:11:12Z", "2024-03-05T08:11:13Z"},
+		},
+		{
+			name:      "declared group and layout",
+			format:    "test",
+			regex:     `^(?P<when>\S+) (\d+:\d+) (.*)$`,
+			timestamp: "when",
+			layout:    "20060102",
+			lines: []string{
+				"20240305 10:11 started",
+				"2024-03-05 10:11 stopped",
+			},
+			want: []string{"2024-03-05T00:00:00Z", ""},
+		},
+		{
+			name:   "json",
+			format: FormatJSON,
+			lines: []string{
+				`{"msg":"started","ts":"2024-03-05 10:11:12.123"}`,
+				`{"msg":"no timestamp"}`,
+				`{"ts":"2024-03-05 10:11:13","msg":"stopped"}`,
+			},
+			want: []string{"2024-03-05T10:11:12.123Z", "", "2024-03-05T10:11:13Z"},
+		},
+		{
+			name:   "json unix seconds",
+			format: FormatJSON,
+			lines: []string{
+				`{"time":1709633472,"msg":"started"}`,
+				`{"time":1709633473.5,"msg":"stopped"}`,
+			},
+			want: []string{"2024-03-05T10:11:12Z", "2024-03-05T10:11:13.5Z"},
+		},
+		{
+			name:   "logfmt unix milliseconds",
+			format: FormatLogfmt,
+			lines: []string{
+				"t=1709633472123 level=info",
+			},
+			want: []string{"2024-03-05T10:11:12.123Z"},
+		},
+	}
+
+	location := config.User().Location
+	defer func() { config.User().Location = location }()
+	config.User().Location = time.UTC
+
+	formats := config.Formats()
+	defer delete(formats, "test")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var regex *regexp.Regexp
+			if tt.regex != "" {
+				regex = regexp.MustCompile(tt.regex)
+				formats[tt.format] = config.Format{
+					Regex:     tt.regex,
+					Timestamp: tt.timestamp,
+					Layout:    tt.layout,
+				}
+			}
+
+			tp := NewTimeParser(tt.format, regex, reference)
+			for i, line := range tt.lines {
+				got, ok := tp.Parse(line)
+				var gotStr string
+				if ok {
+					gotStr = got.UTC().Format(time.RFC3339Nano)
+				}
+				if gotStr != tt.want[i] {
+					t.Errorf("Parse(%q) = %q, want %q", line, gotStr, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestInferYear(t *testing.T) {
+	tests := []struct {
+		reference time.Time
+		line      string
+		wantYear  int
+	}{
+		{time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), "Jan  2 09:00:00", 2024},
+		{time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), "Dec 31 23:00:00", 2023},
+		// a bit newer than the file is fine, e.g. clocks being off
+		{time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), "Jan  3 09:00:00", 2024},
+		{time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), "Jan  4 09:00:00", 2023},
+		{time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC), "Jan  1 01:00:00", 2024},
+	}
+
+	location := config.User().Location
+	defer func() { config.User().Location = location }()
+	config.User().Location = time.UTC
+
+	for _, tt := range tests {
+		t.Run(tt.reference.String()+" "+tt.line, func(t *testing.T) {
+			got, ok := NewTimeParser("", nil, tt.reference).Parse(tt.line)
+			if !ok {
+				t.Fatalf("Parse(%q) found no timestamp", tt.line)
+			}
+			if got.Year() != tt.wantYear {
+				t.Errorf("Parse(%q) = %v, want year %d", tt.line, got, tt.wantYear)
+			}
+		})
+	}
+}
+
+func TestStampInheritsTimestamp(t *testing.T) {
+	location := config.User().Location
+	defer func() { config.User().Location = location }()
+	config.User().Location = time.UTC
+
+	tp := NewTimeParser("", nil, time.Now())
+	first := lines.NewLine(0, "2024-03-05 10:11:12 started")
+	trace := lines.NewLine(1, "\tat Main.run(Main.java:12)")
+	tp.Stamp(first)
+	tp.Stamp(trace)
+
+	if !trace.When.Equal(first.When) || first.When.IsZero() {
+		t.Errorf("continuation line got %v, want %v", trace.When, first.When)
+	}
+}
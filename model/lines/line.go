@@ -9,9 +9,18 @@ const (
 	LineMatched
 	LineDimmed
 	LineHidden
+	// not a line at all, separates lines which aren't adjacent in the file,
+	// see DividerLine
+	LineDivider
 	LineDoesNotExist = -1
 )
 
+// origin of lines a command wrote to stderr
+const OriginStderr = "stderr"
+
+// appended to lines longer than the configured maximum line length
+const TruncatedMarker = " [...truncated]"
+
 var NonExistingLine = &Line{
 	No:      -1,
 	Status:  LineDoesNotExist,
@@ -19,12 +28,30 @@ var NonExistingLine = &Line{
 	Str:     "",
 }
 
+// Shown between groups of matches and their context.
+var DividerLine = &Line{
+	No:     -1,
+	Status: LineDivider,
+}
+
 type Line struct {
 	No      int
 	Status  LineStatus
 	Matched bool
 	Str     string
 	When    time.Time
+	// where the line physically came from, e.g. the path of the file
+	Origin string
+	// marker lines are not part of the file but inserted by infiltrator
+	// itself, e.g. when a followed file got rotated or truncated
+	Marker bool
+	// continuation lines get the severity of the record they belong to
+	Severity Severity
+	// number of identical lines folded into this one (including itself),
+	// 0 if there are none
+	Repeats int
+	// belongs to a rarely seen template
+	Anomaly bool
 	// each byte in ColorIndex is a color index for each byte in Str
 	ColorIndex []uint8
 }
@@ -39,9 +66,17 @@ func NewLine(lineNo int, text string) *Line {
 	}
 }
 
+func NewMarkerLine(lineNo int, text string) *Line {
+	line := NewLine(lineNo, text)
+	line.Marker = true
+	return line
+}
+
 func (l *Line) CleanUp() {
 	l.Status = LineWithoutStatus
 	l.Matched = false
+	l.Repeats = 0
+	l.Anomaly = false
 	for i := range l.ColorIndex {
 		l.ColorIndex[i] = 0
 	}
@@ -0,0 +1,31 @@
+package lines
+
+// Severity is the level of a line (e.g. ERROR, <3> or level=warn) normalized
+// to one scale. SeverityNone means no level was found.
+type Severity int8
+
+const (
+	SeverityNone Severity = iota
+	SeverityTrace
+	SeverityDebug
+	SeverityInfo
+	SeverityNotice
+	SeverityWarning
+	SeverityError
+	SeverityCritical
+)
+
+var SeverityStrings = []string{
+	"none",
+	"trace",
+	"debug",
+	"info",
+	"notice",
+	"warning",
+	"error",
+	"critical",
+}
+
+func (s Severity) String() string {
+	return SeverityStrings[s]
+}
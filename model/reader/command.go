@@ -0,0 +1,139 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/busy"
+	"github.com/claude42/infiltrator/model/lines"
+	"github.com/claude42/infiltrator/util"
+)
+
+// how long a command gets to exit after SIGTERM before it gets killed
+const commandWaitDelay = 3 * time.Second
+
+// RunCommand runs command and sends everything it writes to stdout (and to
+// stderr if withStderr is set) to ch. It returns once the command has exited.
+// When ctx gets cancelled, the command gets terminated.
+func (r *Reader) RunCommand(ctx context.Context, wg *sync.WaitGroup,
+	command []string, withStderr bool, ch chan<- []*lines.Line) {
+
+	defer wg.Done()
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return terminate(cmd)
+	}
+	cmd.WaitDelay = commandWaitDelay
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		r.commandFailed(ctx, command, err, ch)
+		return
+	}
+
+	var stderr io.Reader
+	if withStderr {
+		stderr, err = cmd.StderrPipe()
+		if err != nil {
+			r.commandFailed(ctx, command, err, ch)
+			return
+		}
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		r.commandFailed(ctx, command, err, ch)
+		return
+	}
+	config.PostEventFunc(NewEventCommandStatus(true, 0, nil))
+
+	// all output must have been read before calling Wait()
+	var pipeWg sync.WaitGroup
+	pipeWg.Add(1)
+	go r.readPipe(ctx, &pipeWg, stdout, "", ch)
+	if stderr != nil {
+		pipeWg.Add(1)
+		go r.readPipe(ctx, &pipeWg, stderr, lines.OriginStderr, ch)
+	}
+	pipeWg.Wait()
+
+	err = cmd.Wait()
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		log.Printf("error waiting for command: %+v", err)
+	}
+	exitCode := cmd.ProcessState.ExitCode()
+
+	config.PostEventFunc(NewEventCommandStatus(false, exitCode, nil))
+	r.sendCommandMarker(ctx, fmt.Sprintf("--- %s exited with status %d ---",
+		command[0], exitCode), ch)
+}
+
+func (r *Reader) readPipe(ctx context.Context, wg *sync.WaitGroup,
+	pipe io.Reader, origin string, ch chan<- []*lines.Line) {
+
+	defer wg.Done()
+
+	// the command gets terminated anyways if ctx is done, no need to read any
+	// further then
+	scanBatched(ctx, newLineScanner(pipe), func(text string) *lines.Line {
+		busy.Spin()
+		line := lines.NewLine(0, text)
+		line.Origin = origin
+		return line
+	}, ch)
+}
+
+func (r *Reader) commandFailed(ctx context.Context, command []string, err error,
+	ch chan<- []*lines.Line) {
+
+	log.Printf("error running command %v: %+v", command, err)
+	config.PostEventFunc(NewEventCommandStatus(false, -1, err))
+	r.sendCommandMarker(ctx, fmt.Sprintf("--- %s failed: %v ---", command[0], err), ch)
+}
+
+// line numbers get assigned by the source
+func (r *Reader) sendCommandMarker(ctx context.Context, text string,
+	ch chan<- []*lines.Line) {
+
+	sendOrGiveUp(ctx, ch, []*lines.Line{lines.NewMarkerLine(0, text)})
+}
+
+// Nobody will receive anything anymore once ctx is done (e.g. when quitting),
+// so don't block forever in this case.
+func sendOrGiveUp(ctx context.Context, ch chan<- []*lines.Line,
+	newLines []*lines.Line) bool {
+
+	select {
+	case ch <- newLines:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Created by RunCommand() whenever the command gets started or exits.
+
+type EventCommandStatus struct {
+	util.EventImpl
+
+	Running  bool
+	ExitCode int
+	Err      error
+}
+
+func NewEventCommandStatus(running bool, exitCode int, err error) *EventCommandStatus {
+	ev := &EventCommandStatus{Running: running, ExitCode: exitCode, Err: err}
+	ev.EventImpl.SetEventNow()
+	return ev
+}
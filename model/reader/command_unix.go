@@ -0,0 +1,19 @@
+//go:build !windows
+
+package reader
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// The command gets a process group of its own, so the whole pipeline (e.g.
+// tail -f | grep) gets terminated and not only the shell.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func terminate(cmd *exec.Cmd) error {
+	// negative pid: the whole process group
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
@@ -0,0 +1,10 @@
+package reader
+
+import "os/exec"
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// there's no SIGTERM on Windows
+func terminate(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
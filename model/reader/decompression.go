@@ -0,0 +1,164 @@
+package reader
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sync/atomic"
+
+	"github.com/claude42/infiltrator/model/formats"
+)
+
+const decompressionBufferSize = 64 * 1024
+
+// decompression keeps the decompressor of a followed compressed file open in
+// its own goroutine, so it continues where it stopped when the file grows
+// instead of decompressing the whole file again.
+type decompression struct {
+	// decompressed data, closed once the stream ended or turned out broken
+	data chan []byte
+	// sent once everything written to the file so far got decompressed
+	idle chan struct{}
+	// the decompressor should look for new data in the file
+	more chan struct{}
+	// closed to stop the goroutine
+	done chan struct{}
+
+	// how much of the compressed file has been read
+	offset atomic.Int64
+	ended  bool
+	// the decompressor waits for more until the next read()
+	waiting bool
+	// decompressed data after the last complete line
+	pending []byte
+}
+
+// startDecompression decompresses file from its beginning, skipping the
+// first skip decompressed bytes.
+func startDecompression(decompressor *formats.Decompressor, file *os.File,
+	skip int64) *decompression {
+
+	d := &decompression{
+		data: make(chan []byte),
+		idle: make(chan struct{}),
+		more: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go d.run(decompressor, file, skip)
+	return d
+}
+
+func (d *decompression) run(decompressor *formats.Decompressor, file *os.File,
+	skip int64) {
+
+	defer close(d.data)
+
+	decompressed, err := decompressor.NewReader(&followingReader{file: file, d: d})
+	if err != nil {
+		log.Printf("error decompressing %s: %+v", file.Name(), err)
+		return
+	}
+	defer decompressed.Close()
+
+	if _, err = io.CopyN(io.Discard, decompressed, skip); err != nil {
+		return
+	}
+
+	buf := make([]byte, decompressionBufferSize)
+	for {
+		n, err := decompressed.Read(buf)
+		if n > 0 {
+			select {
+			case d.data <- slices.Clone(buf[:n]):
+			case <-d.done:
+				return
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return
+		} else if err != nil {
+			select {
+			case <-d.done:
+			default:
+				log.Printf("error decompressing %s: %+v", file.Name(), err)
+			}
+			return
+		}
+	}
+}
+
+// read returns all complete lines decompressed since the last call. Blocks
+// until the decompressor has caught up with the file. Whatever follows the
+// last line ending will be returned next time.
+func (d *decompression) read() []byte {
+	if d.ended {
+		return nil
+	}
+	if d.waiting {
+		d.more <- struct{}{}
+		d.waiting = false
+	}
+
+	collecting := true
+	for collecting {
+		select {
+		case data, ok := <-d.data:
+			if !ok {
+				d.ended = true
+				collecting = false
+				break
+			}
+			d.pending = append(d.pending, data...)
+		case <-d.idle:
+			d.waiting = true
+			collecting = false
+		}
+	}
+
+	end := bytes.LastIndexByte(d.pending, '\n')
+	if end < 0 {
+		return nil
+	}
+	data := d.pending[:end+1]
+	d.pending = slices.Clone(d.pending[end+1:])
+	return data
+}
+
+func (d *decompression) stop() {
+	close(d.done)
+}
+
+// followingReader doesn't return io.EOF at the end of the file but waits
+// until there's more, decompressors can't continue after io.EOF. Uses its
+// own offset, the file's one doesn't get touched.
+type followingReader struct {
+	file *os.File
+	d    *decompression
+}
+
+func (r *followingReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.file.ReadAt(p, r.d.offset.Load())
+		r.d.offset.Add(int64(n))
+		if n > 0 {
+			return n, nil
+		}
+		if !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+
+		select {
+		case r.d.idle <- struct{}{}:
+		case <-r.d.done:
+			return 0, io.EOF
+		}
+		select {
+		case <-r.d.more:
+		case <-r.d.done:
+			return 0, io.EOF
+		}
+	}
+}
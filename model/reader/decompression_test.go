@@ -0,0 +1,58 @@
+package reader
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/claude42/infiltrator/model/formats"
+)
+
+func TestDecompressionContinues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log.gz")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	writer := gzip.NewWriter(out)
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var d *decompression
+	defer func() { d.stop() }()
+
+	steps := []struct {
+		write string
+		want  string
+	}{
+		{"first\nsec", "first\n"},
+		{"ond\n", "second\n"},
+		{"no line ending", ""},
+		{"\nlast\n", "no line ending\nlast\n"},
+	}
+
+	for _, step := range steps {
+		writer.Write([]byte(step.write))
+		if err := writer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if d == nil {
+			decompressor, err := formats.DetectCompression(file)
+			if err != nil || decompressor == nil {
+				t.Fatalf("DetectCompression() = %v, %v", decompressor, err)
+			}
+			d = startDecompression(decompressor, file, 0)
+		}
+
+		if got := string(d.read()); got != step.want {
+			t.Errorf("after writing %q read() = %q, want %q", step.write, got, step.want)
+		}
+	}
+}
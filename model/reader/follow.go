@@ -0,0 +1,365 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/claude42/infiltrator/model/formats"
+	"github.com/claude42/infiltrator/model/lines"
+	"github.com/fsnotify/fsnotify"
+)
+
+// follower implements "tail -F" semantics: it keeps on reading from filePath
+// even after the file got rotated away, removed and re-created or truncated.
+type follower struct {
+	r        *Reader
+	filePath string
+	file     *os.File
+	ch       chan<- []*lines.Line
+	lineNo   int
+
+	// optional, if set all new lines get timestamped
+	timeParser *formats.TimeParser
+
+	// set for compressed files, consumed then counts the decompressed bytes
+	// read so far
+	decompressor  *formats.Decompressor
+	consumed      int64
+	decompression *decompression
+
+	// set once the file has been renamed or removed, following continues as
+	// soon as a new file shows up under filePath
+	gone bool
+}
+
+// startWatching takes over ownership of file and closes it once it's done.
+func (r *Reader) startWatching(ctx context.Context, filePath string,
+	file *os.File, ch chan<- []*lines.Line, lineNo int) {
+
+	r.newFollower(filePath, file, ch, lineNo).run(ctx)
+}
+
+func (r *Reader) newFollower(filePath string, file *os.File,
+	ch chan<- []*lines.Line, lineNo int) *follower {
+
+	return &follower{
+		r:        r,
+		filePath: filepath.Clean(filePath),
+		file:     file,
+		ch:       ch,
+		lineNo:   lineNo,
+	}
+}
+
+// passed as offset to newFollowerAt()
+const followFromEnd = -1
+
+// newFollowerAt opens filePath and returns a follower continuing at offset,
+// or at the end of the file if offset is followFromEnd. For compressed files
+// the offset counts decompressed bytes.
+func (r *Reader) newFollowerAt(filePath string, offset int64,
+	ch chan<- []*lines.Line, lineNo int) (*follower, error) {
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	decompressor, err := formats.DetectCompression(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	f := r.newFollower(filePath, file, ch, lineNo)
+	f.decompressor = decompressor
+
+	if decompressor == nil {
+		if offset == followFromEnd {
+			_, err = file.Seek(0, io.SeekEnd)
+		} else {
+			_, err = file.Seek(offset, io.SeekStart)
+		}
+	} else {
+		if offset == followFromEnd {
+			offset, err = f.decompressedSize()
+		}
+		f.consumed = offset
+	}
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *follower) run(ctx context.Context) {
+	defer func() {
+		f.stopDecompression()
+		if f.file != nil {
+			f.file.Close()
+		}
+	}()
+
+	log.Println("Start watching")
+	watcher, err := f.r.initWatcher(f.filePath)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer watcher.Close()
+
+	err = f.keepWatching(ctx, watcher)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+}
+
+// Watches the directory as well so we get notified when the file gets
+// re-created after it has been rotated away.
+func (r *Reader) initWatcher(filePath string) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("error creating watcher: %w", err)
+	}
+
+	err = watcher.Add(filepath.Dir(filePath))
+	if err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("error watching directory of %s: %w", filePath, err)
+	}
+
+	err = watcher.Add(filePath)
+	if err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("error watching file %s: %w", filePath, err)
+	}
+	return watcher, nil
+}
+
+func (f *follower) keepWatching(ctx context.Context, watcher *fsnotify.Watcher) error {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				log.Println("Watcher events channel closed.")
+				return nil
+			}
+
+			if filepath.Clean(event.Name) != f.filePath {
+				continue
+			}
+
+			err := f.handleEvent(ctx, watcher, event)
+			if err != nil {
+				return fmt.Errorf("error reading file, %w", err)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				log.Println("Watcher errors channel closed.")
+				return nil
+			}
+			log.Printf("Watcher error: %+v", err)
+			continue
+		case <-ctx.Done():
+			log.Println("Reader received shutdown")
+			return nil
+		}
+	}
+}
+
+func (f *follower) handleEvent(ctx context.Context, watcher *fsnotify.Watcher,
+	event fsnotify.Event) error {
+
+	switch {
+	case event.Has(fsnotify.Create):
+		if !f.gone && f.isStillSameFile() {
+			return f.readNewLines(ctx)
+		}
+		// whatever has been written to the old file in the meantime
+		if err := f.readNewLines(ctx); err != nil {
+			return err
+		}
+		// on some platforms the watch on the file itself is gone by now
+		watcher.Add(f.filePath)
+		return f.reopen(ctx, "was rotated, continuing with new file")
+	case event.Has(fsnotify.Rename), event.Has(fsnotify.Remove):
+		// the file descriptor stays valid, so read whatever is left
+		f.gone = true
+		return f.readNewLines(ctx)
+	case event.Has(fsnotify.Write):
+		if f.gone {
+			return f.readNewLines(ctx)
+		}
+		truncated, err := f.wasTruncated()
+		if err != nil {
+			return err
+		}
+		if truncated {
+			f.stopDecompression()
+			if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			f.consumed = 0
+			f.sendMarker(ctx, "was truncated")
+		}
+		return f.readNewLines(ctx)
+	}
+
+	return nil
+}
+
+// Gives up once ctx is done, nobody is reading the lines anymore then.
+func (f *follower) readNewLines(ctx context.Context) error {
+	if f.file == nil {
+		return nil
+	}
+
+	var input io.Reader = f.file
+	if f.decompressor != nil {
+		data, err := f.readNewDecompressedData()
+		if err != nil || len(data) == 0 {
+			return err
+		}
+		input = bytes.NewReader(data)
+	}
+
+	newLines, lineNo, err := f.r.scanLines(input, f.filePath, f.lineNo)
+	f.lineNo = lineNo
+	if err != nil || len(newLines) == 0 {
+		return err
+	}
+
+	if f.timeParser != nil {
+		for _, line := range newLines {
+			f.timeParser.Stamp(line)
+		}
+	}
+
+	sendOrGiveUp(ctx, f.ch, newLines)
+
+	return nil
+}
+
+// Only complete lines are taken, the rest will be read next time.
+func (f *follower) readNewDecompressedData() ([]byte, error) {
+	if f.decompression == nil {
+		f.decompression = startDecompression(f.decompressor, f.file, f.consumed)
+	}
+
+	data := f.decompression.read()
+	f.consumed += int64(len(data))
+	return data, nil
+}
+
+func (f *follower) stopDecompression() {
+	if f.decompression == nil {
+		return
+	}
+	f.decompression.stop()
+	f.decompression = nil
+}
+
+func (f *follower) decompressedSize() (int64, error) {
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	decompressed, err := f.decompressor.NewReader(f.file)
+	if err != nil {
+		return 0, err
+	}
+	defer decompressed.Close()
+
+	return io.Copy(io.Discard, decompressed)
+}
+
+// A file got truncated if it's now smaller than what we've already read.
+func (f *follower) wasTruncated() (bool, error) {
+	if f.file == nil {
+		return false, nil
+	}
+
+	info, err := f.file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	var offset int64
+	if f.decompression != nil {
+		offset = f.decompression.offset.Load()
+	} else {
+		offset, err = f.file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return info.Size() < offset, nil
+}
+
+func (f *follower) isStillSameFile() bool {
+	if f.file == nil {
+		return false
+	}
+
+	pathInfo, err := os.Stat(f.filePath)
+	if err != nil {
+		return false
+	}
+
+	fileInfo, err := f.file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(pathInfo, fileInfo)
+}
+
+func (f *follower) reopen(ctx context.Context, reason string) error {
+	file, err := os.Open(f.filePath)
+	if err != nil {
+		// maybe it's gone again already, wait for the next create event
+		log.Printf("error reopening file %s: %+v", f.filePath, err)
+		return nil
+	}
+
+	decompressor, err := formats.DetectCompression(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.stopDecompression()
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.file = file
+	f.gone = false
+	f.decompressor = decompressor
+	f.consumed = 0
+
+	f.sendMarker(ctx, reason)
+
+	return f.readNewLines(ctx)
+}
+
+func (f *follower) sendMarker(ctx context.Context, reason string) {
+	text := fmt.Sprintf("--- %s %s ---", filepath.Base(f.filePath), reason)
+	marker := lines.NewMarkerLine(f.lineNo, text)
+	marker.Origin = f.filePath
+	if f.timeParser != nil {
+		f.timeParser.Stamp(marker)
+	}
+	f.lineNo++
+
+	sendOrGiveUp(ctx, f.ch, []*lines.Line{marker})
+}
@@ -0,0 +1,165 @@
+package reader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+// Starts following path from its end. The watcher gets set up before
+// returning, so no change gets missed.
+func startFollowing(t *testing.T, path string,
+	ch chan<- []*lines.Line) (context.CancelFunc, <-chan struct{}) {
+
+	t.Helper()
+
+	r := GetReader()
+	f, err := r.newFollowerAt(path, followFromEnd, ch, 0)
+	if err != nil {
+		t.Fatalf("newFollowerAt() error = %v", err)
+	}
+	watcher, err := r.initWatcher(f.filePath)
+	if err != nil {
+		t.Fatalf("initWatcher() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer watcher.Close()
+		defer func() {
+			if f.file != nil {
+				f.file.Close()
+			}
+		}()
+		if err := f.keepWatching(ctx, watcher); err != nil {
+			t.Errorf("keepWatching() error = %v", err)
+		}
+	}()
+	return cancel, done
+}
+
+// Waits for n lines at most a few seconds.
+func receiveLines(t *testing.T, ch <-chan []*lines.Line, n int) []string {
+	t.Helper()
+
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for len(got) < n {
+		select {
+		case newLines := <-ch:
+			for _, line := range newLines {
+				got = append(got, line.Str)
+			}
+		case <-timeout:
+			t.Fatalf("got %q, still waiting for %d more lines", got, n-len(got))
+		}
+	}
+	return got
+}
+
+func TestFollow(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, path string)
+		want   []string
+	}{
+		{
+			name: "append",
+			change: func(t *testing.T, path string) {
+				appendFile(t, path, "b\n")
+			},
+			want: []string{"b"},
+		},
+		{
+			name: "truncation",
+			change: func(t *testing.T, path string) {
+				writeTestFile(t, path, "c\n")
+			},
+			want: []string{"--- test.log was truncated ---", "c"},
+		},
+		{
+			name: "rotation",
+			change: func(t *testing.T, path string) {
+				if err := os.Rename(path, path+".1"); err != nil {
+					t.Fatal(err)
+				}
+				writeTestFile(t, path, "d\n")
+			},
+			want: []string{"--- test.log was rotated, continuing with new file ---", "d"},
+		},
+		{
+			name: "re-create",
+			change: func(t *testing.T, path string) {
+				if err := os.Remove(path); err != nil {
+					t.Fatal(err)
+				}
+				writeTestFile(t, path, "e\n")
+			},
+			want: []string{"--- test.log was rotated, continuing with new file ---", "e"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.log")
+			writeTestFile(t, path, "a line already there\n")
+
+			ch := make(chan []*lines.Line, 10)
+			cancel, done := startFollowing(t, path, ch)
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			tt.change(t, path)
+
+			if got := receiveLines(t, ch, len(tt.want)); !slices.Equal(got, tt.want) {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Nobody reads the new lines anymore, following must stop anyway.
+func TestFollowGivesUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	writeTestFile(t, path, "a\n")
+
+	cancel, done := startFollowing(t, path, make(chan []*lines.Line))
+	appendFile(t, path, "b\n")
+	// give the follower a chance to block on sending
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("follower still running after cancel")
+	}
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendFile(t *testing.T, path string, content string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
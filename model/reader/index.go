@@ -0,0 +1,170 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/busy"
+	"github.com/claude42/infiltrator/model/formats"
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+// Files at least this big don't get read into memory but get indexed instead.
+const IndexThreshold = 64 * 1024 * 1024
+
+const (
+	indexChunkSize = 4 * 1024 * 1024
+	indexBatchSize = 50_000
+)
+
+// ShouldIndex returns true if filePath is big enough to not read it into
+// memory as a whole. Compressed files can't be read on demand so they never
+// get indexed.
+func (r *Reader) ShouldIndex(filePath string) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.Size() < IndexThreshold {
+		return false
+	}
+
+	decompressor, err := formats.DetectCompression(file)
+	return err == nil && decompressor == nil
+}
+
+// IndexFile scans filePath for line endings and sends their offsets in
+// batches to indexCh. The lines themselves don't get kept in memory. In follow
+// mode new lines get sent to ch afterwards just like ReadFromFiles() does.
+func (r *Reader) IndexFile(ctx context.Context, wg *sync.WaitGroup,
+	quit chan<- string, filePath string, indexCh chan<- []int64,
+	ch chan<- []*lines.Line) {
+
+	defer wg.Done()
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		quit <- err.Error()
+		close(quit)
+		return
+	}
+	defer file.Close()
+
+	lineNo, offset, err := r.indexWholeFile(ctx, file, indexCh)
+	if err != nil {
+		quit <- fmt.Sprintf("error indexing %s: %v", filePath, err)
+		close(quit)
+		return
+	}
+
+	if !config.User().Follow {
+		return
+	}
+
+	followFile, err := os.Open(filePath)
+	if err != nil {
+		log.Printf("error opening file %s: %+v", filePath, err)
+		return
+	}
+
+	_, err = followFile.Seek(offset, io.SeekStart)
+	if err != nil {
+		log.Printf("error seeking in file %s: %+v", filePath, err)
+		followFile.Close()
+		return
+	}
+
+	// the last line might not be complete, it gets read like any line
+	// appended later on
+	f := r.newFollower(filePath, followFile, ch, lineNo)
+	if err := f.readNewLines(ctx); err != nil {
+		log.Printf("error reading file %s: %+v", filePath, err)
+	}
+	f.run(ctx)
+}
+
+// Returns the number of lines and the offset up to which the file has been
+// indexed. In follow mode a last line without a line ending doesn't get
+// indexed, it might still be growing.
+func (r *Reader) indexWholeFile(ctx context.Context, file *os.File,
+	indexCh chan<- []int64) (int, int64, error) {
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	size := int(info.Size())
+
+	buf := make([]byte, indexChunkSize)
+	batch := make([]int64, 0, indexBatchSize)
+	var offset, lastEnd int64
+	lineNo := 0
+
+	for {
+		n, err := file.Read(buf)
+
+		chunk := buf[:n]
+		pos := offset
+		for {
+			i := bytes.IndexByte(chunk, '\n')
+			if i < 0 {
+				break
+			}
+			pos += int64(i) + 1
+			lastEnd = pos
+			chunk = chunk[i+1:]
+
+			batch = append(batch, lastEnd)
+			lineNo++
+			busy.SpinWithFraction(int(lastEnd), size)
+		}
+		offset += int64(n)
+
+		if len(batch) >= indexBatchSize {
+			select {
+			case indexCh <- batch:
+			case <-ctx.Done():
+				return lineNo, offset, nil
+			}
+			batch = make([]int64, 0, indexBatchSize)
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return lineNo, offset, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return lineNo, offset, nil
+		default:
+		}
+	}
+
+	// last line without a line ending
+	if lastEnd < offset && !config.User().Follow {
+		batch = append(batch, offset)
+		lineNo++
+		lastEnd = offset
+	}
+
+	if len(batch) > 0 {
+		select {
+		case indexCh <- batch:
+		case <-ctx.Done():
+		}
+	}
+
+	return lineNo, lastEnd, nil
+}
@@ -0,0 +1,121 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+func TestIndexFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		follow  bool
+		want    []int64
+		// lines sent like new lines in follow mode
+		wantLines []string
+	}{
+		{
+			name:    "lf",
+			content: "a\nbc\n",
+			want:    []int64{2, 5},
+		},
+		{
+			name:    "crlf",
+			content: "a\r\nbc\r\n",
+			want:    []int64{3, 7},
+		},
+		{
+			name:    "last line without line ending",
+			content: "a\nbc",
+			want:    []int64{2, 4},
+		},
+		{
+			name:      "last line without line ending in follow mode",
+			content:   "a\nbc",
+			follow:    true,
+			want:      []int64{2},
+			wantLines: []string{"bc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			follow := config.User().Follow
+			defer func() { config.User().Follow = follow }()
+			config.User().Follow = tt.follow
+
+			path := filepath.Join(t.TempDir(), "indexed.log")
+			writeTestFile(t, path, tt.content)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			var wg sync.WaitGroup
+			wg.Add(1)
+			quit := make(chan string, 1)
+			indexCh := make(chan []int64, 10)
+			ch := make(chan []*lines.Line, 10)
+			go GetReader().IndexFile(ctx, &wg, quit, path, indexCh, ch)
+
+			// without follow mode IndexFile() returns by itself
+			var gotLines []string
+			if tt.follow {
+				gotLines = receiveLines(t, ch, len(tt.wantLines))
+				cancel()
+			}
+			wg.Wait()
+			cancel()
+
+			var got []int64
+			for len(indexCh) > 0 {
+				got = append(got, <-indexCh...)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ends = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(gotLines, tt.wantLines) {
+				t.Errorf("lines = %q, want %q", gotLines, tt.wantLines)
+			}
+		})
+	}
+}
+
+// Nobody takes the index anymore, indexing must stop anyway.
+func TestIndexFileGivesUp(t *testing.T) {
+	follow := config.User().Follow
+	defer func() { config.User().Follow = follow }()
+	config.User().Follow = false
+
+	// more than one batch
+	var sb strings.Builder
+	for i := range indexBatchSize + 1 {
+		fmt.Fprintf(&sb, "%d\n", i)
+	}
+	path := filepath.Join(t.TempDir(), "indexed.log")
+	writeTestFile(t, path, sb.String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go GetReader().IndexFile(ctx, &wg, make(chan string, 1), path,
+		make(chan []int64), make(chan []*lines.Line))
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("IndexFile() still running after cancel")
+	}
+}
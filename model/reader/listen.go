@@ -0,0 +1,332 @@
+package reader
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/claude42/infiltrator/model/busy"
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+// maximum size of a syslog message received via UDP
+const maxDatagramSize = 65535
+
+// Listen receives syslog messages (RFC 3164 or RFC 5424) on address, which is
+// either udp://host:port or tcp://host:port. Each message becomes a line
+// carrying the address of the sender as its origin.
+func (r *Reader) Listen(ctx context.Context, wg *sync.WaitGroup,
+	quit chan<- string, address string, ch chan<- []*lines.Line) {
+
+	defer wg.Done()
+
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		quit <- fmt.Sprintf("invalid listen address %s, use udp://host:port or tcp://host:port", address)
+		close(quit)
+		return
+	}
+
+	switch u.Scheme {
+	case "udp":
+		err = r.listenUDP(ctx, u.Host, ch)
+	case "tcp":
+		err = r.listenTCP(ctx, u.Host, ch)
+	default:
+		err = fmt.Errorf("unknown protocol %s, use udp or tcp", u.Scheme)
+	}
+
+	if err != nil {
+		quit <- err.Error()
+		close(quit)
+	}
+}
+
+func (r *Reader) listenUDP(ctx context.Context, host string,
+	ch chan<- []*lines.Line) error {
+
+	conn, err := net.ListenPacket("udp", host)
+	if err != nil {
+		return err
+	}
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("error receiving syslog message: %+v", err)
+			continue
+		}
+
+		// some senders put several messages into one datagram
+		for _, message := range strings.Split(string(buf[:n]), "\n") {
+			if !r.sendSyslogMessage(ctx, message, addr.String(), ch) {
+				return nil
+			}
+		}
+	}
+}
+
+func (r *Reader) listenTCP(ctx context.Context, host string,
+	ch chan<- []*lines.Line) error {
+
+	listener, err := net.Listen("tcp", host)
+	if err != nil {
+		return err
+	}
+	defer context.AfterFunc(ctx, func() { listener.Close() })()
+
+	var connWg sync.WaitGroup
+	defer connWg.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("error accepting connection: %+v", err)
+			continue
+		}
+		stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+		connWg.Add(1)
+		go func() {
+			defer connWg.Done()
+			defer stop()
+			defer conn.Close()
+			r.readSyslogStream(ctx, conn, ch)
+		}()
+	}
+}
+
+// Handles both octet counting ("42 <34>1 ...") and newline delimited framing
+// as described in RFC 6587. The framing gets decided by the first message, a
+// sender doesn't mix them.
+func (r *Reader) readSyslogStream(ctx context.Context, conn net.Conn,
+	ch chan<- []*lines.Line) {
+
+	sender := conn.RemoteAddr().String()
+	reader := bufio.NewReader(conn)
+
+	octetCounted, err := isOctetCounted(reader)
+	if err != nil {
+		return
+	}
+
+	for {
+		var message string
+		if octetCounted {
+			message, err = readOctetCounted(reader)
+		} else {
+			message, err = reader.ReadString('\n')
+		}
+
+		if message != "" && !r.sendSyslogMessage(ctx, message, sender, ch) {
+			return
+		}
+
+		if errors.Is(err, io.EOF) {
+			return
+		} else if err != nil {
+			log.Printf("error reading from %s: %+v", sender, err)
+			return
+		}
+	}
+}
+
+// Octet counted messages start with digits followed by a space and the "<" of
+// the priority, newline delimited ones might start with digits as well, e.g.
+// with a timestamp.
+func isOctetCounted(reader *bufio.Reader) (bool, error) {
+	// one more than the number of digits of maxDatagramSize
+	const maxDigits = 6
+
+	for n := 1; ; n++ {
+		peeked, err := reader.Peek(n)
+		if err != nil {
+			return false, err
+		}
+
+		c := peeked[n-1]
+		switch {
+		case c >= '0' && c <= '9' && n <= maxDigits:
+			continue
+		case c == ' ' && n > 1:
+			peeked, err = reader.Peek(n + 1)
+			if err != nil {
+				return false, err
+			}
+			return peeked[n] == '<', nil
+		default:
+			return false, nil
+		}
+	}
+}
+
+func readOctetCounted(reader *bufio.Reader) (string, error) {
+	lengthStr, err := reader.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+
+	length, err := strconv.Atoi(strings.TrimSpace(lengthStr))
+	if err != nil || length > maxDatagramSize {
+		return "", fmt.Errorf("invalid message length %q", lengthStr)
+	}
+
+	buf := make([]byte, length)
+	_, err = io.ReadFull(reader, buf)
+	return string(buf), err
+}
+
+func (r *Reader) sendSyslogMessage(ctx context.Context, message string,
+	sender string, ch chan<- []*lines.Line) bool {
+
+	message = strings.TrimRight(message, "\r\n\x00")
+	if message == "" {
+		return true
+	}
+
+	busy.Spin()
+	line := lines.NewLine(0, parseSyslogMessage(message, sender))
+	line.Origin = sender
+
+	return sendOrGiveUp(ctx, ch, []*lines.Line{line})
+}
+
+// parseSyslogMessage turns both RFC 3164 and RFC 5424 messages into the
+// traditional "timestamp host tag: message" form. The priority gets dropped.
+func parseSyslogMessage(message string, sender string) string {
+	rest, ok := stripPriority(message)
+	if !ok {
+		return message
+	}
+
+	if strings.HasPrefix(rest, "1 ") {
+		if parsed, ok := parseRFC5424(rest[2:]); ok {
+			return parsed
+		}
+	}
+
+	// RFC 3164 says a relay should add timestamp and host if they're missing
+	if len(rest) < len(time.Stamp) {
+		return addTimestampAndHost(rest, sender)
+	}
+	if _, err := time.Parse(time.Stamp, rest[:len(time.Stamp)]); err != nil {
+		return addTimestampAndHost(rest, sender)
+	}
+
+	return rest
+}
+
+func stripPriority(message string) (string, bool) {
+	if !strings.HasPrefix(message, "<") {
+		return message, false
+	}
+
+	end := strings.IndexByte(message, '>')
+	if end < 2 || end > 4 {
+		return message, false
+	}
+
+	if _, err := strconv.Atoi(message[1:end]); err != nil {
+		return message, false
+	}
+
+	return message[end+1:], true
+}
+
+func addTimestampAndHost(message string, sender string) string {
+	host, _, err := net.SplitHostPort(sender)
+	if err != nil {
+		host = sender
+	}
+
+	return time.Now().Format(time.Stamp) + " " + host + " " + message
+}
+
+// Expects everything after "<PRI>1 ", i.e.
+// TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func parseRFC5424(message string) (string, bool) {
+	header := strings.SplitN(message, " ", 6)
+	if len(header) < 6 {
+		return "", false
+	}
+	timestamp, hostname, appName, procID := header[0], header[1], header[2], header[3]
+
+	structuredData, msg, ok := splitStructuredData(header[5])
+	if !ok {
+		return "", false
+	}
+	msg = strings.TrimPrefix(msg, "\ufeff")
+
+	var sb strings.Builder
+	sb.WriteString(nilValue(timestamp))
+	sb.WriteString(" ")
+	sb.WriteString(nilValue(hostname))
+	sb.WriteString(" ")
+	sb.WriteString(nilValue(appName))
+	if procID != "-" {
+		sb.WriteString("[" + procID + "]")
+	}
+	sb.WriteString(":")
+	if structuredData != "-" {
+		sb.WriteString(" " + structuredData)
+	}
+	if msg != "" {
+		sb.WriteString(" " + msg)
+	}
+
+	return sb.String(), true
+}
+
+func nilValue(value string) string {
+	if value == "-" {
+		return ""
+	}
+	return value
+}
+
+// Structured data is either "-" or one or more [id param="value"] elements.
+// Values might contain escaped quotes and brackets.
+func splitStructuredData(str string) (string, string, bool) {
+	if strings.HasPrefix(str, "-") {
+		return "-", strings.TrimPrefix(str[1:], " "), true
+	}
+
+	inQuotes := false
+	depth := 0
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; {
+		case c == '\\' && inQuotes:
+			i++
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == '[' && !inQuotes:
+			depth++
+		case c == ']' && !inQuotes:
+			depth--
+			if depth == 0 && (i+1 == len(str) || str[i+1] != '[') {
+				return str[:i+1], strings.TrimPrefix(str[i+1:], " "), true
+			}
+		case depth == 0:
+			return "", "", false
+		}
+	}
+
+	return "", "", false
+}
@@ -0,0 +1,140 @@
+package reader
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseSyslogMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "rfc5424",
+			message: "<34>1 2024-10-11T22:14:15.003Z host su 123 ID47 - 'su root' failed",
+			want:    "2024-10-11T22:14:15.003Z host su[123]: 'su root' failed",
+		},
+		{
+			name:    "rfc5424 with structured data",
+			message: `<165>1 2024-10-11T22:14:15Z host app - ID47 [exampleSDID@32473 iut="3" eventSource="App\]"] started`,
+			want:    `2024-10-11T22:14:15Z host app: [exampleSDID@32473 iut="3" eventSource="App\]"] started`,
+		},
+		{
+			name:    "rfc5424 with bom and several elements",
+			message: "<165>1 2024-10-11T22:14:15Z host app - - [a x=\"1\"][b y=\"2\"] \ufeffstarted",
+			want:    `2024-10-11T22:14:15Z host app: [a x="1"][b y="2"] started`,
+		},
+		{
+			name:    "rfc5424 without message",
+			message: "<165>1 2024-10-11T22:14:15Z host app - - -",
+			want:    "2024-10-11T22:14:15Z host app:",
+		},
+		{
+			name:    "rfc3164",
+			message: "<34>Oct 11 22:14:15 host su: 'su root' failed",
+			want:    "Oct 11 22:14:15 host su: 'su root' failed",
+		},
+		{
+			name:    "no priority",
+			message: "just text",
+			want:    "just text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSyslogMessage(tt.message, "sender"); got != tt.want {
+				t.Errorf("parseSyslogMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsOctetCounted(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   bool
+	}{
+		{"octet counted", "11 <34>1 - - -", true},
+		{"newline delimited", "<34>Oct 11 22:14:15 host su: x\n", false},
+		{"starts with a timestamp", "2024-10-11 host app: x\n", false},
+		{"starts with a number", "42 apples\n", false},
+		{"too many digits", "1234567 <34>\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := isOctetCounted(bufio.NewReader(strings.NewReader(tt.stream)))
+			if err != nil {
+				t.Fatalf("isOctetCounted() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("isOctetCounted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadOctetCounted(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "several messages",
+			stream: "5 <1>ab7 <2>c de",
+			want:   []string{"<1>ab", "<2>c de"},
+		},
+		{
+			name:   "newline inside message",
+			stream: "6 <1>a\nb",
+			want:   []string{"<1>a\nb"},
+		},
+		{
+			name:    "invalid length",
+			stream:  "x <1>a",
+			wantErr: true,
+		},
+		{
+			name:    "too long",
+			stream:  "99999999 <1>a",
+			wantErr: true,
+		},
+		{
+			name:    "truncated",
+			stream:  "9 <1>a",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(tt.stream))
+			var got []string
+			var err error
+			for {
+				var message string
+				message, err = readOctetCounted(reader)
+				if err != nil {
+					break
+				}
+				got = append(got, message)
+			}
+			if tt.wantErr != !errors.Is(err, io.EOF) {
+				t.Fatalf("readOctetCounted() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(got, tt.want) {
+				t.Errorf("readOctetCounted() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
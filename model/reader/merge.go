@@ -0,0 +1,194 @@
+package reader
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/formats"
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+type mergeInput struct {
+	filePath   string
+	lines      []*lines.Line
+	offset     int64
+	timeParser *formats.TimeParser
+}
+
+// MergeFiles reads all files and merges their lines into one stream ordered
+// by the timestamps of the lines. The format of each file is detected
+// separately. Lines without a timestamp stick to the line before them. In
+// follow mode new lines of all files get appended in the order they arrive.
+func (r *Reader) MergeFiles(ctx context.Context, wg *sync.WaitGroup,
+	quit chan<- string, filePaths []string, ch chan<- []*lines.Line) {
+
+	defer wg.Done()
+
+	inputs := make([]*mergeInput, 0, len(filePaths))
+	for _, filePath := range filePaths {
+		input, err := r.readMergeInput(filePath)
+		if err != nil {
+			quit <- err.Error()
+			close(quit)
+			return
+		}
+		inputs = append(inputs, input)
+	}
+
+	merged := mergeByTime(inputs)
+	if len(merged) > 0 {
+		ch <- merged
+	}
+
+	if !config.User().Follow {
+		return
+	}
+
+	var followWg sync.WaitGroup
+	for _, input := range inputs {
+		// line numbers get assigned by the source, so each follower can
+		// start counting wherever it wants
+		f, err := r.newFollowerAt(input.filePath, input.offset, ch, len(merged))
+		if err != nil {
+			log.Printf("error opening file %s: %+v", input.filePath, err)
+			continue
+		}
+		f.timeParser = input.timeParser
+
+		followWg.Add(1)
+		go func() {
+			defer followWg.Done()
+			f.run(ctx)
+		}()
+	}
+	followWg.Wait()
+}
+
+func (r *Reader) readMergeInput(filePath string) (*mergeInput, error) {
+	file, ioReader, err := r.openFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	defer ioReader.Close()
+
+	counter := &countingReader{r: ioReader}
+	newLines, _, err := r.scanLines(counter, filePath, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fileFormat, regex := formats.Detect(newLines)
+	timeParser := formats.NewTimeParser(fileFormat, regex,
+		formats.ReferenceTime([]string{filePath}))
+	for _, line := range newLines {
+		timeParser.Stamp(line)
+	}
+
+	return &mergeInput{
+		filePath:   filePath,
+		lines:      newLines,
+		offset:     counter.count,
+		timeParser: timeParser,
+	}, nil
+}
+
+// Repeatedly takes the oldest of the first lines of all inputs. In case of a
+// tie the file given first on the command line wins. Lines before the first
+// timestamp of their file go along with that first timestamp, files without
+// any timestamps get appended unmerged.
+func mergeByTime(inputs []*mergeInput) []*lines.Line {
+	total := 0
+	for _, input := range inputs {
+		total += len(input.lines)
+	}
+
+	merged := make([]*lines.Line, 0, total)
+	next := make([]int, len(inputs))
+
+	var timed, untimed []int
+	firstTimes := make([]time.Time, len(inputs))
+	for i, input := range inputs {
+		firstTimes[i] = firstTime(input.lines)
+		if firstTimes[i].IsZero() {
+			untimed = append(untimed, i)
+		} else {
+			timed = append(timed, i)
+		}
+	}
+
+	when := func(i int) time.Time {
+		if when := inputs[i].lines[next[i]].When; !when.IsZero() {
+			return when
+		}
+		return firstTimes[i]
+	}
+
+	for {
+		oldest := -1
+		for _, i := range timed {
+			if next[i] >= len(inputs[i].lines) {
+				continue
+			}
+			if oldest == -1 || when(i).Before(when(oldest)) {
+				oldest = i
+			}
+		}
+		if oldest == -1 {
+			break
+		}
+
+		merged = appendMerged(merged, inputs[oldest].lines[next[oldest]])
+		next[oldest]++
+	}
+
+	for _, i := range untimed {
+		for _, line := range inputs[i].lines {
+			merged = appendMerged(merged, line)
+		}
+	}
+
+	return merged
+}
+
+func appendMerged(merged []*lines.Line, line *lines.Line) []*lines.Line {
+	line.No = len(merged)
+	return append(merged, line)
+}
+
+// zero time if there are no timestamps at all
+func firstTime(inputLines []*lines.Line) time.Time {
+	for _, line := range inputLines {
+		if !line.When.IsZero() {
+			return line.When
+		}
+	}
+	return time.Time{}
+}
+
+// ReopenAllForWatching is the counterpart of ReopenForWatching for merged
+// files: all of them get followed from their current end on.
+func (r *Reader) ReopenAllForWatching(ctx context.Context, wg *sync.WaitGroup,
+	filePaths []string, ch chan<- []*lines.Line) {
+
+	defer wg.Done()
+
+	var followWg sync.WaitGroup
+	for _, filePath := range filePaths {
+		f, err := r.newFollowerAt(filePath, followFromEnd, ch, 0)
+		if err != nil {
+			log.Printf("error opening file %s: %+v", filePath, err)
+			continue
+		}
+
+		followWg.Add(1)
+		go func() {
+			defer followWg.Done()
+			f.run(ctx)
+		}()
+	}
+	followWg.Wait()
+}
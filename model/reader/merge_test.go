@@ -0,0 +1,70 @@
+package reader
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+func mergeTestInput(stamps ...string) *mergeInput {
+	input := &mergeInput{}
+	for _, stamp := range stamps {
+		line := lines.NewLine(0, stamp)
+		if stamp[0] != '-' {
+			line.When, _ = time.Parse(time.TimeOnly, stamp)
+		}
+		input.lines = append(input.lines, line)
+	}
+	return input
+}
+
+func TestMergeByTime(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs [][]string
+		want   []string
+	}{
+		{
+			name:   "interleaved",
+			inputs: [][]string{{"10:00:00", "10:00:02"}, {"10:00:01", "10:00:03"}},
+			want:   []string{"10:00:00", "10:00:01", "10:00:02", "10:00:03"},
+		},
+		{
+			name:   "tie goes to the first file",
+			inputs: [][]string{{"10:00:01"}, {"10:00:01"}},
+			want:   []string{"10:00:01", "10:00:01"},
+		},
+		{
+			name:   "lines before the first timestamp stay with it",
+			inputs: [][]string{{"10:00:00", "10:00:05"}, {"-a", "-b", "10:00:03"}},
+			want:   []string{"10:00:00", "-a", "-b", "10:00:03", "10:00:05"},
+		},
+		{
+			name:   "file without timestamps gets appended",
+			inputs: [][]string{{"-a", "-b"}, {"10:00:00", "10:00:01"}},
+			want:   []string{"10:00:00", "10:00:01", "-a", "-b"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var inputs []*mergeInput
+			for _, stamps := range test.inputs {
+				inputs = append(inputs, mergeTestInput(stamps...))
+			}
+
+			var got []string
+			for i, line := range mergeByTime(inputs) {
+				if line.No != i {
+					t.Errorf("line %d has number %d", i, line.No)
+				}
+				got = append(got, line.Str)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
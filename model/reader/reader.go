@@ -1,8 +1,6 @@
 package reader
 
 import (
-	"bufio"
-	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
@@ -11,11 +9,9 @@ import (
 	"sync"
 
 	"github.com/claude42/infiltrator/config"
-	"github.com/claude42/infiltrator/fail"
 	"github.com/claude42/infiltrator/model/busy"
 	"github.com/claude42/infiltrator/model/formats"
 	"github.com/claude42/infiltrator/model/lines"
-	"github.com/fsnotify/fsnotify"
 )
 
 var (
@@ -33,44 +29,117 @@ func GetReader() *Reader {
 	return readerInstance
 }
 
-func (r *Reader) ReadFromFile(ctx context.Context, wg *sync.WaitGroup,
-	quit chan<- string, filePath string, ch chan<- []*lines.Line, follow bool) {
+// ReadFromFiles reads all files one after the other as if they were one
+// single file. Line numbers continue from one file to the next. In follow mode
+// only the last file gets watched for changes.
+func (r *Reader) ReadFromFiles(ctx context.Context, wg *sync.WaitGroup,
+	quit chan<- string, filePaths []string, ch chan<- []*lines.Line, follow bool) {
 
 	defer wg.Done()
 
-	file, err := os.Open(filePath)
+	var err error
+	var offset int64
+	lineNo := 0
+	for _, filePath := range filePaths {
+		lineNo, offset, err = r.readWholeFile(filePath, ch, lineNo)
+		if err != nil {
+			quit <- err.Error()
+			close(quit)
+			return
+		}
+	}
+
+	if !config.User().Follow || len(filePaths) == 0 {
+		return
+	}
+
+	// continue exactly where we stopped reading
+	lastFilePath := filePaths[len(filePaths)-1]
+	f, err := r.newFollowerAt(lastFilePath, offset, ch, lineNo)
 	if err != nil {
-		quit <- err.Error()
-		close(quit)
+		log.Printf("error opening file %s: %+v", lastFilePath, err)
 		return
 	}
+
+	f.run(ctx)
+}
+
+// Returns the new line number and the offset up to which the file has been
+// read. For compressed files the offset counts decompressed bytes.
+func (r *Reader) readWholeFile(filePath string, ch chan<- []*lines.Line,
+	lineNo int) (int, int64, error) {
+
+	file, ioReader, err := r.openFile(filePath)
+	if err != nil {
+		return lineNo, 0, err
+	}
 	defer file.Close()
+	defer ioReader.Close()
 
-	isGzip, _ := formats.IsGzip(file)
+	counter := &countingReader{r: ioReader}
+	lineNo, err = r.readNewLines(counter, filePath, ch, lineNo)
+	return lineNo, counter.count, err
+}
 
-	var ioReader io.Reader
-	if isGzip {
-		gzReader, err := gzip.NewReader(file)
-		fail.OnError(err, "Failed to create gzip reader")
-		defer gzReader.Close()
-		ioReader = gzReader
-	} else {
-		ioReader = file
+// openFile opens filePath and returns the file itself as well as a reader
+// that transparently decompresses the file if necessary. Reading reports
+// progress based on how much of the (compressed) file has been consumed.
+// Both have to be closed.
+func (r *Reader) openFile(filePath string) (*os.File, io.ReadCloser, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	lineNo, err := r.readNewLines(ioReader, ch, 0)
+	decompressor, err := formats.DetectCompression(file)
 	if err != nil {
-		quit <- err.Error()
-		close(quit)
-		return
+		file.Close()
+		return nil, nil, fmt.Errorf("error reading %s: %w", filePath, err)
 	}
 
-	if !config.User().Follow {
-		return
+	var size int64
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	progress := &progressReader{r: file, size: size}
+
+	if decompressor == nil {
+		return file, io.NopCloser(progress), nil
 	}
 
-	r.startWatching(ctx, filePath, file, ch, lineNo)
+	decompressed, err := decompressor.NewReader(progress)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("error decompressing %s: %w", filePath, err)
+	}
+
+	return file, decompressed, nil
+}
+
+// progressReader reports how much of a file of the given size has been read
+// so far.
+type progressReader struct {
+	r        io.Reader
+	size     int64
+	consumed int64
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	p.consumed += int64(n)
+	busy.SpinWithFraction(int(p.consumed), int(p.size))
+	return n, err
+}
+
+type countingReader struct {
+	r     io.Reader
+	count int64
+}
 
+func (c *countingReader) Read(buf []byte) (int, error) {
+	n, err := c.r.Read(buf)
+	c.count += int64(n)
+	return n, err
 }
 
 func (r *Reader) ReopenForWatching(ctx context.Context, wg *sync.WaitGroup,
@@ -80,41 +149,16 @@ func (r *Reader) ReopenForWatching(ctx context.Context, wg *sync.WaitGroup,
 
 	defer wg.Done()
 
-	file, err := os.Open(filePath)
+	f, err := r.newFollowerAt(filePath, followFromEnd, ch, lineNo)
 	if err != nil {
 		log.Printf("error opening file %s: %+v", filePath, err)
 		return
 	}
-	defer file.Close()
 
-	_, err = file.Seek(0, io.SeekEnd)
-	if err != nil {
-		log.Printf("error seeking in file %s: %+v", filePath, err)
-		return
-	}
-
-	r.startWatching(ctx, filePath, file, ch, lineNo)
+	f.run(ctx)
 	log.Println("ReopenForWatching ended")
 }
 
-func (r *Reader) startWatching(ctx context.Context, filePath string,
-	file *os.File, ch chan<- []*lines.Line, lineNo int) {
-
-	log.Println("Start watching")
-	watcher, err := r.initWatcher(filePath)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	defer watcher.Close()
-
-	err = r.keepWatching(ctx, watcher, file, ch, lineNo)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-}
-
 func (r *Reader) ReadFromStdin(ch chan<- []*lines.Line, quit chan<- string) {
 
 	yes, err := r.canUseStdin()
@@ -129,16 +173,12 @@ func (r *Reader) ReadFromStdin(ch chan<- []*lines.Line, quit chan<- string) {
 	}
 
 	lineNo := 0
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		text := scanner.Text()
+	scanBatched(context.Background(), newLineScanner(os.Stdin), func(text string) *lines.Line {
 		busy.Spin()
-		ch <- []*lines.Line{lines.NewLine(lineNo, text)}
+		line := lines.NewLine(lineNo, text)
 		lineNo++
-	}
-	if err := scanner.Err(); err != nil {
-		log.Printf("error reading file: %+v", err)
-	}
+		return line
+	}, ch)
 }
 
 func (r *Reader) canUseStdin() (bool, error) {
@@ -154,68 +194,42 @@ func (r *Reader) canUseStdin() (bool, error) {
 	return true, nil
 }
 
-func (r *Reader) keepWatching(ctx context.Context, watcher *fsnotify.Watcher,
-	file *os.File, ch chan<- []*lines.Line, lineNo int) error {
-	var err error
-	for {
-		select {
-		case event, ok := <-watcher.Events:
-			if !ok {
-				log.Println("Watcher events channel closed.")
-				return nil
-			}
-
-			if event.Has(fsnotify.Write) {
-				lineNo, err = r.readNewLines(file, ch, lineNo)
-				if err != nil {
-					return fmt.Errorf("error reading file, %w", err)
-				}
-
-				continue
-			}
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				log.Println("Watcher errors channel closed.")
-				return nil
-			}
-			log.Printf("Watcher error: %+v", err)
-			continue
-		case <-ctx.Done():
-			log.Println("Reader received shutdown")
-			return nil
-		}
-	}
-}
+func (r *Reader) readNewLines(file io.Reader, origin string,
+	ch chan<- []*lines.Line, lineNo int) (int, error) {
 
-func (r *Reader) initWatcher(filePath string) (*fsnotify.Watcher, error) {
-	watcher, err := fsnotify.NewWatcher()
+	newLines, lineNo, err := r.scanLines(file, origin, lineNo)
 	if err != nil {
-		return nil, fmt.Errorf("error creating watcher: %w", err)
+		return lineNo, err
 	}
 
-	err = watcher.Add(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("error watching file %s: %w", filePath, err)
+	// nothing new, e.g. an empty file or a just truncated one
+	if len(newLines) == 0 {
+		return lineNo, nil
 	}
-	return watcher, nil
+
+	ch <- newLines
+
+	return lineNo, nil
 }
 
-func (r *Reader) readNewLines(file io.Reader, ch chan<- []*lines.Line, lineNo int) (int, error) {
+func (r *Reader) scanLines(file io.Reader, origin string,
+	lineNo int) ([]*lines.Line, int, error) {
+
 	var newLines []*lines.Line
 
-	scanner := bufio.NewScanner(file)
+	scanner := newLineScanner(file)
 	for scanner.Scan() {
 		text := scanner.Text()
 		busy.Spin()
-		newLines = append(newLines, lines.NewLine(lineNo, text))
+		line := lines.NewLine(lineNo, text)
+		line.Origin = origin
+		newLines = append(newLines, line)
 		lineNo++
 	}
 
 	if err := scanner.Err(); err != nil {
-		return lineNo, fmt.Errorf("error reading file: %w", err)
+		return newLines, lineNo, fmt.Errorf("error reading file: %w", err)
 	}
 
-	ch <- newLines
-
-	return lineNo, nil
+	return newLines, lineNo, nil
 }
@@ -0,0 +1,127 @@
+package reader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Matches what logrotate appends to the name of a log file: either a number
+// (syslog.1) or a date (syslog-20250712), optionally followed by the
+// extension of a compression format (syslog.2.gz).
+var rotationSuffixRegex = regexp.MustCompile(`^(?:\.(\d+)|-(\d{8,10}))(\.[[:alnum:]]+)?$`)
+
+type rotatedFile struct {
+	path string
+	// files rotated by date are always considered older than the ones rotated
+	// by number, these are always older than the current file
+	group int
+	age   int
+}
+
+// RotationFamily checks whether filePaths belong to the same rotated log
+// family and returns them ordered from oldest to newest. If discover is true
+// all rotated versions of filePaths that can be found next to them are added
+// as well.
+func RotationFamily(filePaths []string, discover bool) ([]string, error) {
+	candidates := slices.Clone(filePaths)
+
+	if discover {
+		for _, filePath := range filePaths {
+			for _, pattern := range []string{filePath + ".*", filePath + "-*"} {
+				matches, err := filepath.Glob(pattern)
+				if err != nil {
+					return nil, fmt.Errorf("error looking for rotated files: %w", err)
+				}
+				candidates = append(candidates, matches...)
+			}
+		}
+	}
+
+	candidates = slices.DeleteFunc(candidates, func(path string) bool {
+		info, err := os.Stat(path)
+		return err == nil && info.IsDir()
+	})
+	slices.Sort(candidates)
+	candidates = slices.Compact(candidates)
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no files found")
+	}
+
+	base := rotationBase(candidates)
+
+	family := make([]rotatedFile, 0, len(candidates))
+	for _, path := range candidates {
+		file, err := parseRotatedFile(base, path)
+		if err != nil {
+			// only complain about files the user explicitly asked for,
+			// silently skip anything else the glob patterns found
+			if slices.Contains(filePaths, path) {
+				return nil, err
+			}
+			continue
+		}
+		family = append(family, file)
+	}
+
+	slices.SortStableFunc(family, func(a, b rotatedFile) int {
+		if a.group != b.group {
+			return a.group - b.group
+		}
+		return b.age - a.age
+	})
+
+	result := make([]string, len(family))
+	for i, file := range family {
+		result[i] = file.path
+	}
+
+	return result, nil
+}
+
+// The base of the family is the file all other files have been rotated from.
+// If the current file itself is not part of candidates, derive the name from
+// one of the rotated ones.
+func rotationBase(candidates []string) string {
+	shortest := slices.MinFunc(candidates, func(a, b string) int {
+		return len(a) - len(b)
+	})
+
+	for i, r := range shortest {
+		if (r == '.' || r == '-') && rotationSuffixRegex.MatchString(shortest[i:]) {
+			return shortest[:i]
+		}
+	}
+
+	return shortest
+}
+
+func parseRotatedFile(base string, path string) (rotatedFile, error) {
+	if path == base {
+		return rotatedFile{path: path, group: 2}, nil
+	}
+
+	suffix, found := strings.CutPrefix(path, base)
+	if !found {
+		return rotatedFile{}, fmt.Errorf("%s is not a rotated version of %s", path, base)
+	}
+
+	matches := rotationSuffixRegex.FindStringSubmatch(suffix)
+	if matches == nil {
+		return rotatedFile{}, fmt.Errorf("%s is not a rotated version of %s", path, base)
+	}
+
+	if matches[1] != "" {
+		number, _ := strconv.Atoi(matches[1])
+		return rotatedFile{path: path, group: 1, age: number}, nil
+	}
+
+	// the newer the date the younger the file
+	date, _ := strconv.Atoi(matches[2])
+	return rotatedFile{path: path, group: 0, age: -date}, nil
+}
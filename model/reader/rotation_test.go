@@ -0,0 +1,96 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRotationFamily(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		args     []string
+		discover bool
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:  "numbers sort numerically",
+			files: []string{"syslog", "syslog.1", "syslog.2.gz", "syslog.10"},
+			args:  []string{"syslog.1", "syslog", "syslog.10", "syslog.2.gz"},
+			want:  []string{"syslog.10", "syslog.2.gz", "syslog.1", "syslog"},
+		},
+		{
+			name:  "dates before numbers",
+			files: []string{"syslog", "syslog.1", "syslog-20250712", "syslog-20250101.gz"},
+			args:  []string{"syslog", "syslog.1", "syslog-20250712", "syslog-20250101.gz"},
+			want:  []string{"syslog-20250101.gz", "syslog-20250712", "syslog.1", "syslog"},
+		},
+		{
+			name:     "discover rotated files",
+			files:    []string{"syslog", "syslog.1", "syslog.2.gz", "syslog-20250712", "syslog.bak", "syslog.d/x", "auth.log"},
+			args:     []string{"syslog"},
+			discover: true,
+			want:     []string{"syslog-20250712", "syslog.2.gz", "syslog.1", "syslog"},
+		},
+		{
+			name:  "only rotated files",
+			files: []string{"syslog.1", "syslog.2"},
+			args:  []string{"syslog.1", "syslog.2"},
+			want:  []string{"syslog.2", "syslog.1"},
+		},
+		{
+			name:    "different files",
+			files:   []string{"syslog", "auth.log"},
+			args:    []string{"syslog", "auth.log"},
+			wantErr: true,
+		},
+		{
+			name:    "not rotated by logrotate",
+			files:   []string{"syslog", "syslog.bak"},
+			args:    []string{"syslog", "syslog.bak"},
+			wantErr: true,
+		},
+		{
+			name:    "no files",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, file := range tt.files {
+				path := filepath.Join(dir, file)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			args := make([]string, len(tt.args))
+			for i, arg := range tt.args {
+				args[i] = filepath.Join(dir, arg)
+			}
+
+			got, err := RotationFamily(args, tt.discover)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RotationFamily() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			for i := range got {
+				got[i], _ = filepath.Rel(dir, got[i])
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("RotationFamily() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
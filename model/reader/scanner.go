@@ -0,0 +1,124 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"math"
+	"slices"
+
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+const initialScanBufferSize = 64 * 1024
+
+// newLineScanner returns a scanner that, unlike the default bufio.Scanner,
+// doesn't give up on lines longer than 64 KiB. If a maximum line length is
+// configured, longer lines get truncated and end with lines.TruncatedMarker.
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+
+	maxLineLength := config.User().MaxLineLength
+	if maxLineLength <= 0 {
+		scanner.Buffer(make([]byte, initialScanBufferSize), math.MaxInt)
+		return scanner
+	}
+
+	// the buffer must be able to hold a line up to the maximum plus its
+	// line ending
+	scanner.Buffer(make([]byte, min(initialScanBufferSize, maxLineLength+2)),
+		maxLineLength+2)
+	scanner.Split(scanLinesTruncated(maxLineLength))
+	return scanner
+}
+
+// Works like bufio.ScanLines but truncates lines longer than maxLineLength.
+// The rest of such a line gets skipped.
+func scanLinesTruncated(maxLineLength int) bufio.SplitFunc {
+	skipping := false
+
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		i := bytes.IndexByte(data, '\n')
+
+		if skipping {
+			if i < 0 {
+				// throw away everything, the line goes on
+				return len(data), nil, nil
+			}
+			skipping = false
+			return i + 1, nil, nil
+		}
+
+		if i >= 0 && (i <= maxLineLength || (i == maxLineLength+1 && data[i-1] == '\r')) {
+			return bufio.ScanLines(data, atEOF)
+		}
+
+		if i < 0 && (len(data) <= maxLineLength ||
+			(len(data) == maxLineLength+1 && data[maxLineLength] == '\r')) {
+			// either the end of the file or we need more data
+			return bufio.ScanLines(data, atEOF)
+		}
+
+		// line is too long
+		token := slices.Concat(data[:maxLineLength], []byte(lines.TruncatedMarker))
+		if i >= 0 {
+			return i + 1, token, nil
+		}
+		skipping = true
+		return len(data), token, nil
+	}
+}
+
+// lines available at once get sent as one batch, but not more than this
+const maxBatchLines = 10_000
+
+// scanBatched sends the lines of scanner to ch. All lines available right
+// away go into one batch, otherwise a fast writer would cause one update per
+// line. Returns false if ctx got done in the meantime.
+func scanBatched(ctx context.Context, scanner *bufio.Scanner,
+	newLine func(text string) *lines.Line, ch chan<- []*lines.Line) bool {
+
+	texts := make(chan string, maxBatchLines)
+	go func() {
+		defer close(texts)
+		for scanner.Scan() {
+			select {
+			case texts <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			log.Printf("error reading lines: %+v", err)
+		}
+	}()
+
+	for text := range texts {
+		batch := []*lines.Line{newLine(text)}
+	drain:
+		for len(batch) < maxBatchLines {
+			select {
+			case text, ok := <-texts:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, newLine(text))
+			default:
+				break drain
+			}
+		}
+
+		if !sendOrGiveUp(ctx, ch, batch) {
+			return false
+		}
+	}
+
+	return ctx.Err() == nil
+}
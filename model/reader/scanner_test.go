@@ -0,0 +1,51 @@
+package reader
+
+import (
+	"bufio"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/claude42/infiltrator/model/lines"
+)
+
+func TestScanLinesTruncated(t *testing.T) {
+	const maxLineLength = 5
+	truncated := "12345" + lines.TruncatedMarker
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"short lines", "a\nbc\n", []string{"a", "bc"}},
+		{"exactly max", "12345\n", []string{"12345"}},
+		{"exactly max with crlf", "12345\r\nab\r\n", []string{"12345", "ab"}},
+		{"one too long", "123456\nab\n", []string{truncated, "ab"}},
+		{"one too long with crlf", "123456\r\nab\n", []string{truncated, "ab"}},
+		{"much too long", "1234567890123\nab\n", []string{truncated, "ab"}},
+		{"last line without newline", "ab\n12345", []string{"ab", "12345"}},
+		{"last line too long", "ab\n123456", []string{"ab", truncated}},
+		{"last line ending with cr", "12345\r", []string{"12345"}},
+		{"empty lines", "\n\nab\n", []string{"", "", "ab"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := bufio.NewScanner(strings.NewReader(tt.input))
+			scanner.Buffer(make([]byte, maxLineLength+2), maxLineLength+2)
+			scanner.Split(scanLinesTruncated(maxLineLength))
+
+			var got []string
+			for scanner.Scan() {
+				got = append(got, scanner.Text())
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatalf("scanner error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("scanLinesTruncated() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
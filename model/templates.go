@@ -0,0 +1,51 @@
+package model
+
+import (
+	"github.com/claude42/infiltrator/model/busy"
+	"github.com/claude42/infiltrator/model/templates"
+	"github.com/claude42/infiltrator/util"
+)
+
+// Runs in its own Go routine as it has to look at each and every line.
+func (fm *FilterManager) mineTemplates() {
+	source := fm.filters.Source()
+	length := source.Length()
+
+	index := templates.NewIndex()
+	for lineNo := range length {
+		if lineNo%10_000 == 0 {
+			select {
+			case <-fm.ctx.Done():
+				return
+			default:
+			}
+		}
+
+		busy.SpinWithFraction(lineNo, length)
+		text, err := source.Text(lineNo)
+		if err != nil {
+			break
+		}
+		index.Add(lineNo, text)
+	}
+
+	// the filter manager's Go routine takes it from here
+	select {
+	case fm.commandChannel <- CommandTemplatesMined{index}:
+	case <-fm.ctx.Done():
+	}
+}
+
+// Generated by FilterManager.MineTemplates()
+
+type EventTemplates struct {
+	util.EventImpl
+
+	Index *templates.Index
+}
+
+func NewEventTemplates(index *templates.Index) *EventTemplates {
+	ev := &EventTemplates{Index: index}
+	ev.EventImpl.SetEventNow()
+	return ev
+}
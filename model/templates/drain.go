@@ -0,0 +1,202 @@
+package templates
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Clusters lines into templates like Drain does (He et al., "Drain: An
+// Online Log Parsing Approach with Fixed Depth Tree"). Lines with the same
+// number of tokens and the same first few tokens end up in the same leaf of
+// the tree. Within a leaf a line joins the most similar template or starts a
+// new one. Tokens differing between the lines of a template become
+// wildcards.
+
+const Wildcard = "<*>"
+
+const (
+	// the first treeDepth-2 tokens of a line decide on the leaf
+	treeDepth = 4
+	// share of tokens which have to be equal to join a template
+	similarity = 0.4
+	// further tokens end up in the wildcard child
+	maxChildren = 100
+)
+
+type Template struct {
+	ID     int
+	Tokens []string
+	Count  int
+	// first line belonging to the template
+	First int
+}
+
+func (t *Template) String() string {
+	return strings.Join(t.Tokens, " ")
+}
+
+type node struct {
+	children  map[string]*node
+	templates []*Template
+}
+
+func newNode() *node {
+	return &node{children: make(map[string]*node)}
+}
+
+type Miner struct {
+	root      *node
+	templates []*Template
+}
+
+func NewMiner() *Miner {
+	return &Miner{root: newNode()}
+}
+
+// Add puts the line into a template and returns the template.
+func (m *Miner) Add(lineNo int, str string) *Template {
+	tokens := Tokenize(str)
+	leaf := m.leaf(tokens, true)
+
+	template, sim := best(leaf.templates, tokens)
+	if template != nil && sim >= similarity {
+		template.merge(tokens)
+		template.Count++
+		return template
+	}
+
+	template = &Template{
+		ID:     len(m.templates),
+		Tokens: tokens,
+		Count:  1,
+		First:  lineNo,
+	}
+	leaf.templates = append(leaf.templates, template)
+	m.templates = append(m.templates, template)
+	return template
+}
+
+// Match returns the template the line fits into without changing any of the
+// templates, nil if there's none.
+func (m *Miner) Match(str string) *Template {
+	tokens := Tokenize(str)
+	leaf := m.leaf(tokens, false)
+	if leaf == nil {
+		return nil
+	}
+
+	var match *Template
+	matchWildcards := len(tokens) + 1
+	for _, template := range leaf.templates {
+		if wildcards, ok := template.fits(tokens); ok && wildcards < matchWildcards {
+			match, matchWildcards = template, wildcards
+		}
+	}
+	return match
+}
+
+func (m *Miner) Templates() []*Template {
+	return m.templates
+}
+
+// Tokenize splits str at white space and replaces all tokens containing
+// digits by wildcards. In key=value tokens only the value gets replaced.
+func Tokenize(str string) []string {
+	tokens := strings.Fields(str)
+	for i, token := range tokens {
+		if !strings.ContainsFunc(token, unicode.IsDigit) {
+			continue
+		}
+		if sep := strings.IndexAny(token, "=:"); sep > 0 &&
+			!strings.ContainsFunc(token[:sep], unicode.IsDigit) {
+
+			tokens[i] = token[:sep+1] + Wildcard
+		} else {
+			tokens[i] = Wildcard
+		}
+	}
+	return tokens
+}
+
+// Returns nil if create is false and there's no such leaf.
+func (m *Miner) leaf(tokens []string, create bool) *node {
+	current := m.child(m.root, strconv.Itoa(len(tokens)), create)
+
+	for i := 0; current != nil && i < min(len(tokens), treeDepth-2); i++ {
+		key := tokens[i]
+		if _, ok := current.children[key]; !ok && len(current.children) >= maxChildren {
+			key = Wildcard
+		}
+		next := m.child(current, key, create)
+		if next == nil && key != Wildcard {
+			next = m.child(current, Wildcard, false)
+		}
+		current = next
+	}
+
+	return current
+}
+
+func (m *Miner) child(parent *node, key string, create bool) *node {
+	if parent == nil {
+		return nil
+	}
+
+	child, ok := parent.children[key]
+	if !ok && create {
+		child = newNode()
+		parent.children[key] = child
+	}
+	return child
+}
+
+// Returns the most similar template, more specific templates win if equally
+// similar.
+func best(templates []*Template, tokens []string) (*Template, float64) {
+	var bestTemplate *Template
+	bestSim, bestWildcards := -1.0, -1
+
+	for _, template := range templates {
+		equal, wildcards := 0, 0
+		for i, token := range template.Tokens {
+			if token == Wildcard {
+				wildcards++
+			} else if token == tokens[i] {
+				equal++
+			}
+		}
+
+		sim := 1.0
+		if len(tokens) > 0 {
+			sim = float64(equal) / float64(len(tokens))
+		}
+		if sim > bestSim || (sim == bestSim && wildcards < bestWildcards) {
+			bestTemplate, bestSim, bestWildcards = template, sim, wildcards
+		}
+	}
+
+	return bestTemplate, bestSim
+}
+
+func (t *Template) merge(tokens []string) {
+	for i, token := range tokens {
+		if t.Tokens[i] != token {
+			t.Tokens[i] = Wildcard
+		}
+	}
+}
+
+// Returns the number of wildcards if all other tokens are equal.
+func (t *Template) fits(tokens []string) (int, bool) {
+	wildcards := 0
+	for i, token := range t.Tokens {
+		switch {
+		case token == Wildcard:
+			wildcards++
+		case token != tokens[i]:
+			return 0, false
+		}
+	}
+	return wildcards, true
+}
@@ -0,0 +1,125 @@
+package templates
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		str  string
+		want []string
+	}{
+		{"user alice logged in", []string{"user", "alice", "logged", "in"}},
+		{"took 12ms", []string{"took", "<*>"}},
+		{"status=500 path=/x", []string{"status=<*>", "path=/x"}},
+		{"host:web-01 up", []string{"host:<*>", "up"}},
+		{"ip=10.0.0.1", []string{"ip=<*>"}},
+		{"ipv4=10.0.0.1", []string{"<*>"}},
+		{"a1=x", []string{"<*>"}},
+		{"  spaced \t out  ", []string{"spaced", "out"}},
+		{"", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.str, func(t *testing.T) {
+			if got := Tokenize(tt.str); !slices.Equal(got, tt.want) {
+				t.Errorf("Tokenize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiner(t *testing.T) {
+	type template struct {
+		text  string
+		count int
+		first int
+	}
+
+	tests := []struct {
+		name  string
+		lines []string
+		want  []template
+	}{
+		{
+			name: "differing tokens become wildcards",
+			lines: []string{
+				"user login alice ok",
+				"user login bob ok",
+				"user login carol failed",
+			},
+			want: []template{{"user login <*> <*>", 3, 0}},
+		},
+		{
+			name: "different length, different template",
+			lines: []string{
+				"connection from alpha closed",
+				"connection from alpha closed by peer",
+				"connection from beta closed",
+			},
+			want: []template{
+				{"connection from <*> closed", 2, 0},
+				{"connection from alpha closed by peer", 1, 1},
+			},
+		},
+		{
+			name: "different first tokens, different template",
+			lines: []string{
+				"GET /index ok",
+				"POST /index ok",
+				"GET /index failed",
+			},
+			want: []template{
+				{"GET /index <*>", 2, 0},
+				{"POST /index ok", 1, 1},
+			},
+		},
+		{
+			name: "too different to join",
+			lines: []string{
+				"a b c d e f",
+				"a b x y z w",
+			},
+			want: []template{
+				{"a b c d e f", 1, 0},
+				{"a b x y z w", 1, 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMiner()
+			for i, line := range tt.lines {
+				m.Add(i, line)
+			}
+
+			var got []template
+			for _, tmpl := range m.Templates() {
+				got = append(got, template{tmpl.String(), tmpl.Count, tmpl.First})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("templates = %v, want %v", got, tt.want)
+			}
+
+			for _, line := range tt.lines {
+				if m.Match(line) == nil {
+					t.Errorf("Match(%q) = nil, want a template", line)
+				}
+			}
+		})
+	}
+}
+
+func TestMinerMatchDoesNotChangeTemplates(t *testing.T) {
+	m := NewMiner()
+	m.Add(0, "user login alice ok")
+
+	if got := m.Match("user login bob ok"); got != nil {
+		t.Errorf("Match() = %q, want nil", got)
+	}
+	if got := m.Templates()[0].String(); got != "user login alice ok" {
+		t.Errorf("template = %q, changed by Match()", got)
+	}
+}
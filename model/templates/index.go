@@ -0,0 +1,74 @@
+package templates
+
+import (
+	"github.com/claude42/infiltrator/model/formats"
+)
+
+// the message is all that matters, timestamps, hosts etc. would only get in
+// the way
+var messageFields = []string{"message", "msg"}
+
+// Index knows the template of each line which was there when the templates
+// got mined. Lines added afterwards get matched against the templates.
+type Index struct {
+	miner *Miner
+	// template ID of each line
+	ids []int32
+	// only the message gets clustered if there is such a field
+	field string
+}
+
+func NewIndex() *Index {
+	ix := &Index{miner: NewMiner()}
+	for _, candidate := range messageFields {
+		if field := formats.FieldName(candidate); field != "" {
+			ix.field = field
+			break
+		}
+	}
+	return ix
+}
+
+// Add has to be called for each line in order.
+func (ix *Index) Add(lineNo int, str string) {
+	template := ix.miner.Add(lineNo, ix.message(str))
+	ix.ids = append(ix.ids, int32(template.ID))
+}
+
+// Template returns the template of the line, nil if a line added later
+// doesn't fit into any template.
+func (ix *Index) Template(lineNo int, str string) *Template {
+	if lineNo >= 0 && lineNo < len(ix.ids) {
+		return ix.miner.templates[ix.ids[lineNo]]
+	}
+	return ix.miner.Match(ix.message(str))
+}
+
+func (ix *Index) Templates() []*Template {
+	return ix.miner.Templates()
+}
+
+// Lines returns the number of lines which got mined.
+func (ix *Index) Lines() int {
+	return len(ix.ids)
+}
+
+// IsRare returns true if the template is an anomaly, i.e. only very few lines
+// belong to it.
+func (ix *Index) IsRare(template *Template) bool {
+	return template.Count <= max(1, len(ix.ids)/rareShare)
+}
+
+// at most one in this many lines belongs to a rare template
+const rareShare = 1000
+
+func (ix *Index) message(str string) string {
+	if ix.field == "" {
+		return str
+	}
+	start, end, ok := formats.FieldSpan(str, ix.field)
+	if !ok {
+		return str
+	}
+	return str[start:end]
+}
@@ -0,0 +1,43 @@
+package ui
+
+import (
+	"github.com/claude42/infiltrator/components"
+	"github.com/gdamore/tcell/v2"
+)
+
+type ColoredMultiSelect struct {
+	components.MultiSelect
+
+	colorIndex uint8
+}
+
+func NewColoredMultiSelect(placeholder string, key tcell.Key, do func([]bool)) *ColoredMultiSelect {
+	m := &ColoredMultiSelect{
+		MultiSelect: *components.NewMultiSelect(placeholder, key, do),
+	}
+
+	m.StyleUsing(m)
+
+	return m
+}
+
+func (m *ColoredMultiSelect) SetColorIndex(colorIndex uint8) {
+	m.colorIndex = colorIndex
+}
+
+func (m *ColoredMultiSelect) Style() tcell.Style {
+	var style tcell.Style
+	if m.OldStyler != nil {
+		style = m.OldStyler.Style()
+	} else {
+		style = tcell.StyleDefault.Reverse(true)
+	}
+
+	if m.IsActive() {
+		style = style.Foreground((FilterColors[m.colorIndex][0]))
+	} else {
+		style = style.Foreground((FilterColors[m.colorIndex][1]))
+	}
+
+	return style
+}
@@ -0,0 +1,150 @@
+package ui
+
+import (
+	"slices"
+	"time"
+
+	"github.com/claude42/infiltrator/components"
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/fail"
+	"github.com/claude42/infiltrator/model"
+	"github.com/claude42/infiltrator/model/filter"
+	"github.com/gdamore/tcell/v2"
+)
+
+// every change starts the command again, so wait until typing paused for a
+// bit longer than usual
+const commandInputDelay = time.Second
+
+// CommandPanel pipes lines through an external command like jq or awk, which
+// either decides whether to keep each line or transforms it.
+type CommandPanel struct {
+	*FilterPanelImpl
+
+	input      *FilterInput
+	typeSelect *ColoredDropdown
+	output     *ColoredDropdown
+}
+
+func NewCommandPanel(panelType config.FilterType, name string) *CommandPanel {
+	c := &CommandPanel{
+		FilterPanelImpl: NewFilterPanelImpl(panelType, name),
+		input:           NewFilterInput(filter.CommandFilterCommand),
+	}
+	c.input.SetUpdateDelay(commandInputDelay)
+	c.typeSelect = NewColoredDropdown(config.Filters.AllStrings(), tcell.KeyCtrlH, c.changePanelType)
+	c.typeSelect.SetSelectedIndex(int(panelType))
+	c.output = NewColoredDropdown(filter.CommandOutputs, tcell.KeyCtrlJ, c.changeOutput)
+	c.Add(c.typeSelect)
+	c.Add(c.output)
+	c.Add(c.input)
+
+	return c
+}
+
+func (c *CommandPanel) SetPanelConfig(panelConfig *config.PanelTable) {
+	if panelConfig == nil {
+		return
+	}
+
+	c.SetOutput(panelConfig.Mode)
+	// don't run whatever the panel searched for before its type got changed
+	if panelConfig.Type == config.FilterStringCommand {
+		c.SetContent(panelConfig.Key)
+	}
+
+	// don't put this into FilterPanelImpl!
+	c.SetColorIndex(panelConfig.ColorIndex)
+}
+
+func (c *CommandPanel) Resize(x, y, width, height int) {
+	c.FilterPanelImpl.Resize(x, y, width, height)
+
+	c.typeSelect.Resize(x+1, y, config.PanelNameWidth, 1)
+	c.output.Resize(x+config.PanelNameWidth, y, 1, 1)
+
+	inputX := x + config.PanelHeaderWidth + config.PanelHeaderGap
+	c.input.Resize(inputX, y, width-inputX, 1)
+}
+
+func (c *CommandPanel) Render(updateScreen bool) {
+	if !c.IsVisible() {
+		return
+	}
+
+	c.FilterPanelImpl.Render(false)
+
+	style := c.CurrentStyler.Style()
+
+	_, y := c.Position()
+	components.RenderText(config.PanelHeaderWidth, y, "▶ ", style)
+
+	if updateScreen {
+		screen.Show()
+	}
+}
+
+func (c *CommandPanel) SetColorIndex(colorIndex uint8) {
+	c.FilterPanelImpl.SetColorIndex(colorIndex)
+
+	if c.Filter() != nil {
+		model.GetFilterManager().UpdateFilterColorIndex(c.Filter(), colorIndex)
+	}
+}
+
+func (c *CommandPanel) SetContent(content string) {
+	fail.IfNil(c.input, "CommandPanel.SetContent() called without input field!")
+
+	c.input.SetContent(content)
+}
+
+// Content returns the command.
+func (c *CommandPanel) Content() string {
+	return c.input.Content()
+}
+
+func (c *CommandPanel) SetFilter(filter filter.Filter) {
+	c.FilterPanelImpl.SetFilter(filter)
+
+	c.input.SetFilter(filter)
+}
+
+func (c *CommandPanel) changeOutput(i int) {
+	model.GetFilterManager().UpdateFilterKey(c.Filter(), filter.CommandFilterOutput, c.Output())
+
+	c.Render(true)
+}
+
+// Output returns what the command answers, filter.CommandVerdict or
+// filter.CommandTransform.
+func (c *CommandPanel) Output() string {
+	return c.output.SelectedOption()
+}
+
+func (c *CommandPanel) SetOutput(output string) {
+	index := slices.Index(c.output.Options, output)
+	if index == -1 {
+		index = 0
+	}
+	c.output.SetSelectedIndex(index)
+
+	fail.IfNil(c.Filter(), "CommandPanel.SetOutput() called without filter!")
+	model.GetFilterManager().UpdateFilterKey(c.Filter(), filter.CommandFilterOutput, c.Output())
+}
+
+func (c *CommandPanel) changePanelType(i int) {
+	newType := config.FilterType(i)
+	if newType == c.panelType {
+		return
+	}
+
+	c.panelConfig.Key = c.Content()
+	c.panelConfig.Mode = c.Output()
+	c.panelConfig.ColorIndex = c.ColorIndex()
+
+	// Note-to-self: don't put the next lines into FilterPanelImpl!
+	newPanel := NewPanelWithPanelTypeAndConfig(newType, &c.panelConfig)
+	newPanel.Show()
+	err := GetPanelManager().Replace(c, newPanel)
+	fail.OnError(err, "failed to replace panel")
+}
@@ -0,0 +1,116 @@
+package ui
+
+import (
+	"slices"
+
+	"github.com/claude42/infiltrator/components"
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/fail"
+	"github.com/claude42/infiltrator/model"
+	"github.com/claude42/infiltrator/model/filter"
+	"github.com/gdamore/tcell/v2"
+)
+
+// DedupPanel folds runs of repeated lines, either only identical ones or
+// also those differing in numbers, IDs and timestamps.
+type DedupPanel struct {
+	*FilterPanelImpl
+
+	typeSelect *ColoredDropdown
+	dedupMode  *ColoredDropdown
+}
+
+func NewDedupPanel(panelType config.FilterType, name string) *DedupPanel {
+	d := &DedupPanel{
+		FilterPanelImpl: NewFilterPanelImpl(panelType, name),
+	}
+	d.typeSelect = NewColoredDropdown(config.Filters.AllStrings(), tcell.KeyCtrlH, d.changePanelType)
+	d.typeSelect.SetSelectedIndex(int(panelType))
+	d.dedupMode = NewColoredDropdown(filter.DedupModes, tcell.KeyCtrlG, d.changeDedupMode)
+	d.Add(d.typeSelect)
+	d.Add(d.dedupMode)
+
+	return d
+}
+
+func (d *DedupPanel) SetPanelConfig(panelConfig *config.PanelTable) {
+	if panelConfig == nil {
+		return
+	}
+
+	d.SetContent(panelConfig.Key)
+
+	// don't put this into FilterPanelImpl!
+	d.SetColorIndex(panelConfig.ColorIndex)
+}
+
+func (d *DedupPanel) Resize(x, y, width, height int) {
+	d.FilterPanelImpl.Resize(x, y, width, height)
+
+	d.typeSelect.Resize(x+1, y, config.PanelNameWidth, 1)
+	d.dedupMode.Resize(x+config.PanelHeaderWidth+config.PanelHeaderGap, y, 1, 1)
+}
+
+func (d *DedupPanel) Render(updateScreen bool) {
+	if !d.IsVisible() {
+		return
+	}
+
+	d.FilterPanelImpl.Render(false)
+
+	style := d.CurrentStyler.Style()
+
+	_, y := d.Position()
+	x := components.RenderText(config.PanelHeaderWidth-len("Fold "), y, "Fold ", style.Reverse(true))
+	components.RenderText(x, y, "▶ ", style)
+
+	if updateScreen {
+		screen.Show()
+	}
+}
+
+func (d *DedupPanel) SetColorIndex(colorIndex uint8) {
+	d.FilterPanelImpl.SetColorIndex(colorIndex)
+
+	if d.Filter() != nil {
+		model.GetFilterManager().UpdateFilterColorIndex(d.Filter(), colorIndex)
+	}
+}
+
+func (d *DedupPanel) changeDedupMode(i int) {
+	model.GetFilterManager().UpdateFilterKey(d.Filter(), d.Name(), d.Content())
+
+	d.Render(true)
+}
+
+// Content returns the dedup mode, filter.DedupExact or filter.DedupFuzzy.
+func (d *DedupPanel) Content() string {
+	return d.dedupMode.SelectedOption()
+}
+
+func (d *DedupPanel) SetContent(content string) {
+	index := slices.Index(d.dedupMode.Options, content)
+	if index == -1 {
+		index = 0
+	}
+	d.dedupMode.SetSelectedIndex(index)
+
+	fail.IfNil(d.Filter(), "DedupPanel.SetContent() called without filter!")
+	model.GetFilterManager().UpdateFilterKey(d.Filter(), d.Name(), d.Content())
+}
+
+func (d *DedupPanel) changePanelType(i int) {
+	newType := config.FilterType(i)
+	if newType == d.panelType {
+		return
+	}
+
+	d.panelConfig.Key = d.Content()
+	d.panelConfig.ColorIndex = d.ColorIndex()
+
+	// Note-to-self: don't put the next lines into FilterPanelImpl!
+	newPanel := NewPanelWithPanelTypeAndConfig(newType, &d.panelConfig)
+	newPanel.Show()
+	err := GetPanelManager().Replace(d, newPanel)
+	fail.OnError(err, "failed to replace panel")
+}
@@ -48,6 +48,7 @@ type PopupState int
 const (
 	PopupPanelSelection PopupState = iota
 	PopupHelp
+	PopupTemplates
 	PopupNone = -1
 )
 
@@ -0,0 +1,276 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/claude42/infiltrator/components"
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/fail"
+	"github.com/claude42/infiltrator/model"
+	"github.com/claude42/infiltrator/model/filter"
+	"github.com/claude42/infiltrator/model/formats"
+	"github.com/gdamore/tcell/v2"
+)
+
+// width of the value list when closed
+const valueSelectWidth = 40
+
+// FieldValuesPanel lets the user pick from the values actually seen in a
+// field, a single host for the host panel or any number of programs for the
+// facility panel.
+type FieldValuesPanel struct {
+	*FilterPanelImpl
+
+	typeSelect *ColoredDropdown
+	mode       *ColoredDropdown
+	// host panels allow only a single value to be checked
+	valueSelect *ColoredMultiSelect
+
+	// candidates for the field name, the first known one is used
+	fieldCandidates []string
+	field           string
+	// if the filter knows about field already
+	fieldSet bool
+
+	values   []model.FieldValue
+	selected []string
+}
+
+func NewFieldValuesPanel(panelType config.FilterType, name string) *FieldValuesPanel {
+	p := &FieldValuesPanel{
+		FilterPanelImpl: NewFilterPanelImpl(panelType, name),
+	}
+	p.typeSelect = NewColoredDropdown(config.Filters.AllStrings(), tcell.KeyCtrlH, p.changePanelType)
+	p.typeSelect.SetSelectedIndex(int(panelType))
+	p.mode = NewColoredDropdown(config.FilterModeStrings, tcell.KeyCtrlJ, p.toggleMode)
+	p.Add(p.typeSelect)
+	p.Add(p.mode)
+
+	switch panelType {
+	case config.FilterTypeHost:
+		p.fieldCandidates = []string{"host", "hostname"}
+		p.valueSelect = NewColoredMultiSelect("all hosts", tcell.KeyCtrlG, p.changeSelection)
+		p.valueSelect.Single = true
+	case config.FilterTypeFacility:
+		p.fieldCandidates = []string{"program", "facility", "app", "tag"}
+		p.valueSelect = NewColoredMultiSelect("all programs", tcell.KeyCtrlG, p.changeSelection)
+	default:
+		log.Panicf("NewFieldValuesPanel() called with panel type %d", panelType)
+	}
+	p.Add(p.valueSelect)
+
+	return p
+}
+
+func (p *FieldValuesPanel) SetPanelConfig(panelConfig *config.PanelTable) {
+	if panelConfig == nil {
+		return
+	}
+
+	mode := slices.Index(config.FilterModeStrings, panelConfig.Mode)
+	if mode != -1 {
+		p.SetMode(config.FilterMode(mode))
+	}
+	p.SetContent(panelConfig.Key)
+
+	// don't put this into FilterPanelImpl!
+	p.SetColorIndex(panelConfig.ColorIndex)
+}
+
+func (p *FieldValuesPanel) Resize(x, y, width, height int) {
+	p.FilterPanelImpl.Resize(x, y, width, height)
+
+	p.typeSelect.Resize(x+1, y, config.PanelNameWidth, 1)
+	p.mode.Resize(x+config.PanelNameWidth, y, 1, 1)
+
+	valuesX := x + config.PanelHeaderWidth + config.PanelHeaderGap
+	p.valueSelect.Resize(valuesX, y, min(valueSelectWidth, width-valuesX), 1)
+}
+
+func (p *FieldValuesPanel) Render(updateScreen bool) {
+	if !p.IsVisible() {
+		return
+	}
+
+	p.FilterPanelImpl.Render(false)
+
+	style := p.CurrentStyler.Style()
+
+	_, y := p.Position()
+	components.RenderText(config.PanelHeaderWidth, y, "▶ ", style)
+
+	if updateScreen {
+		screen.Show()
+	}
+}
+
+func (p *FieldValuesPanel) HandleEvent(ev tcell.Event) bool {
+	switch ev := ev.(type) {
+	case *model.EventFieldValues:
+		// other panels might be interested as well
+		if ev.Field == p.field {
+			p.setValues(ev.Values)
+			components.RenderAll(true)
+		}
+		return false
+	case *tcell.EventKey:
+		if p.IsActive() && ev.Key() == tcell.KeyCtrlG && !p.valueSelect.IsOpen() {
+			// there might be new values since the list was last opened
+			p.refreshValues()
+		}
+	}
+
+	return p.FilterPanelImpl.HandleEvent(ev)
+}
+
+func (p *FieldValuesPanel) SetColorIndex(colorIndex uint8) {
+	p.FilterPanelImpl.SetColorIndex(colorIndex)
+
+	if p.Filter() != nil {
+		model.GetFilterManager().UpdateFilterColorIndex(p.Filter(), colorIndex)
+	}
+}
+
+func (p *FieldValuesPanel) SetFilter(f filter.Filter) {
+	p.FilterPanelImpl.SetFilter(f)
+
+	p.refreshValues()
+}
+
+// The format might not have been known when the panel was created, so the
+// field gets looked up again.
+func (p *FieldValuesPanel) resolveField() string {
+	for _, candidate := range p.fieldCandidates {
+		if field := formats.FieldName(candidate); field != "" {
+			return field
+		}
+	}
+	// no such field, will match nothing until there is one
+	return p.fieldCandidates[0]
+}
+
+func (p *FieldValuesPanel) refreshValues() {
+	fail.IfNil(p.Filter(), "FieldValuesPanel.refreshValues() called without filter!")
+
+	if field := p.resolveField(); field != p.field || !p.fieldSet {
+		p.field = field
+		p.fieldSet = true
+		model.GetFilterManager().UpdateFilterField(p.Filter(), p.field)
+	}
+	model.GetFilterManager().CountFieldValues(p.field)
+}
+
+func (p *FieldValuesPanel) setValues(values []model.FieldValue) {
+	p.values = values
+	p.updateOptions()
+
+	x, y := p.Position()
+	width, height := p.Size()
+	p.Resize(x, y, width, height)
+}
+
+// Values which got selected but weren't found (e.g. from a preset) are kept
+// at the end of the list.
+func (p *FieldValuesPanel) valueStrings() []string {
+	var values []string
+	for _, value := range p.values {
+		values = append(values, value.Value)
+	}
+	for _, selected := range p.selected {
+		if !slices.Contains(values, selected) {
+			values = append(values, selected)
+		}
+	}
+	return values
+}
+
+func (p *FieldValuesPanel) count(value string) int {
+	i := slices.IndexFunc(p.values, func(v model.FieldValue) bool {
+		return v.Value == value
+	})
+	if i == -1 {
+		return 0
+	}
+	return p.values[i].Count
+}
+
+func (p *FieldValuesPanel) updateOptions() {
+	values := p.valueStrings()
+	options := make([]string, len(values))
+	checked := make([]bool, len(values))
+	for i, value := range values {
+		options[i] = fmt.Sprintf("%s (%d)", value, p.count(value))
+		checked[i] = slices.Contains(p.selected, value)
+	}
+	p.valueSelect.SetOptions(options, values, checked)
+}
+
+func (p *FieldValuesPanel) changeSelection(checked []bool) {
+	p.selected = nil
+	for i, c := range checked {
+		if c {
+			p.selected = append(p.selected, p.valueSelect.Labels[i])
+		}
+	}
+	p.applySelection()
+}
+
+func (p *FieldValuesPanel) applySelection() {
+	model.GetFilterManager().UpdateFilterKey(p.Filter(), p.Name(), p.Content())
+
+	p.Render(true)
+}
+
+// Content returns the selected values, joined by filter.JoinValues(). An
+// empty string means all values.
+func (p *FieldValuesPanel) Content() string {
+	return filter.JoinValues(p.selected)
+}
+
+func (p *FieldValuesPanel) SetContent(content string) {
+	p.selected = filter.SplitValues(content)
+	if p.valueSelect.Single && len(p.selected) > 1 {
+		// e.g. switched over from a facility panel
+		p.selected = p.selected[:1]
+	}
+
+	p.setValues(p.values)
+	fail.IfNil(p.Filter(), "FieldValuesPanel.SetContent() called without filter!")
+	model.GetFilterManager().UpdateFilterKey(p.Filter(), p.Name(), p.Content())
+}
+
+func (p *FieldValuesPanel) toggleMode(i int) {
+	model.GetFilterManager().UpdateFilterMode(p.Filter(), config.FilterMode(i))
+
+	p.Render(true)
+}
+
+func (p *FieldValuesPanel) Mode() config.FilterMode {
+	return config.FilterMode(p.mode.SelectedIndex())
+}
+
+func (p *FieldValuesPanel) SetMode(mode config.FilterMode) {
+	p.mode.SetSelectedIndex(int(mode))
+
+	fail.IfNil(p.Filter(), "FieldValuesPanel.SetMode() called without filter!")
+	model.GetFilterManager().UpdateFilterMode(p.Filter(), mode)
+}
+
+func (p *FieldValuesPanel) changePanelType(i int) {
+	newType := config.FilterType(i)
+	if newType == p.panelType {
+		return
+	}
+
+	p.panelConfig.Key = p.Content()
+	p.panelConfig.Mode = p.Mode().String()
+	p.panelConfig.ColorIndex = p.ColorIndex()
+
+	// Note-to-self: don't put the next lines into FilterPanelImpl!
+	newPanel := NewPanelWithPanelTypeAndConfig(newType, &p.panelConfig)
+	newPanel.Show()
+	err := GetPanelManager().Replace(p, newPanel)
+	fail.OnError(err, "failed to replace panel")
+}
@@ -3,6 +3,7 @@ package ui
 import (
 	"time"
 
+	"github.com/claude42/infiltrator/components"
 	"github.com/claude42/infiltrator/config"
 	"github.com/claude42/infiltrator/fail"
 	"github.com/claude42/infiltrator/model"
@@ -20,6 +21,9 @@ type FilterInput struct {
 	history             *[]string
 	currentHistoryIndex int
 	saveHistoryDelay    *util.Delay
+
+	// if set, errors get shown at the right end of the input
+	validate func(content string) error
 }
 
 func NewFilterInput(name string) *FilterInput {
@@ -63,6 +67,9 @@ func (fi *FilterInput) HandleEvent(ev tcell.Event) bool {
 		return false
 	}
 
+	// the input renders itself without knowing about errors
+	defer fi.renderError(true)
+
 	switch ev := ev.(type) {
 	case *tcell.EventKey:
 		switch ev.Key() {
@@ -110,6 +117,40 @@ func (fi *FilterInput) HandleEvent(ev tcell.Event) bool {
 	return fi.ColoredInput.HandleEvent(ev)
 }
 
+func (fi *FilterInput) Render(updateScreen bool) {
+	fi.ColoredInput.Render(false)
+	fi.renderError(updateScreen)
+}
+
+func (fi *FilterInput) SetValidator(validate func(content string) error) {
+	fi.validate = validate
+}
+
+func (fi *FilterInput) renderError(updateScreen bool) {
+	if fi.validate == nil || !fi.IsVisible() {
+		return
+	}
+
+	err := fi.validate(fi.Content())
+	fi.InputCorrect = err == nil
+	if err == nil {
+		return
+	}
+
+	// only if there's room left after the content
+	text := " " + err.Error() + " "
+	x, y := fi.Position()
+	errorX := x + fi.Width() - len(text)
+	if errorX <= x+len([]rune(fi.Content())) {
+		return
+	}
+	components.RenderText(errorX, y, text, fi.CurrentStyler.Style().Reverse(true))
+
+	if updateScreen {
+		screen.Show()
+	}
+}
+
 func (fi *FilterInput) SetFilter(filter filter.Filter) {
 	fi.filter = filter
 }
@@ -50,6 +50,86 @@ func setupNewDateFilterPanel(panelType config.FilterType,
 	return p
 }
 
+func setupNewFieldValuesPanel(panelType config.FilterType,
+	name string, panelConfig *config.PanelTable) *FieldValuesPanel {
+
+	p := NewFieldValuesPanel(panelType, name)
+	f := filter.NewStringFilter(filter.ValuesFilterFuncFactory, p.Mode())
+	model.GetFilterManager().AddFilter(f)
+	p.SetFilter(f)
+
+	if panelConfig != nil {
+		p.SetPanelConfig(panelConfig)
+	}
+	// done last so both panel and filter get the same color index
+	if panelConfig == nil || panelConfig.ColorIndex == 0 {
+		colorIndex := GetColorManager().Add(p)
+		p.SetColorIndex(colorIndex)
+	}
+
+	return p
+}
+
+func setupNewSeverityPanel(panelType config.FilterType,
+	name string, panelConfig *config.PanelTable) *SeverityPanel {
+
+	p := NewSeverityPanel(panelType, name)
+	f := filter.NewSeverityFilter()
+	model.GetFilterManager().AddFilter(f)
+	p.SetFilter(f)
+
+	if panelConfig != nil {
+		p.SetPanelConfig(panelConfig)
+	}
+	// done last so both panel and filter get the same color index
+	if panelConfig == nil || panelConfig.ColorIndex == 0 {
+		colorIndex := GetColorManager().Add(p)
+		p.SetColorIndex(colorIndex)
+	}
+
+	return p
+}
+
+func setupNewDedupPanel(panelType config.FilterType,
+	name string, panelConfig *config.PanelTable) *DedupPanel {
+
+	p := NewDedupPanel(panelType, name)
+	f := filter.NewDedupFilter()
+	model.GetFilterManager().AddFilter(f)
+	p.SetFilter(f)
+
+	if panelConfig != nil {
+		p.SetPanelConfig(panelConfig)
+	}
+	// done last so both panel and filter get the same color index
+	if panelConfig == nil || panelConfig.ColorIndex == 0 {
+		colorIndex := GetColorManager().Add(p)
+		p.SetColorIndex(colorIndex)
+	}
+
+	return p
+}
+
+func setupNewCommandPanel(panelType config.FilterType,
+	name string, panelConfig *config.PanelTable) *CommandPanel {
+
+	p := NewCommandPanel(panelType, name)
+	f := filter.NewCommandFilter()
+	model.GetFilterManager().AddFilter(f)
+	p.SetFilter(f)
+
+	if panelConfig != nil {
+		p.SetPanelConfig(panelConfig)
+	}
+	// done last so both panel and filter get the same color index
+	if panelConfig == nil || panelConfig.ColorIndex == 0 {
+		colorIndex := GetColorManager().Add(p)
+		p.SetColorIndex(colorIndex)
+	}
+
+	return p
+}
+
 func NewPanel(panelType config.FilterType) FilterPanel {
 	return NewPanelWithPanelTypeAndConfig(panelType, nil)
 }
@@ -76,12 +156,26 @@ func NewPanelWithPanelTypeAndConfig(panelType config.FilterType,
 	case config.FilterTypeRegex:
 		return setupNewStringFilterPanel(panelType, filter.RegexFilterFuncFactory,
 			filterString, panelConfig)
-	// case Glob:
-	// 	return NewGlobPanel()
-	// case Host:
-	// 	return NewHostPanel()
-	// case Facility:
-	// 	return NewFacilityPanel()
+	case config.FilterTypeQuery:
+		return setupNewStringFilterPanel(panelType, filter.QueryFilterFuncFactory,
+			filterString, panelConfig)
+	case config.FilterTypeCompare:
+		return setupNewStringFilterPanel(panelType, filter.CompareFilterFuncFactory,
+			filterString, panelConfig)
+	case config.FilterTypeGlob:
+		return setupNewStringFilterPanel(panelType, filter.GlobFilterFuncFactory,
+			filterString, panelConfig)
+	case config.FilterTypeList:
+		return setupNewStringFilterPanel(panelType, filter.KeywordListFilterFuncFactory,
+			filterString, panelConfig)
+	case config.FilterTypeHost, config.FilterTypeFacility:
+		return setupNewFieldValuesPanel(panelType, filterString, panelConfig)
+	case config.FilterTypeSeverity:
+		return setupNewSeverityPanel(panelType, filterString, panelConfig)
+	case config.FilterTypeDedup:
+		return setupNewDedupPanel(panelType, filterString, panelConfig)
+	case config.FilterTypeCommand:
+		return setupNewCommandPanel(panelType, filterString, panelConfig)
 	case config.FilterTypeDate:
 		// TODO: error handling
 		return setupNewDateFilterPanel(panelType, filterString, panelConfig)
@@ -214,6 +214,32 @@ func (pm *PanelManager) copyPanelsToConfig() {
 				Key:           p.Content(),
 				Mode:          config.FilterModeStrings[p.Mode()],
 				CaseSensitive: p.CaseSensitive(),
+				Origin:        p.Origin(),
+				Field:         p.Field(),
+				Before:        p.Before(),
+				After:         p.After(),
+			}
+		case *FieldValuesPanel:
+			cp = config.PanelTable{
+				Type: p.Name(),
+				Key:  p.Content(),
+				Mode: config.FilterModeStrings[p.Mode()],
+			}
+		case *SeverityPanel:
+			cp = config.PanelTable{
+				Type: p.Name(),
+				Key:  p.Content(),
+			}
+		case *DedupPanel:
+			cp = config.PanelTable{
+				Type: p.Name(),
+				Key:  p.Content(),
+			}
+		case *CommandPanel:
+			cp = config.PanelTable{
+				Type: p.Name(),
+				Key:  p.Content(),
+				Mode: p.Output(),
 			}
 		case *DateFilterPanel:
 			cp = config.PanelTable{
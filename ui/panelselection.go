@@ -10,6 +10,14 @@ import (
 const content = `[ R ] Regular expression
 [ K ] Simple keyword search
 [ G ] Glob style pattern matching
+[ W ] Word list, from a file or comma separated
+[ Q ] Query with AND / OR / NOT
+[ C ] Compare field, e.g. status >= 500
+[ H ] Host
+[ F ] Facility / program
+[ L ] Level / severity threshold
+[ U ] Fold repeated lines
+[ E ] External command, e.g. jq or awk
 [ D ] Date filter`
 
 type PanelSelection struct {
@@ -44,6 +52,60 @@ func (p *PanelSelection) HandleEvent(ev tcell.Event) bool {
 					p.Hide()
 					components.RenderAll(true)
 					return true
+				case 'q':
+					GetPanelManager().CreateAndAdd(config.FilterTypeQuery)
+					GetPanelManager().SetPanelsOpen(true)
+					p.Hide()
+					components.RenderAll(true)
+					return true
+				case 'c':
+					GetPanelManager().CreateAndAdd(config.FilterTypeCompare)
+					GetPanelManager().SetPanelsOpen(true)
+					p.Hide()
+					components.RenderAll(true)
+					return true
+				case 'g':
+					GetPanelManager().CreateAndAdd(config.FilterTypeGlob)
+					GetPanelManager().SetPanelsOpen(true)
+					p.Hide()
+					components.RenderAll(true)
+					return true
+				case 'w':
+					GetPanelManager().CreateAndAdd(config.FilterTypeList)
+					GetPanelManager().SetPanelsOpen(true)
+					p.Hide()
+					components.RenderAll(true)
+					return true
+				case 'h':
+					GetPanelManager().CreateAndAdd(config.FilterTypeHost)
+					GetPanelManager().SetPanelsOpen(true)
+					p.Hide()
+					components.RenderAll(true)
+					return true
+				case 'f':
+					GetPanelManager().CreateAndAdd(config.FilterTypeFacility)
+					GetPanelManager().SetPanelsOpen(true)
+					p.Hide()
+					components.RenderAll(true)
+					return true
+				case 'l':
+					GetPanelManager().CreateAndAdd(config.FilterTypeSeverity)
+					GetPanelManager().SetPanelsOpen(true)
+					p.Hide()
+					components.RenderAll(true)
+					return true
+				case 'u':
+					GetPanelManager().CreateAndAdd(config.FilterTypeDedup)
+					GetPanelManager().SetPanelsOpen(true)
+					p.Hide()
+					components.RenderAll(true)
+					return true
+				case 'e':
+					GetPanelManager().CreateAndAdd(config.FilterTypeCommand)
+					GetPanelManager().SetPanelsOpen(true)
+					p.Hide()
+					components.RenderAll(true)
+					return true
 				case 'd':
 					GetPanelManager().CreateAndAdd(config.FilterTypeDate)
 					GetPanelManager().SetPanelsOpen(true)
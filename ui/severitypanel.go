@@ -0,0 +1,125 @@
+package ui
+
+import (
+	"slices"
+
+	"github.com/claude42/infiltrator/components"
+	"github.com/claude42/infiltrator/config"
+	"github.com/claude42/infiltrator/fail"
+	"github.com/claude42/infiltrator/model"
+	"github.com/claude42/infiltrator/model/lines"
+	"github.com/gdamore/tcell/v2"
+)
+
+// SeverityPanel hides all lines below the selected severity.
+type SeverityPanel struct {
+	*FilterPanelImpl
+
+	typeSelect *ColoredDropdown
+	threshold  *ColoredDropdown
+}
+
+func NewSeverityPanel(panelType config.FilterType, name string) *SeverityPanel {
+	s := &SeverityPanel{
+		FilterPanelImpl: NewFilterPanelImpl(panelType, name),
+	}
+	s.typeSelect = NewColoredDropdown(config.Filters.AllStrings(), tcell.KeyCtrlH, s.changePanelType)
+	s.typeSelect.SetSelectedIndex(int(panelType))
+	s.threshold = NewColoredDropdown(thresholdStrings(), tcell.KeyCtrlG, s.changeThreshold)
+	s.Add(s.typeSelect)
+	s.Add(s.threshold)
+
+	return s
+}
+
+// first entry means "show all lines", followed by all severities from debug
+// upwards (trace would show all lines anyways)
+func thresholdStrings() []string {
+	return append([]string{"all"}, lines.SeverityStrings[lines.SeverityDebug:]...)
+}
+
+func (s *SeverityPanel) SetPanelConfig(panelConfig *config.PanelTable) {
+	if panelConfig == nil {
+		return
+	}
+
+	s.SetContent(panelConfig.Key)
+
+	// don't put this into FilterPanelImpl!
+	s.SetColorIndex(panelConfig.ColorIndex)
+}
+
+func (s *SeverityPanel) Resize(x, y, width, height int) {
+	s.FilterPanelImpl.Resize(x, y, width, height)
+
+	s.typeSelect.Resize(x+1, y, config.PanelNameWidth, 1)
+	s.threshold.Resize(x+config.PanelHeaderWidth+config.PanelHeaderGap, y, 1, 1)
+}
+
+func (s *SeverityPanel) Render(updateScreen bool) {
+	if !s.IsVisible() {
+		return
+	}
+
+	s.FilterPanelImpl.Render(false)
+
+	style := s.CurrentStyler.Style()
+
+	_, y := s.Position()
+	x := components.RenderText(config.PanelHeaderWidth-len("At least "), y, "At least ", style.Reverse(true))
+	components.RenderText(x, y, "▶ ", style)
+
+	if updateScreen {
+		screen.Show()
+	}
+}
+
+func (s *SeverityPanel) SetColorIndex(colorIndex uint8) {
+	s.FilterPanelImpl.SetColorIndex(colorIndex)
+
+	if s.Filter() != nil {
+		model.GetFilterManager().UpdateFilterColorIndex(s.Filter(), colorIndex)
+	}
+}
+
+func (s *SeverityPanel) changeThreshold(i int) {
+	model.GetFilterManager().UpdateFilterKey(s.Filter(), s.Name(), s.Content())
+
+	s.Render(true)
+}
+
+// Content returns the name of the selected severity or an empty string if all
+// lines are shown.
+func (s *SeverityPanel) Content() string {
+	if s.threshold.SelectedIndex() == 0 {
+		return ""
+	}
+	return s.threshold.SelectedOption()
+}
+
+func (s *SeverityPanel) SetContent(content string) {
+	index := slices.Index(s.threshold.Options, content)
+	if content == "" || index == -1 {
+		index = 0
+	}
+	s.threshold.SetSelectedIndex(index)
+
+	fail.IfNil(s.Filter(), "SeverityPanel.SetContent() called without filter!")
+	model.GetFilterManager().UpdateFilterKey(s.Filter(), s.Name(), s.Content())
+}
+
+func (s *SeverityPanel) changePanelType(i int) {
+	newType := config.FilterType(i)
+	if newType == s.panelType {
+		return
+	}
+
+	s.panelConfig.Key = s.Content()
+	s.panelConfig.ColorIndex = s.ColorIndex()
+
+	// Note-to-self: don't put the next lines into FilterPanelImpl!
+	newPanel := NewPanelWithPanelTypeAndConfig(newType, &s.panelConfig)
+	newPanel.Show()
+	err := GetPanelManager().Replace(s, newPanel)
+	fail.OnError(err, "failed to replace panel")
+}
@@ -2,12 +2,19 @@ package ui
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/claude42/infiltrator/components"
 	"github.com/claude42/infiltrator/config"
 	"github.com/claude42/infiltrator/model"
 	"github.com/claude42/infiltrator/model/busy"
+	"github.com/claude42/infiltrator/model/filter"
+	"github.com/claude42/infiltrator/model/formats"
+	"github.com/claude42/infiltrator/model/lines"
+	"github.com/claude42/infiltrator/model/reader"
 	"github.com/claude42/infiltrator/util"
 
 	"github.com/gdamore/tcell/v2"
@@ -23,6 +30,7 @@ const (
 )
 
 const StatusDefaultText = "[/] search [n/N] next/previous match [F] follow"
+const StatusCommandText = StatusDefaultText + " [R] restart"
 const StatusFollow = StatusDefault
 const StatusPanelOpenText = "[CTRL-S] change mode [CTRL-H] change case sensitive [CTRL-P/O] add remove panel"
 
@@ -35,6 +43,14 @@ type Statusbar struct {
 	panelsOpen             bool
 	busyVisualizationIndex int
 	busyState              busy.State
+	// file the first line on screen came from
+	origin string
+	// e.g. "running" or "exit 1" when reading from a command
+	commandStatus string
+	// fields of the current line, e.g. "host=web-0 program=sshd"
+	fields string
+	// last error of a command filter, shown instead of the fields
+	commandFilterError string
 }
 
 func NewStatusbar() *Statusbar {
@@ -87,42 +103,98 @@ func (s *Statusbar) Render(updateScreen bool) {
 func (s *Statusbar) renderDefaultStatusBar() {
 	s.renderPercentage()
 
-	s.renderFileName()
+	fileNameStart := s.renderFileName()
 
-	s.renderStatusDefaultText()
+	textEnd := s.renderStatusDefaultText()
+
+	s.renderFields(textEnd, fileNameStart)
 }
 
 func (s *Statusbar) renderFollowStausBar() {
 	s.renderFollow()
 
-	s.renderFileName()
+	fileNameStart := s.renderFileName()
+
+	textEnd := s.renderStatusDefaultText()
 
-	s.renderStatusDefaultText()
+	s.renderFields(textEnd, fileNameStart)
 }
 
-func (s *Statusbar) renderStatusDefaultText() {
+// returns where the text ends
+func (s *Statusbar) renderStatusDefaultText() int {
 	_, y := s.Position()
-	components.RenderText(0, y, StatusDefaultText, StatusBarStyle)
+	if len(config.User().Command) > 0 {
+		components.RenderText(0, y, StatusCommandText, StatusBarStyle)
+		return len(StatusCommandText)
+	} else {
+		components.RenderText(0, y, StatusDefaultText, StatusBarStyle)
+		return len(StatusDefaultText)
+	}
 }
 
 func (s *Statusbar) renderPanelOpenStatusBar() {
 	s.renderPercentage()
 
-	s.renderFileName()
+	fileNameStart := s.renderFileName()
 
 	_, y := s.Position()
 	components.RenderText(0, y, StatusPanelOpenText, StatusBarStyle)
+
+	// helps while typing the command
+	if s.commandFilterError != "" {
+		s.renderBetween(len(StatusPanelOpenText), fileNameStart,
+			s.commandFilterError, StatusBarErrorStyle)
+	}
 }
 
-func (s *Statusbar) renderFileName() {
+// renders the fields of the current line between start and end, or the error
+// of a command filter if there is one
+func (s *Statusbar) renderFields(start int, end int) {
+	if s.commandFilterError != "" {
+		s.renderBetween(start, end, s.commandFilterError, StatusBarErrorStyle)
+	} else {
+		s.renderBetween(start, end, s.fields, StatusBarStyle)
+	}
+}
+
+// renders as much of text as fits between start and end
+func (s *Statusbar) renderBetween(start int, end int, text string, style tcell.Style) {
+	const spacer = 2
+	start += spacer
+	end -= spacer
+
+	if text == "" || end-start < 10 {
+		return
+	}
+
+	// RenderText() draws one cell per rune
+	runes := []rune(text)
+	if len(runes) > end-start {
+		runes = append(runes[:end-start-1], '…')
+	}
+
+	_, y := s.Position()
+	components.RenderText(start, y, string(runes), style)
+}
+
+// returns where the file name starts
+func (s *Statusbar) renderFileName() int {
 	const spacer = 4
 	const percentLength = 9
-	fileNameStr := fmt.Sprintf("\"%s\"", config.User().FileName)
-	length := len(fileNameStr)
+	fileName := config.User().FileName
+	if s.origin != "" && len(config.User().Command) == 0 {
+		fileName = filepath.Base(s.origin)
+	}
+	fileNameStr := fmt.Sprintf("\"%s\"", fileName)
+	if s.commandStatus != "" {
+		fileNameStr += " [" + s.commandStatus + "]"
+	}
+	length := utf8.RuneCountInString(fileNameStr)
 	start := s.Width() - length - spacer - percentLength
 
 	_, y := s.Position()
 	components.RenderText(start, y, fileNameStr, StatusBarStyle)
+	return start
 }
 
 func (s *Statusbar) renderPercentage() {
@@ -194,6 +266,17 @@ func (s *Statusbar) HandleEvent(ev tcell.Event) bool {
 		screen.Show()
 	case *model.EventDisplay:
 		s.percentage = ev.Display.Percentage
+		if first := firstLine(ev.Display); first != nil && first.Origin != s.origin {
+			s.origin = first.Origin
+			s.fields = currentLineFields(ev.Display)
+			s.Render(true)
+			return false
+		}
+		if fields := currentLineFields(ev.Display); fields != s.fields {
+			s.fields = fields
+			s.Render(true)
+			return false
+		}
 		s.renderPercentage()
 		screen.Show()
 	case *model.EventFileChanged:
@@ -204,6 +287,23 @@ func (s *Statusbar) HandleEvent(ev tcell.Event) bool {
 		s.percentage = ev.Percentage()
 		s.renderPercentage()
 		screen.Show()
+	case *reader.EventCommandStatus:
+		switch {
+		case ev.Running:
+			s.commandStatus = "running"
+		case ev.Err != nil:
+			s.commandStatus = "failed"
+		default:
+			s.commandStatus = fmt.Sprintf("exit %d", ev.ExitCode)
+		}
+		s.Render(true)
+	case *filter.EventCommandFilterStatus:
+		if ev.Err != nil {
+			s.commandFilterError = ev.Command + ": " + ev.Err.Error()
+		} else {
+			s.commandFilterError = ""
+		}
+		s.Render(true)
 	case *EventPanelStateChanged:
 		s.panelsOpen = ev.PanelsOpen()
 		s.Render(true)
@@ -211,3 +311,43 @@ func (s *Statusbar) HandleEvent(ev tcell.Event) bool {
 
 	return false
 }
+
+// The current line is the current match or if there is none, the first line
+// on screen.
+func currentLineFields(display model.Display) string {
+	var current *lines.Line
+	for _, line := range display.Buffer {
+		if line.No == display.CurrentMatch {
+			current = line
+			break
+		}
+	}
+	if current == nil {
+		current = firstLine(display)
+	}
+	if current == nil || current.Marker {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, field := range formats.Fields(current.Str) {
+		if field.Value == "" {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(field.Name + "=" + field.Value)
+	}
+	return sb.String()
+}
+
+// first line on screen which isn't a divider
+func firstLine(display model.Display) *lines.Line {
+	for _, line := range display.Buffer {
+		if line.Status != lines.LineDivider {
+			return line
+		}
+	}
+	return nil
+}
@@ -1,13 +1,17 @@
 package ui
 
 import (
+	"path/filepath"
 	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/claude42/infiltrator/components"
 	"github.com/claude42/infiltrator/config"
 	"github.com/claude42/infiltrator/fail"
 	"github.com/claude42/infiltrator/model"
 	"github.com/claude42/infiltrator/model/filter"
+	"github.com/claude42/infiltrator/model/formats"
 
 	"github.com/gdamore/tcell/v2"
 )
@@ -19,8 +23,25 @@ type StringFilterPanel struct {
 	typeSelect    *ColoredDropdown
 	mode          *ColoredDropdown
 	caseSensitive *ColoredDropdown
+
+	// only available when several files get merged
+	origin *ColoredDropdown
+	// only available when the file format has named fields
+	field *ColoredDropdown
+
+	// context lines before and after matches, only used in match mode
+	before *ColoredDropdown
+	after  *ColoredDropdown
 }
 
+// sizes of the context offered in the dropdowns
+var contextSizes = []int{0, 1, 2, 3, 5, 10}
+
+const (
+	contextBeforeFlag = "-B"
+	contextAfterFlag  = "-A"
+)
+
 func NewStringFilterPanel(panelType config.FilterType, name string) *StringFilterPanel {
 
 	s := &StringFilterPanel{
@@ -34,8 +55,32 @@ func NewStringFilterPanel(panelType config.FilterType, name string) *StringFilte
 	s.Add(s.typeSelect)
 	s.Add(s.mode)
 	s.Add(s.caseSensitive)
+	s.before = NewColoredDropdown(contextStrings(contextBeforeFlag), tcell.KeyCtrlR, s.changeContext)
+	s.after = NewColoredDropdown(contextStrings(contextAfterFlag), tcell.KeyCtrlN, s.changeContext)
+	s.updateContextDropdowns()
+	if config.User().Merge {
+		s.origin = NewColoredDropdown(originStrings(), tcell.KeyCtrlT, s.changeOrigin)
+		s.Add(s.origin)
+	}
+	if fields := fieldStrings(); len(fields) > 1 {
+		s.field = NewColoredDropdown(fields, tcell.KeyCtrlG, s.changeField)
+		s.Add(s.field)
+	}
 	s.Add(s.input)
 
+	switch panelType {
+	case config.FilterTypeQuery:
+		s.input.SetValidator(func(content string) error {
+			_, err := filter.QueryFilterFuncFactory(content, false)
+			return err
+		})
+	case config.FilterTypeCompare:
+		s.input.SetValidator(func(content string) error {
+			_, err := filter.CompareFilterFuncFactory(content, false)
+			return err
+		})
+	}
+
 	return s
 }
 
@@ -50,6 +95,9 @@ func (s *StringFilterPanel) SetPanelConfig(panelConfig *config.PanelTable) {
 		s.SetMode(config.FilterMode(mode))
 	}
 	s.SetCaseSensitive(panelConfig.CaseSensitive)
+	s.SetOrigin(panelConfig.Origin)
+	s.SetField(panelConfig.Field)
+	s.SetContext(panelConfig.Before, panelConfig.After)
 
 	// don't put this into FilterPanelImpl!
 	s.SetColorIndex(panelConfig.ColorIndex)
@@ -59,8 +107,23 @@ func (s *StringFilterPanel) Resize(x, y, width, height int) {
 	s.FilterPanelImpl.Resize(x, y, width, height)
 
 	s.typeSelect.Resize(x+1, y, config.PanelNameWidth, 1)
-	s.input.Resize(x+config.PanelHeaderWidth+config.PanelHeaderGap, y,
-		width-(x+config.PanelHeaderWidth+config.PanelHeaderGap), 1)
+
+	inputX := x + config.PanelHeaderWidth + config.PanelHeaderGap
+	if s.showsContext() {
+		s.before.Resize(inputX, y, 1, 1)
+		inputX += s.before.Width() + 1
+		s.after.Resize(inputX, y, 1, 1)
+		inputX += s.after.Width() + 1
+	}
+	if s.origin != nil {
+		s.origin.Resize(inputX, y, 1, 1)
+		inputX += s.origin.Width() + 1
+	}
+	if s.field != nil {
+		s.field.Resize(inputX, y, 1, 1)
+		inputX += s.field.Width() + 1
+	}
+	s.input.Resize(inputX, y, width-inputX, 1)
 	s.mode.Resize(x+config.PanelNameWidth, y, 1, 1)
 	s.caseSensitive.Resize(x+config.PanelNameWidth+8, y, 1, 1)
 }
@@ -109,6 +172,7 @@ func (s *StringFilterPanel) SetFilter(filter filter.Filter) {
 func (s *StringFilterPanel) toggleMode(i int) {
 	model.GetFilterManager().UpdateFilterMode(s.Filter(), config.FilterMode(i))
 
+	s.updateContextDropdowns()
 	s.Render(true)
 }
 
@@ -124,6 +188,7 @@ func (s *StringFilterPanel) Mode() config.FilterMode {
 
 func (s *StringFilterPanel) SetMode(mode config.FilterMode) {
 	s.mode.SetSelectedIndex(int(mode))
+	s.updateContextDropdowns()
 
 	fail.IfNil(s.Filter(), "StringFilterPanel.SetMode() called without filter!")
 	model.GetFilterManager().UpdateFilterMode(s.Filter(), mode)
@@ -145,6 +210,176 @@ func (s *StringFilterPanel) SetCaseSensitive(caseSensitive bool) {
 	model.GetFilterManager().UpdateFilterCaseSensitiveUpdate(s.Filter(), caseSensitive)
 }
 
+func (s *StringFilterPanel) changeOrigin(i int) {
+	model.GetFilterManager().UpdateFilterOrigin(s.Filter(), s.Origin())
+
+	s.Render(true)
+}
+
+// Origin returns the path of the file the filter is restricted to, or an
+// empty string if it applies to all files.
+func (s *StringFilterPanel) Origin() string {
+	if s.origin == nil || s.origin.SelectedIndex() == 0 {
+		return ""
+	}
+
+	return config.User().FilePaths[s.origin.SelectedIndex()-1]
+}
+
+func (s *StringFilterPanel) SetOrigin(origin string) {
+	if s.origin == nil {
+		return
+	}
+
+	index := slices.Index(config.User().FilePaths, filepath.Clean(origin))
+	if origin == "" || index == -1 {
+		s.origin.SetSelectedIndex(0)
+	} else {
+		s.origin.SetSelectedIndex(index + 1)
+	}
+
+	fail.IfNil(s.Filter(), "StringFilterPanel.SetOrigin() called without filter!")
+	model.GetFilterManager().UpdateFilterOrigin(s.Filter(), s.Origin())
+}
+
+// first entry means "all files", followed by the base names of all merged
+// files
+func originStrings() []string {
+	origins := []string{"all files"}
+	for _, filePath := range config.User().FilePaths {
+		origins = append(origins, filepath.Base(filePath))
+	}
+	return origins
+}
+
+func (s *StringFilterPanel) changeField(i int) {
+	model.GetFilterManager().UpdateFilterField(s.Filter(), s.Field())
+
+	s.Render(true)
+}
+
+// Field returns the name of the field the filter is restricted to, or an
+// empty string if it applies to the whole line.
+func (s *StringFilterPanel) Field() string {
+	if s.field == nil || s.field.SelectedIndex() == 0 {
+		return ""
+	}
+
+	return s.field.SelectedOption()
+}
+
+func (s *StringFilterPanel) SetField(field string) {
+	if s.field == nil {
+		if field == "" {
+			return
+		}
+		// e.g. a preset for JSON lines gets loaded before the format of the
+		// file is known
+		s.Remove(s.input)
+		s.field = NewColoredDropdown(fieldStrings(), tcell.KeyCtrlG, s.changeField)
+		s.Add(s.field)
+		s.Add(s.input)
+	}
+
+	index := slices.Index(s.field.Options, field)
+	if field == "" {
+		index = 0
+	} else if index == -1 {
+		// e.g. a preset written for another format, keep it anyways
+		s.field.SetOptions(append(s.field.Options, field))
+		index = len(s.field.Options) - 1
+	}
+	s.field.SetSelectedIndex(index)
+
+	fail.IfNil(s.Filter(), "StringFilterPanel.SetField() called without filter!")
+	model.GetFilterManager().UpdateFilterField(s.Filter(), s.Field())
+}
+
+// first entry means "whole line", followed by the names of all fields
+func fieldStrings() []string {
+	return append([]string{"line"}, formats.FieldNames()...)
+}
+
+// Context is only shown in match mode, otherwise the dropdowns aren't there
+// at all.
+func (s *StringFilterPanel) updateContextDropdowns() {
+	inMatchMode := s.Mode() == config.FilterMatch
+	if inMatchMode == s.showsContext() {
+		return
+	}
+
+	if inMatchMode {
+		for _, dropdown := range []*ColoredDropdown{s.before, s.after} {
+			dropdown.SetActive(s.IsActive())
+			dropdown.SetVisible(s.IsVisible())
+			s.Add(dropdown)
+		}
+	} else {
+		s.Remove(s.before)
+		s.Remove(s.after)
+	}
+
+	// make room for the dropdowns or take it back, unless the panel hasn't
+	// been laid out yet
+	if width, height := s.Size(); width > 0 {
+		x, y := s.Position()
+		s.Resize(x, y, width, height)
+	}
+}
+
+func (s *StringFilterPanel) showsContext() bool {
+	return slices.Contains(s.Contained(), components.Component(s.before))
+}
+
+func contextStrings(flag string) []string {
+	strs := make([]string, len(contextSizes))
+	for i, size := range contextSizes {
+		strs[i] = flag + strconv.Itoa(size)
+	}
+	return strs
+}
+
+func (s *StringFilterPanel) changeContext(i int) {
+	model.GetFilterManager().UpdateFilterContext(s.Filter(), s.Before(), s.After())
+
+	s.Render(true)
+}
+
+// Before returns how many lines before each match are shown in match mode.
+func (s *StringFilterPanel) Before() int {
+	return contextSize(s.before, contextBeforeFlag)
+}
+
+// After returns how many lines after each match are shown in match mode.
+func (s *StringFilterPanel) After() int {
+	return contextSize(s.after, contextAfterFlag)
+}
+
+func contextSize(dropdown *ColoredDropdown, flag string) int {
+	size, err := strconv.Atoi(strings.TrimPrefix(dropdown.SelectedOption(), flag))
+	fail.OnError(err, "invalid context size")
+	return size
+}
+
+func (s *StringFilterPanel) SetContext(before int, after int) {
+	selectContextSize(s.before, contextBeforeFlag, before)
+	selectContextSize(s.after, contextAfterFlag, after)
+
+	fail.IfNil(s.Filter(), "StringFilterPanel.SetContext() called without filter!")
+	model.GetFilterManager().UpdateFilterContext(s.Filter(), s.Before(), s.After())
+}
+
+func selectContextSize(dropdown *ColoredDropdown, flag string, size int) {
+	option := flag + strconv.Itoa(max(0, size))
+	index := slices.Index(dropdown.Options, option)
+	if index == -1 {
+		// e.g. a preset edited by hand
+		dropdown.SetOptions(append(dropdown.Options, option))
+		index = len(dropdown.Options) - 1
+	}
+	dropdown.SetSelectedIndex(index)
+}
+
 func (s *StringFilterPanel) SetName(name string) {
 	s.FilterPanelImpl.SetName(name)
 	s.input.SetName(name)
@@ -159,6 +394,10 @@ func (s *StringFilterPanel) changePanelType(i int) {
 	s.panelConfig.Key = s.Content()
 	s.panelConfig.Mode = s.Mode().String()
 	s.panelConfig.CaseSensitive = s.CaseSensitive()
+	s.panelConfig.Origin = s.Origin()
+	s.panelConfig.Field = s.Field()
+	s.panelConfig.Before = s.Before()
+	s.panelConfig.After = s.After()
 	s.panelConfig.ColorIndex = s.ColorIndex()
 
 	// Note-to-self: don't put the next lines into FilterPanelImpl!
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"github.com/claude42/infiltrator/model/lines"
 	"github.com/gdamore/tcell/v2"
 )
 
@@ -10,6 +11,25 @@ var DefStyle = tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.
 var ViewStyle = DefStyle
 var ViewDimmedStyle = DefStyle.Foreground(tcell.ColorDarkGray)
 var CurrentMatchStyle = DefStyle.Foreground(tcell.ColorYellow)
+var ViewMarkerStyle = DefStyle.Foreground(tcell.ColorDarkCyan).Reverse(true)
+var ViewDividerStyle = DefStyle.Foreground(tcell.ColorDarkCyan)
+var ViewRepeatsStyle = DefStyle.Foreground(tcell.ColorDarkCyan).Reverse(true)
+var ViewStderrStyle = DefStyle.Foreground(tcell.ColorIndianRed)
+
+// lines of rarely seen templates
+var ViewAnomalyBackground = tcell.ColorMaroon
+
+// e.g. the keys and values of logfmt lines
+var ViewFieldKeyColor = tcell.ColorSteelBlue
+var ViewFieldValueColor = tcell.ColorDarkKhaki
+
+// Lines get tinted according to their severity if colorize is on, anything
+// below warning keeps the default color.
+var ViewSeverityColors = map[lines.Severity]tcell.Color{
+	lines.SeverityWarning:  tcell.ColorGoldenrod,
+	lines.SeverityError:    tcell.ColorIndianRed,
+	lines.SeverityCritical: tcell.ColorFuchsia,
+}
 
 var ViewLineNumberStyle = DefStyle.Foreground(tcell.ColorOrange)
 var ViewDimmedLineNumberStyle = ViewLineNumberStyle.Foreground(tcell.ColorBrown)
@@ -20,6 +40,7 @@ var DimmedViewOverflowStyle = ViewOverflowStyle.Foreground(tcell.ColorDimGray)
 
 var StatusBarStyle = tcell.StyleDefault.Reverse((true)).Bold((true))
 var StatusBarBusyStyle = StatusBarStyle.Foreground(tcell.ColorRed)
+var StatusBarErrorStyle = StatusBarStyle.Foreground(tcell.ColorRed)
 
 // var TextInputStyle = tcell.StyleDefault.Background(tcell.ColorDarkBlue)
 // var ActiveTextInputStyle = tcell.StyleDefault.Background(tcell.ColorWhite).Foreground(tcell.ColorBlack).Bold(true)
@@ -0,0 +1,301 @@
+package ui
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/claude42/infiltrator/components"
+	"github.com/claude42/infiltrator/model"
+	"github.com/claude42/infiltrator/model/filter"
+	"github.com/claude42/infiltrator/model/templates"
+	"github.com/gdamore/tcell/v2"
+)
+
+const templatesHelp = "[h] hide  [f] focus  [Enter] jump  [a] anomalies  [r] rare first  [Esc] close"
+
+const maxTemplatesModalWidth = 120
+
+// TemplatesModal lists the templates all lines got clustered into, most
+// frequent ones first. Templates can be hidden, focused on or jumped to.
+type TemplatesModal struct {
+	components.ModalImpl
+
+	index     *templates.Index
+	templates []*templates.Template
+	selection filter.TemplateSelection
+	rareFirst bool
+
+	cursor int
+	offset int
+}
+
+func NewTemplatesModal() *TemplatesModal {
+	t := &TemplatesModal{}
+	t.SetTitle("Templates")
+
+	return t
+}
+
+// Open shows the modal and mines the templates (again), new lines might have
+// come in since the last time.
+func (t *TemplatesModal) Open() {
+	t.index = nil
+	t.templates = nil
+	t.Resize(-1, -1, -1, -1)
+	t.Show()
+
+	model.GetFilterManager().MineTemplates()
+}
+
+func (t *TemplatesModal) Resize(x, y, width, height int) {
+	// x, y, width and height are ignored, the modal takes most of the screen
+	screenWidth, screenHeight := screen.Size()
+	t.ModalImpl.Resize(-1, -1, min(screenWidth-4, maxTemplatesModalWidth),
+		max(screenHeight-4, 6))
+}
+
+func (t *TemplatesModal) rows() int {
+	return max(1, t.Height()-5)
+}
+
+func (t *TemplatesModal) Render(updateScreen bool) {
+	if !t.IsVisible() {
+		return
+	}
+
+	t.ModalImpl.Render(false)
+
+	x, y := t.Position()
+	width, height := t.Size()
+
+	if t.index == nil {
+		components.RenderText(x+2, y+2, "Mining templates...", components.ModalStyle)
+	}
+
+	for row := range t.rows() {
+		i := t.offset + row
+		if i >= len(t.templates) {
+			break
+		}
+
+		style := components.ModalStyle
+		if i == t.cursor {
+			style = style.Reverse(false)
+		}
+		text := []rune(fmt.Sprintf("%-*s", width-4, t.describe(t.templates[i])))
+		components.RenderText(x+2, y+2+row, string(text[:max(0, width-4)]), style)
+	}
+
+	components.RenderText(x+2, y+height-2, templatesHelp, components.ModalStyle)
+
+	if updateScreen {
+		screen.Show()
+	}
+}
+
+// e.g. "H! 12  user <*> logged in"
+func (t *TemplatesModal) describe(template *templates.Template) string {
+	pattern := template.String()
+
+	state := ' '
+	switch {
+	case slices.Contains(t.selection.Hidden, pattern):
+		state = 'H'
+	case pattern == t.selection.Focused:
+		state = 'F'
+	case pattern == t.selection.Marked:
+		state = '>'
+	}
+	rare := ' '
+	if t.index.IsRare(template) {
+		rare = '!'
+	}
+
+	return fmt.Sprintf("%c%c %8d  %s", state, rare, template.Count, pattern)
+}
+
+func (t *TemplatesModal) setIndex(index *templates.Index) {
+	t.index = index
+	t.templates = slices.Clone(index.Templates())
+	t.sort()
+}
+
+func (t *TemplatesModal) sort() {
+	slices.SortFunc(t.templates, func(a, b *templates.Template) int {
+		c := cmp.Compare(b.Count, a.Count)
+		if t.rareFirst {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	t.cursor, t.offset = 0, 0
+}
+
+func (t *TemplatesModal) moveCursor(offset int) {
+	if len(t.templates) == 0 {
+		return
+	}
+
+	t.cursor = max(0, min(len(t.templates)-1, t.cursor+offset))
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	} else if t.cursor >= t.offset+t.rows() {
+		t.offset = t.cursor - t.rows() + 1
+	}
+}
+
+func (t *TemplatesModal) HandleEvent(ev tcell.Event) bool {
+	switch ev := ev.(type) {
+	case *model.EventTemplates:
+		t.setIndex(ev.Index)
+		t.Render(true)
+		return false
+	case *model.EventDisplay:
+		// stay on top of the view
+		t.Render(true)
+		return false
+	case *tcell.EventKey:
+		if !t.IsActive() {
+			return false
+		}
+		t.handleKey(ev)
+		t.Render(true)
+		return true
+	}
+
+	return false
+}
+
+func (t *TemplatesModal) handleKey(ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		t.close()
+	case tcell.KeyUp:
+		t.moveCursor(-1)
+	case tcell.KeyDown:
+		t.moveCursor(1)
+	case tcell.KeyPgUp:
+		t.moveCursor(-t.rows())
+	case tcell.KeyPgDn:
+		t.moveCursor(t.rows())
+	case tcell.KeyHome:
+		t.moveCursor(-len(t.templates))
+	case tcell.KeyEnd:
+		t.moveCursor(len(t.templates))
+	case tcell.KeyEnter:
+		t.jump()
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'T', 'q':
+			t.close()
+		case 'k':
+			t.moveCursor(-1)
+		case 'j':
+			t.moveCursor(1)
+		case 'h':
+			t.toggleHidden()
+		case 'f':
+			t.toggleFocused()
+		case 'a':
+			t.selection.Anomalies = !t.selection.Anomalies
+			t.updateSelection()
+		case 'r':
+			t.rareFirst = !t.rareFirst
+			t.sort()
+		}
+	}
+}
+
+func (t *TemplatesModal) current() (string, bool) {
+	if t.cursor >= len(t.templates) {
+		return "", false
+	}
+	return t.templates[t.cursor].String(), true
+}
+
+func (t *TemplatesModal) toggleHidden() {
+	pattern, ok := t.current()
+	if !ok {
+		screen.Beep()
+		return
+	}
+
+	if i := slices.Index(t.selection.Hidden, pattern); i != -1 {
+		t.selection.Hidden = slices.Delete(t.selection.Hidden, i, i+1)
+	} else {
+		t.selection.Hidden = append(t.selection.Hidden, pattern)
+		if t.selection.Focused == pattern {
+			t.selection.Focused = ""
+		}
+	}
+	t.updateSelection()
+}
+
+func (t *TemplatesModal) toggleFocused() {
+	pattern, ok := t.current()
+	if !ok {
+		screen.Beep()
+		return
+	}
+
+	if t.selection.Focused == pattern {
+		t.selection.Focused = ""
+	} else {
+		t.selection.Focused = pattern
+		t.selection.Hidden = slices.DeleteFunc(t.selection.Hidden, func(h string) bool {
+			return h == pattern
+		})
+	}
+	t.updateSelection()
+}
+
+// Lines of the template become matches so n / N go to the next ones.
+func (t *TemplatesModal) jump() {
+	pattern, ok := t.current()
+	if !ok {
+		screen.Beep()
+		return
+	}
+
+	// the lines have to be visible to jump to them
+	t.selection.Marked = pattern
+	t.selection.Hidden = slices.DeleteFunc(t.selection.Hidden, func(h string) bool {
+		return h == pattern
+	})
+	if t.selection.Focused != pattern {
+		t.selection.Focused = ""
+	}
+	t.updateSelection()
+
+	model.GetFilterManager().JumpToLine(t.templates[t.cursor].First)
+	t.close()
+}
+
+func (t *TemplatesModal) updateSelection() {
+	// the slice gets modified in place later on
+	selection := t.selection
+	selection.Hidden = slices.Clone(t.selection.Hidden)
+	model.GetFilterManager().UpdateTemplateSelection(selection)
+}
+
+func (t *TemplatesModal) close() {
+	t.Hide()
+	components.RenderAll(true)
+}
+
+func (t *TemplatesModal) SetActive(active bool) {
+	t.ModalImpl.SetActive(active)
+
+	var popupState PopupState
+	if active {
+		popupState = PopupTemplates
+	} else {
+		popupState = PopupNone
+	}
+
+	GetScreen().PostEvent(NewEventPopupStateChanged(popupState, t))
+}
@@ -2,16 +2,22 @@ package ui
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/claude42/infiltrator/components"
 	"github.com/claude42/infiltrator/config"
 	"github.com/claude42/infiltrator/model"
+	"github.com/claude42/infiltrator/model/formats"
 	"github.com/claude42/infiltrator/model/lines"
 	"github.com/claude42/infiltrator/util"
 
 	"github.com/gdamore/tcell/v2"
 )
 
+// Running the format's regex on huge lines for each and every screen update
+// would make the UI sluggish.
+const maxColorizeLength = 64 * 1024
+
 type View struct {
 	components.ComponentImpl
 
@@ -67,6 +73,11 @@ func (v *View) RenderNewDisplay(display *model.Display, updateScreen bool) {
 }
 
 func (v *View) renderLine(line *lines.Line, y int) {
+	if line.Status == lines.LineDivider {
+		v.renderDivider(y)
+		return
+	}
+
 	str := line.Str
 	start := 0
 	matched := line.No == v.CurrentDisplay.CurrentMatch
@@ -76,13 +87,42 @@ func (v *View) renderLine(line *lines.Line, y int) {
 		start = v.renderLineNumber(line, y, matched)
 	}
 
+	if cfg.Merge {
+		start = v.renderOriginGutter(line, start, y)
+	}
+
 	lineStyle := v.determineStyle(line, matched)
+	if line.Anomaly && !matched && !line.Marker {
+		lineStyle = lineStyle.Background(ViewAnomalyBackground)
+	}
+
+	// the tint wins over the colors of the format but not over matches
+	colorize := cfg.Colorize
+	if tint, ok := v.severityTint(line, matched); ok && colorize {
+		lineStyle = lineStyle.Foreground(tint)
+		colorize = false
+	}
+
+	// JSON and logfmt lines might get rendered in a compact form, matches
+	// still refer to the original line
+	var compact *formats.CompactLine
+	if cfg.Compact && formats.CanCompact() && !line.Marker &&
+		len(line.Str) <= maxColorizeLength {
+
+		compact = formats.Compact(line.Str)
+		if compact != nil {
+			str = compact.Str
+		}
+	}
 
 	var detectedTokens []int
-	if cfg.Colorize {
+	var logfmtFields []formats.Field
+	if colorize && !line.Marker && len(line.Str) <= maxColorizeLength {
 		fileFormatRegex := cfg.FileFormatRegex
 		if fileFormatRegex != nil {
 			detectedTokens = fileFormatRegex.FindStringSubmatchIndex(line.Str)
+		} else if compact == nil && cfg.FileFormat == formats.FormatLogfmt {
+			logfmtFields = formats.Fields(line.Str)
 		}
 	}
 
@@ -94,27 +134,56 @@ func (v *View) renderLine(line *lines.Line, y int) {
 		if v.CurrentDisplay.CurrentCol+x < len(str)+start {
 			r = rune(str[lineXPos])
 
+			rawXPos := lineXPos
+			if compact != nil {
+				rawXPos = compact.RawPos[lineXPos]
+			}
+
 			if x == v.Width()-1 && v.CurrentDisplay.CurrentCol+x+1 < len(str) {
 				// in case we're on the last screen column, render an inverse '>'
 				r = '>'
 				style = style.Reverse(true)
-			} else if line.ColorIndex[lineXPos] > 0 {
+			} else if rawXPos >= 0 && line.ColorIndex[rawXPos] > 0 {
 				// if something matched render the character in the color of the corresponding filter
 				switch line.Status {
 				case lines.LineWithoutStatus, lines.LineMatched:
-					style = style.Foreground(FilterColors[line.ColorIndex[lineXPos]][0])
+					style = style.Foreground(FilterColors[line.ColorIndex[rawXPos]][0])
 				case lines.LineDimmed:
-					style = style.Foreground(FilterColors[line.ColorIndex[lineXPos]][1])
+					style = style.Foreground(FilterColors[line.ColorIndex[rawXPos]][1])
 				}
 				style = style.Reverse(true)
+			} else if compact != nil && colorize {
+				style = v.colorCompactLine(lineXPos, compact, style)
 			} else if detectedTokens != nil {
 				// lastly check if we can color the character according to the files format
 				style = v.colorAccordingToFileFormat(lineXPos, detectedTokens, style)
+			} else if logfmtFields != nil {
+				style = v.colorKeyOrValue(str, lineXPos, logfmtFields, style)
 			}
 		}
 
 		screen.SetContent(x, y, r, nil, style)
 	}
+
+	if line.Repeats > 0 {
+		v.renderRepeats(line, start+len(str)-v.CurrentDisplay.CurrentCol, y)
+	}
+}
+
+// Right after the end of the line (if there's enough space) the number of
+// lines folded into it.
+func (v *View) renderRepeats(line *lines.Line, x int, y int) {
+	badge := fmt.Sprintf(" ×%d ", line.Repeats)
+	width := len([]rune(badge))
+
+	x = max(0, min(x, v.Width()-width))
+	components.RenderText(x, y, badge, ViewRepeatsStyle)
+}
+
+// Separates runs of matches and their context, like grep does.
+func (v *View) renderDivider(y int) {
+	x := components.RenderText(0, y, "--", ViewDividerStyle)
+	components.DrawChars(x, y, v.Width()-x, ' ', ViewStyle)
 }
 
 func (v *View) colorAccordingToFileFormat(lineXPos int, matches []int,
@@ -129,12 +198,56 @@ func (v *View) colorAccordingToFileFormat(lineXPos int, matches []int,
 	return baseStyle
 }
 
+// Everything that's neither a value (including its quotes) nor a separator
+// is a key.
+func (v *View) colorKeyOrValue(str string, lineXPos int, fields []formats.Field,
+	baseStyle tcell.Style) tcell.Style {
+
+	switch str[lineXPos] {
+	case ' ', '=':
+		return baseStyle
+	case '"':
+		return baseStyle.Foreground(ViewFieldValueColor)
+	}
+
+	for _, field := range fields {
+		if lineXPos >= field.Start && lineXPos < field.End {
+			return baseStyle.Foreground(ViewFieldValueColor)
+		}
+	}
+
+	return baseStyle.Foreground(ViewFieldKeyColor)
+}
+
+// Leading fields get colored like the groups of a format regex, all other
+// keys and values get their own colors.
+func (v *View) colorCompactLine(lineXPos int, compact *formats.CompactLine,
+	baseStyle tcell.Style) tcell.Style {
+
+	for i, field := range compact.Leading {
+		if lineXPos >= field.Start && lineXPos < field.End {
+			return baseStyle.Foreground(FilterColors[1+i%(len(FilterColors)-1)][1])
+		}
+	}
+
+	if compact.RawPos[lineXPos] < 0 {
+		return baseStyle.Foreground(ViewFieldKeyColor)
+	}
+
+	return baseStyle.Foreground(ViewFieldValueColor)
+}
+
 func (v *View) determineStyle(line *lines.Line, matched bool) tcell.Style {
-	if matched {
+	if line.Marker {
+		return ViewMarkerStyle
+	} else if matched {
 		return CurrentMatchStyle
 	} else {
 		switch line.Status {
 		case lines.LineWithoutStatus, lines.LineMatched:
+			if line.Origin == lines.OriginStderr {
+				return ViewStderrStyle
+			}
 			return ViewStyle
 		case lines.LineDimmed:
 			return ViewDimmedStyle
@@ -147,6 +260,15 @@ func (v *View) determineStyle(line *lines.Line, matched bool) tcell.Style {
 	}
 }
 
+func (v *View) severityTint(line *lines.Line, matched bool) (tcell.Color, bool) {
+	if line.Marker || matched || line.Status == lines.LineDimmed {
+		return tcell.ColorDefault, false
+	}
+
+	color, ok := ViewSeverityColors[line.Severity]
+	return color, ok
+}
+
 func (v *View) renderLineNumber(line *lines.Line, y int, matched bool) int {
 	if line.No < 0 {
 		return 0 // TODO: 0 ok?
@@ -163,6 +285,24 @@ func (v *View) renderLineNumber(line *lines.Line, y int, matched bool) int {
 	return x
 }
 
+// A colored bar to tell apart lines from the different merged files.
+func (v *View) renderOriginGutter(line *lines.Line, x int, y int) int {
+	if x >= v.Width() {
+		return x
+	}
+
+	index := slices.Index(config.User().FilePaths, line.Origin)
+	if index == -1 {
+		screen.SetContent(x, y, ' ', nil, ViewStyle)
+		return x + 1
+	}
+
+	color := FilterColors[1+index%(len(FilterColors)-1)][0]
+	screen.SetContent(x, y, '▌', nil, ViewStyle.Foreground(color))
+
+	return x + 1
+}
+
 func (v *View) determineLineNumberStyle(line *lines.Line, matched bool) tcell.Style {
 	if matched {
 		return ViewCurrentMatchLineNumberStyle
@@ -181,6 +321,33 @@ func (v *View) determineLineNumberStyle(line *lines.Line, matched bool) tcell.St
 	}
 }
 
+// Expands the current match if it's folded, otherwise the topmost folded run
+// on screen.
+func (v *View) expandRun() {
+	if v.CurrentDisplay == nil {
+		return
+	}
+
+	lineNo := -1
+	for _, line := range v.CurrentDisplay.Buffer {
+		if line.Repeats == 0 {
+			continue
+		}
+		if line.No == v.CurrentDisplay.CurrentMatch {
+			lineNo = line.No
+			break
+		} else if lineNo == -1 {
+			lineNo = line.No
+		}
+	}
+
+	if lineNo == -1 {
+		screen.Beep()
+		return
+	}
+	model.GetFilterManager().ExpandRun(lineNo)
+}
+
 func (v *View) Resize(x, y, width, height int) {
 	// x, y ignored for now
 	v.ComponentImpl.Resize(0, 0, width, height)
@@ -191,7 +358,12 @@ func (v *View) Resize(x, y, width, height int) {
 func (v *View) HandleEvent(ev tcell.Event) bool {
 	switch ev := ev.(type) {
 	case *model.EventDisplay:
-		v.RenderNewDisplay(&ev.Display, true)
+		v.RenderNewDisplay(&ev.Display, false)
+		// popups were rendered before and have to stay on top
+		if window.popup != nil && window.popup.IsVisible() {
+			window.popup.Render(false)
+		}
+		screen.Show()
 		return false
 	case *model.EventError:
 		if ev.Beep {
@@ -227,6 +399,12 @@ func (v *View) HandleEvent(ev tcell.Event) bool {
 			case 'l':
 				model.GetFilterManager().ScrollHorizontal(1)
 				return true
+			case 'H':
+				model.GetFilterManager().ScrollHorizontal(-v.Width() / 2)
+				return true
+			case 'L':
+				model.GetFilterManager().ScrollHorizontal(v.Width() / 2)
+				return true
 			case ' ', 'f':
 				model.GetFilterManager().PageDown()
 				return true
@@ -239,6 +417,18 @@ func (v *View) HandleEvent(ev tcell.Event) bool {
 				model.GetFilterManager().FindMatch(-1)
 			case 'F':
 				model.GetFilterManager().ToggleFollowMode()
+			case 'R':
+				model.GetFilterManager().RestartCommand()
+			case 'x':
+				v.expandRun()
+				return true
+			case 'X':
+				model.GetFilterManager().CollapseRuns()
+				return true
+			case 'C':
+				config.User().Compact = !config.User().Compact
+				v.RenderNewDisplay(nil, true)
+				return true
 			}
 		case tcell.KeyDown, tcell.KeyEnter:
 			model.GetFilterManager().ScrollDown()
@@ -247,11 +437,19 @@ func (v *View) HandleEvent(ev tcell.Event) bool {
 			model.GetFilterManager().ScrollUp()
 			return true
 		case tcell.KeyRight:
+			if ev.Modifiers()&tcell.ModShift != 0 {
+				model.GetFilterManager().ScrollHorizontal(v.Width() / 2)
+				return true
+			}
 			model.GetFilterManager().ScrollHorizontal(1)
 			v.CurrentDisplay.CurrentCol++
 			v.RenderNewDisplay(nil, true)
 			return true
 		case tcell.KeyLeft:
+			if ev.Modifiers()&tcell.ModShift != 0 {
+				model.GetFilterManager().ScrollHorizontal(-v.Width() / 2)
+				return true
+			}
 			model.GetFilterManager().ScrollHorizontal(-1)
 			if v.CurrentDisplay.CurrentCol > 0 {
 				v.CurrentDisplay.CurrentCol--
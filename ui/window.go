@@ -27,6 +27,7 @@ type Window struct {
 	popup          components.Modal
 	panelSelection *PanelSelection
 	exPanel        *ExPanel
+	templatesModal *TemplatesModal
 }
 
 func GetScreen() tcell.Screen {
@@ -62,6 +63,9 @@ func Setup() *Window {
 	window.panelSelection = NewPanelSelection()
 	components.Add(window.panelSelection, 2)
 
+	window.templatesModal = NewTemplatesModal()
+	components.Add(window.templatesModal, 2)
+
 	window.exPanel = NewExPanel()
 	components.Add(window.exPanel, 1)
 	window.exPanel.SetContent("Hallo")
@@ -158,6 +162,10 @@ func (w *Window) EventLoop(quit chan<- string) bool {
 			case 'S':
 				w.savePreset()
 				return false
+			case 'T':
+				w.popup = w.templatesModal
+				w.templatesModal.Open()
+				return false
 			}
 		case tcell.KeyBacktab:
 			err := GetPanelManager().switchPanel(-1)
@@ -278,7 +286,9 @@ func (w *Window) savePreset() {
 
 		_, err := os.Stat(presetFileName)
 		if err != nil {
+			GetPanelManager().copyPanelsToConfig()
 			config.WritePreset(presetFileName)
+			return
 		}
 
 		ShowYesNoBar("File exists! Overwrite (y/n)?", func() {